@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"take-home-test/internal/postgres"
+	"take-home-test/internal/store"
+	"take-home-test/internal/users"
+)
+
+const createAdminUsage = "usage: app create-admin -username NAME -email EMAIL < password"
+
+// runCreateAdmin creates an admin account from the command line. The
+// password is read from the first line of stdin so it stays out of the
+// shell history and process list.
+func runCreateAdmin(db *sql.DB, args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "admin username")
+	email := flags.String("email", "", "admin email")
+	if err := flags.Parse(args); err != nil {
+		return errors.New(createAdminUsage)
+	}
+	if *username == "" || *email == "" {
+		return errors.New(createAdminUsage)
+	}
+
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	user, err := users.NewAccount(*username, *email, password, "admin")
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := postgres.NewUserRepository(db).Create(ctx, &user); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			return fmt.Errorf("email %s is already registered", *email)
+		}
+		return err
+	}
+
+	users.Audit(ctx, postgres.NewAuditRepository(db), store.AuditEntry{
+		Action:       store.AuditAdminCreate,
+		TargetUserID: user.UserID,
+		Details:      "admin account created with the create-admin command",
+	})
+
+	fmt.Printf("created admin %s (user_id %d)\n", user.Email, user.UserID)
+	return nil
+}
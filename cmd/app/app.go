@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"take-home-test/internal/auth"
 	"take-home-test/internal/bookings"
 	"take-home-test/internal/configs"
 	"take-home-test/internal/fields"
+	"take-home-test/internal/mailer"
 	"take-home-test/internal/middleware"
 	"take-home-test/internal/payments"
 	"take-home-test/internal/postgres"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/promos"
 	"take-home-test/internal/users"
+	"take-home-test/internal/waitlist"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -26,29 +34,195 @@ func main() {
 	}
 	defer db.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "create-admin" {
+		if err := runCreateAdmin(db, os.Args[2:], os.Stdin); err != nil {
+			log.Fatalf("create-admin: %v", err)
+		}
+		return
+	}
+
+	refundPolicy, err := bookings.ParseRefundPolicy(cfg.BookingConfig.RefundPolicy)
+	if err != nil {
+		log.Fatalf("invalid refund policy: %v", err)
+	}
+	reschedulePolicy := bookings.ReschedulePolicy{
+		Cutoff:         cfg.BookingConfig.RescheduleCutoff,
+		MaxReschedules: cfg.BookingConfig.MaxReschedules,
+	}
+
+	var gateway payments.PaymentGateway
+	switch cfg.PaymentConfig.Provider {
+	case "mock":
+		gateway = payments.NewMockGateway()
+	default:
+		log.Fatalf("unknown payment provider %q", cfg.PaymentConfig.Provider)
+	}
+
+	userRepo := postgres.NewUserRepository(db)
+	fieldRepo := postgres.NewFieldRepository(db)
+	bookingRepo := postgres.NewBookingRepository(db)
+	paymentRepo := postgres.NewPaymentRepository(db)
+	tokenRepo := postgres.NewTokenRepository(db)
+	auditRepo := postgres.NewAuditRepository(db)
+	mfaRepo := postgres.NewMFARepository(db)
+	roleRepo := postgres.NewRoleRepository(db)
+	pricingRepo := postgres.NewPricingRuleRepository(db)
+	pricer := pricing.NewService(pricingRepo)
+	promoRepo := postgres.NewPromoRepository(db)
+	waitlistRepo := postgres.NewWaitlistRepository(db)
+
+	var keys *auth.KeySet
+	if cfg.AppConfig.JWTAlgorithm == auth.AlgorithmHS256 {
+		keys, err = auth.NewHMACKeySet(cfg.AppConfig.JWTSecret)
+	} else {
+		keys, err = auth.LoadKeySet(cfg.AppConfig.JWTAlgorithm, cfg.AppConfig.JWTKeyDir, cfg.AppConfig.JWTSigningKeyID)
+	}
+	if err != nil {
+		log.Fatalf("failed to load JWT keys: %v", err)
+	}
+
+	sessions := auth.NewSessions(keys, cfg.AppConfig.AccessTokenTTL, cfg.AppConfig.RefreshTokenTTL, userRepo, tokenRepo, roleRepo)
+	sessions.RequireAdminMFA(cfg.AppConfig.RequireAdminMFA)
+	go sessions.RunCleanup(context.Background(), time.Hour)
+
+	var mail mailer.Mailer
+	switch cfg.MailConfig.Driver {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(cfg.MailConfig.SMTPHost, cfg.MailConfig.SMTPPort, cfg.MailConfig.SMTPUsername, cfg.MailConfig.SMTPPassword, cfg.MailConfig.From)
+	default:
+		mailLog := os.Stdout
+		if cfg.MailConfig.LogFile != "" {
+			if mailLog, err = os.OpenFile(cfg.MailConfig.LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600); err != nil {
+				log.Fatalf("failed to open mail log: %v", err)
+			}
+			defer mailLog.Close()
+		}
+		mail = mailer.NewLogMailer(mailLog)
+	}
+	emails := users.NewEmails(userRepo, tokenRepo, mail, cfg.MailConfig.BaseURL, cfg.MailConfig.VerifyTTL, cfg.MailConfig.ResetTTL)
+
+	throttle := auth.NewLoginThrottle(postgres.NewLoginAttemptRepository(db), auth.ThrottlePolicy{
+		AccountMaxFailures: cfg.LoginConfig.AccountMaxFailures,
+		IPMaxFailures:      cfg.LoginConfig.IPMaxFailures,
+		Window:             cfg.LoginConfig.FailureWindow,
+		Lockout:            cfg.LoginConfig.Lockout,
+		MaxDelay:           cfg.LoginConfig.MaxDelay,
+	})
+	go throttle.RunCleanup(context.Background(), time.Hour)
+
+	requireUser := middleware.AuthMiddleware(sessions)
+	requireVerified := middleware.VerifiedEmailMiddleware(userRepo, cfg.MailConfig.RequireVerified)
+	can := func(permission string) fiber.Handler {
+		return middleware.RequirePermission(sessions, permission)
+	}
+
+	go bookings.RunSweeper(context.Background(), bookingRepo, cfg.BookingConfig.SweepInterval)
+
+	offers := waitlist.NewService(waitlistRepo, bookingRepo, fieldRepo, userRepo, pricer, mail, cfg.BookingConfig.WaitlistOfferTTL)
+	go waitlist.RunOffers(context.Background(), offers, cfg.BookingConfig.SweepInterval)
+
 	app := fiber.New()
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendString("Take Home Test Sagara")
 	})
 
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(keys.JWKS())
+	})
+
 	//Auth
-	app.Post("/auth/register", users.RegisterUser(db, cfg.AppConfig.JWTSecret))
-	app.Get("/auth/login", users.Login(db, cfg.AppConfig.JWTSecret))
-	app.Post("/admin/auth/register", users.RegisterAdmin(db, cfg.AppConfig.JWTSecret))
+	app.Post("/auth/register", users.RegisterUser(userRepo, sessions, emails))
+	app.Get("/auth/login", users.Login(userRepo, mfaRepo, sessions, throttle))
+	app.Post("/auth/refresh", users.Refresh(sessions))
+	app.Post("/auth/logout", requireUser, users.Logout(sessions))
+	app.Post("/auth/mfa/verify", users.VerifyMFA(mfaRepo, sessions, throttle))
+	app.Post("/auth/mfa/enroll", requireUser, users.EnrollMFA(userRepo, mfaRepo, cfg.AppConfig.MFAIssuer))
+	app.Post("/auth/mfa/confirm", requireUser, users.ConfirmMFA(userRepo, mfaRepo, sessions))
+	app.Post("/auth/mfa/disable", requireUser, users.DisableMFA(mfaRepo, throttle))
+	app.Post("/auth/verify-email/request", requireUser, users.RequestVerification(userRepo, emails))
+	app.Post("/auth/verify-email", users.VerifyEmail(userRepo, tokenRepo))
+	app.Post("/auth/password/forgot", users.ForgotPassword(userRepo, emails))
+	app.Post("/auth/password/reset", users.ResetPassword(userRepo, tokenRepo))
+	app.Post("/auth/email/confirm", users.ConfirmEmailChange(userRepo, tokenRepo))
+	app.Post("/admin/auth/bootstrap", users.BootstrapAdmin(userRepo, auditRepo, sessions, cfg.AppConfig.BootstrapToken))
+
+	//Profile
+	app.Get("/me", requireUser, users.GetProfile(userRepo, mfaRepo))
+	app.Patch("/me", requireUser, users.UpdateProfile(userRepo))
+	app.Post("/me/password", requireUser, users.ChangePassword(userRepo, tokenRepo, sessions, throttle))
+	app.Post("/me/email", requireUser, users.RequestEmailChange(userRepo, emails, throttle))
+	app.Get("/me/export", requireUser, users.ExportAccount(userRepo, mfaRepo, bookingRepo, paymentRepo))
+	app.Delete("/me", requireUser, users.DeleteAccount(userRepo, throttle, auditRepo))
+
+	//Admin
+	app.Post("/admin/auth/register", can(auth.PermUsersManage), users.RegisterAdmin(userRepo, auditRepo))
+	app.Get("/admin/users", can(auth.PermUsersManage), users.ListUsers(userRepo))
+	app.Get("/admin/users/:id", can(auth.PermUsersManage), users.GetUser(userRepo))
+	app.Post("/admin/users/:id/disable", can(auth.PermUsersManage), users.SetUserDisabled(userRepo, tokenRepo, auditRepo, true))
+	app.Post("/admin/users/:id/enable", can(auth.PermUsersManage), users.SetUserDisabled(userRepo, tokenRepo, auditRepo, false))
+	app.Post("/admin/users/:id/promote", can(auth.PermUsersManage), users.PromoteAdmin(userRepo, tokenRepo, auditRepo))
+	app.Put("/admin/users/:id/role", can(auth.PermUsersManage), users.SetUserRole(userRepo, roleRepo, tokenRepo, auditRepo))
+	app.Post("/admin/users/:id/unlock", can(auth.PermUsersManage), users.UnlockAccount(userRepo, throttle, auditRepo))
+	app.Get("/admin/roles", can(auth.PermUsersManage), users.ListRoles(roleRepo))
+	app.Get("/admin/audit-log", can(auth.PermAuditRead), users.ListAuditLog(auditRepo))
+	app.Get("/admin/promo-codes", can(auth.PermPromosManage), promos.ListPromosHandler(promoRepo))
+	app.Post("/admin/promo-codes", can(auth.PermPromosManage), promos.CreatePromoHandler(promoRepo))
+	app.Get("/admin/promo-codes/:id", can(auth.PermPromosManage), promos.GetPromoHandler(promoRepo))
+	app.Put("/admin/promo-codes/:id", can(auth.PermPromosManage), promos.UpdatePromoHandler(promoRepo))
+	app.Delete("/admin/promo-codes/:id", can(auth.PermPromosManage), promos.DeletePromoHandler(promoRepo))
 
 	//Fields
-	app.Get("/fields", fields.GetFieldsHandler(db))
-	app.Get("/fields/:id", fields.GetFieldHandler(db))
-	app.Post("/fields", middleware.AdminMiddleware(cfg.AppConfig.JWTSecret), fields.CreateFieldHandler(db))
-	app.Put("/fields/:id", middleware.AdminMiddleware(cfg.AppConfig.JWTSecret), fields.UpdateFieldHandler(db))
-	app.Delete("/fields/:id", middleware.AdminMiddleware(cfg.AppConfig.JWTSecret), fields.DeleteFieldHandler(db))
+	app.Get("/fields", fields.GetFieldsHandler(fieldRepo))
+	app.Get("/fields/:id", fields.GetFieldHandler(fieldRepo))
+	app.Post("/fields", can(auth.PermFieldsWrite), fields.CreateFieldHandler(fieldRepo))
+	app.Put("/fields/:id", can(auth.PermFieldsWrite), fields.UpdateFieldHandler(fieldRepo))
+	app.Delete("/fields/:id", can(auth.PermFieldsWrite), fields.DeleteFieldHandler(fieldRepo))
+	app.Get("/fields/:id/schedule", fields.GetScheduleHandler(fieldRepo))
+	app.Get("/fields/:id/availability", fields.GetAvailabilityHandler(fieldRepo, bookingRepo))
+	app.Put("/fields/:id/rules", can(auth.PermFieldsWrite), fields.SetRulesHandler(fieldRepo))
+	app.Put("/fields/:id/hours", can(auth.PermFieldsWrite), fields.SetHoursHandler(fieldRepo))
+	app.Put("/fields/:id/exceptions/:date", can(auth.PermFieldsWrite), fields.SetExceptionHandler(fieldRepo))
+	app.Delete("/fields/:id/exceptions/:date", can(auth.PermFieldsWrite), fields.DeleteExceptionHandler(fieldRepo))
+	app.Get("/fields/:id/pricing-rules", pricing.ListRulesHandler(pricingRepo))
+	app.Get("/fields/:id/quote", pricing.QuoteHandler(fieldRepo, pricer))
+	app.Post("/fields/:id/pricing-rules", can(auth.PermFieldsWrite), pricing.CreateRuleHandler(pricingRepo))
+	app.Put("/fields/:id/pricing-rules/:rule_id", can(auth.PermFieldsWrite), pricing.UpdateRuleHandler(pricingRepo))
+	app.Delete("/fields/:id/pricing-rules/:rule_id", can(auth.PermFieldsWrite), pricing.DeleteRuleHandler(pricingRepo))
+	app.Post("/fields/:id/blackouts", can(auth.PermFieldsWrite), fields.CreateBlackoutHandler(fieldRepo))
+	app.Delete("/fields/:id/blackouts/:blackout_id", can(auth.PermFieldsWrite), fields.DeleteBlackoutHandler(fieldRepo))
 
 	//Booking
-	app.Post("/bookings", middleware.UserMiddleware(cfg.AppConfig.JWTSecret), bookings.CreateBookingHandler(db))
+	app.Post("/bookings", can(auth.PermBookingsWrite), requireVerified, bookings.CreateBookingHandler(bookingRepo, fieldRepo, promoRepo, pricer, cfg.BookingConfig.HoldTTL))
+	app.Post("/bookings/recurring", can(auth.PermBookingsWrite), requireVerified, bookings.CreateRecurringBookingHandler(bookingRepo, fieldRepo, pricer, cfg.BookingConfig.HoldTTL))
+	app.Get("/bookings", can(auth.PermBookingsRead), requireVerified, bookings.ListBookingsHandler(bookingRepo))
+	app.Get("/bookings/:id", can(auth.PermBookingsRead), requireVerified, bookings.GetBookingHandler(bookingRepo))
+	app.Patch("/bookings/:id", can(auth.PermBookingsWrite), requireVerified, bookings.RescheduleBookingHandler(bookingRepo, fieldRepo, promoRepo, pricer, reschedulePolicy, offers))
+	app.Post("/bookings/:id/cancel", can(auth.PermBookingsWrite), requireVerified, bookings.CancelBookingHandler(bookingRepo, paymentRepo, gateway, refundPolicy, offers))
+	app.Get("/bookings/series/:id", can(auth.PermBookingsRead), requireVerified, bookings.GetSeriesHandler(bookingRepo))
+	app.Post("/bookings/series/:id/cancel", can(auth.PermBookingsWrite), requireVerified, bookings.CancelSeriesHandler(bookingRepo, paymentRepo, gateway, refundPolicy, offers))
+	app.Post("/bookings/series/:id/pay", can(auth.PermBookingsWrite), requireVerified, bookings.PaySeriesHandler(bookingRepo, paymentRepo, gateway, cfg.PaymentConfig.Currency))
+	app.Post("/bookings/:id/check-in", can(auth.PermBookingsCheckIn), bookings.CheckInBookingHandler(bookingRepo))
+
+	//Waitlist
+	app.Post("/waitlist", can(auth.PermBookingsWrite), requireVerified, waitlist.JoinHandler(waitlistRepo, bookingRepo, fieldRepo))
+	app.Get("/waitlist", can(auth.PermBookingsRead), requireVerified, waitlist.ListHandler(waitlistRepo))
+	app.Get("/waitlist/:id", can(auth.PermBookingsRead), requireVerified, waitlist.GetHandler(waitlistRepo))
+	app.Delete("/waitlist/:id", can(auth.PermBookingsWrite), requireVerified, waitlist.LeaveHandler(waitlistRepo))
 
 	//Payment
-	app.Post("/payments", middleware.UserMiddleware(cfg.AppConfig.JWTSecret), payments.UpdatePayment(db))
+	app.Post("/payments", can(auth.PermBookingsWrite), payments.UpdatePayment(bookingRepo, paymentRepo, gateway, cfg.PaymentConfig.Currency))
+	app.Post("/payments/webhook", payments.WebhookHandler(paymentRepo, gateway, cfg.PaymentConfig.WebhookSecret, cfg.PaymentConfig.WebhookTolerance))
+	app.Get("/payments", can(auth.PermPaymentsReadAll), payments.ListPaymentsHandler(paymentRepo))
+	app.Get("/payments/:id", can(auth.PermPaymentsRead), payments.GetPaymentHandler(bookingRepo, paymentRepo, gateway))
 
 	port := fmt.Sprintf(":%d", cfg.AppConfig.Port)
 	log.Printf("Server running on port %s", port)
@@ -2,25 +2,34 @@ package auth
 
 import (
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func GenerateJWT(jwtSecret string, userID int, email string, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateJWT signs a token for the user. extra claims are added on top of
+// the standard ones.
+func GenerateJWT(keys *KeySet, ttl time.Duration, userID int, email string, role string, extra jwt.MapClaims) (string, error) {
+	expirationTime := time.Now().Add(ttl)
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
 
 	claims := jwt.MapClaims{
+		"jti":     jti,
 		"user_id": userID,
 		"email":   email,
 		"role":    role,
 		"exp":     expirationTime.Unix(),
 		"iat":     time.Now().Unix(),
 	}
+	for key, value := range extra {
+		claims[key] = value
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := keys.sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -28,13 +37,8 @@ func GenerateJWT(jwtSecret string, userID int, email string, role string) (strin
 	return tokenString, nil
 }
 
-func ExtractToken(jwtSecret string, tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
+func ExtractToken(keys *KeySet, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, keys.keyFunc)
 
 	if err != nil {
 		return nil, err
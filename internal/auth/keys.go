@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// KeySet holds the key access tokens are signed with and every key they
+// may be verified with, by kid. Keeping the previous keys around for
+// verification lets the signing key rotate without invalidating tokens
+// that are still in flight.
+type KeySet struct {
+	method     jwt.SigningMethod
+	signingKID string
+	signingKey any
+	verifyKeys map[string]any
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeySet signs and verifies with a shared secret. Tokens carry no
+// kid, so tokens issued before kids were introduced stay valid.
+func NewHMACKeySet(secret string) (*KeySet, error) {
+	if secret == "" {
+		return nil, errors.New("HS256 requires a secret")
+	}
+	return &KeySet{
+		method:     jwt.SigningMethodHS256,
+		signingKey: []byte(secret),
+		verifyKeys: map[string]any{"": []byte(secret)},
+	}, nil
+}
+
+// LoadKeySet reads PEM keys for algorithm from dir. Each file is named
+// <kid>.pem. Private keys can sign and verify; public keys only verify,
+// which is how a retired key is kept until the tokens it signed expire.
+// The key signingKID signs new tokens, so a new key can be published
+// before it is used; when it is empty the private key with the greatest kid
+// is used.
+func LoadKeySet(algorithm, dir, signingKID string) (*KeySet, error) {
+	var method jwt.SigningMethod
+	switch algorithm {
+	case AlgorithmRS256:
+		method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := &KeySet{method: method, verifyKeys: make(map[string]any)}
+	signingKeys := make(map[string]any)
+	newest := ""
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		private, public, err := parseKey(algorithm, pem)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", path, err)
+		}
+		if private != nil {
+			signingKeys[kid] = private
+			if kid > newest {
+				newest = kid
+			}
+		}
+		keys.verifyKeys[kid] = public
+	}
+
+	if signingKID == "" {
+		if len(signingKeys) == 0 {
+			return nil, fmt.Errorf("no %s private key found in %s", algorithm, dir)
+		}
+		signingKID = newest
+	}
+	private, ok := signingKeys[signingKID]
+	if !ok {
+		if _, public := keys.verifyKeys[signingKID]; public {
+			return nil, fmt.Errorf("signing key %q in %s is a public key", signingKID, dir)
+		}
+		return nil, fmt.Errorf("signing key %q not found in %s", signingKID, dir)
+	}
+	keys.signingKID = signingKID
+	keys.signingKey = private
+
+	return keys, nil
+}
+
+// parseKey returns the private key, if the PEM holds one, and the public
+// key.
+func parseKey(algorithm string, pem []byte) (any, any, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			return private, &private.PublicKey, nil
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		return nil, public, err
+	case AlgorithmEdDSA:
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+			signer, ok := private.(crypto.Signer)
+			if !ok {
+				return nil, nil, errors.New("not an Ed25519 private key")
+			}
+			return private, signer.Public(), nil
+		}
+		public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+		return nil, public, err
+	}
+	return nil, nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
+
+func (k *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.signingKID != "" {
+		token.Header["kid"] = k.signingKID
+	}
+	return token.SignedString(k.signingKey)
+}
+
+// keyFunc picks the verification key by the token's kid and rejects any
+// algorithm other than the configured one.
+func (k *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// JWKS returns the public verification keys. It is empty for HS256, whose
+// secret must never be published.
+func (k *KeySet) JWKS() JWKS {
+	kids := make([]string, 0, len(k.verifyKeys))
+	for kid := range k.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, kid := range kids {
+		switch key := k.verifyKeys[kid].(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: AlgorithmRS256,
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: AlgorithmEdDSA,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(key),
+			})
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEdKey writes an Ed25519 key named kid to dir, private or only its
+// public half.
+func writeEdKey(t *testing.T, dir, kid string, private bool) {
+	t.Helper()
+
+	public, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &pem.Block{Type: "PUBLIC KEY"}
+	if private {
+		block.Type = "PRIVATE KEY"
+		block.Bytes, err = x509.MarshalPKCS8PrivateKey(key)
+	} else {
+		block.Bytes, err = x509.MarshalPKIXPublicKey(public)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeySetSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	writeEdKey(t, dir, "2026-01", true)
+	// Published ahead of a staged rotation, not yet signing.
+	writeEdKey(t, dir, "2026-02", true)
+	writeEdKey(t, dir, "2025-12", false)
+
+	tests := []struct {
+		name       string
+		signingKID string
+		want       string
+		wantErr    bool
+	}{
+		{"configured kid is kept", "2026-01", "2026-01", false},
+		{"greatest kid by default", "", "2026-02", false},
+		{"public key cannot sign", "2025-12", "", true},
+		{"missing kid", "2027-01", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeySet(AlgorithmEdDSA, dir, tt.signingKID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadKeySet succeeded signing with %q, want an error", keys.signingKID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeySet: %v", err)
+			}
+			if keys.signingKID != tt.want {
+				t.Errorf("signing kid %q, want %q", keys.signingKID, tt.want)
+			}
+			if len(keys.verifyKeys) != 3 {
+				t.Errorf("%d verify keys, want all 3 published", len(keys.verifyKeys))
+			}
+		})
+	}
+}
+
+func TestLoadKeySetWithoutPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	writeEdKey(t, dir, "2026-01", false)
+
+	if _, err := LoadKeySet(AlgorithmEdDSA, dir, ""); err == nil {
+		t.Fatal("LoadKeySet succeeded with only public keys, want an error")
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+// Permissions granted to roles in the role_permissions table.
+const (
+	PermBookingsRead          = "bookings:read"
+	PermBookingsWrite         = "bookings:write"
+	PermBookingsReadAll       = "bookings:read_all"
+	PermBookingsCancelAll     = "bookings:cancel_all"
+	PermBookingsRescheduleAll = "bookings:reschedule_all"
+	PermBookingsCheckIn       = "bookings:check_in"
+	PermPaymentsRead          = "payments:read"
+	PermPaymentsReadAll       = "payments:read_all"
+	PermFieldsWrite           = "fields:write"
+	PermPromosManage          = "promos:manage"
+	PermUsersManage           = "users:manage"
+	PermAuditRead             = "audit:read"
+)
+
+// Permissions is what a session may do. Middleware stores it in the
+// request locals under "permissions".
+type Permissions []string
+
+func (p Permissions) Has(permission string) bool {
+	for _, granted := range p {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionsFromClaim reads the perms claim, which decodes as a list of
+// interfaces. ok is false when the token has no such claim.
+func permissionsFromClaim(claim any) (Permissions, bool) {
+	list, ok := claim.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	permissions := make(Permissions, 0, len(list))
+	for _, item := range list {
+		if permission, ok := item.(string); ok {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, true
+}
@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token already used")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa token")
+	ErrAccountDisabled     = errors.New("account is disabled")
+)
+
+// mfaChallengeTTL is how long a user has to enter their second factor
+// after their password was accepted.
+const mfaChallengeTTL = 5 * time.Minute
+
+// Token types, in the typ claim. Access tokens issued before the claim was
+// introduced have none.
+const (
+	tokenTypeAccess = "access"
+	tokenTypeMFA    = "mfa_pending"
+)
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// Sessions issues short-lived access tokens together with rotating refresh
+// tokens. Refresh tokens are stored hashed; presenting one that was already
+// rotated revokes its whole family, since either the client or an attacker
+// holds a stolen copy.
+type Sessions struct {
+	keys       *KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	users      store.UserRepository
+	tokens     store.TokenRepository
+	roles      store.RoleRepository
+
+	requireAdminMFA bool
+}
+
+func NewSessions(keys *KeySet, accessTTL, refreshTTL time.Duration, users store.UserRepository, tokens store.TokenRepository, roles store.RoleRepository) *Sessions {
+	return &Sessions{
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		users:      users,
+		tokens:     tokens,
+		roles:      roles,
+	}
+}
+
+// RequireAdminMFA makes admin routes reject sessions that did not begin
+// with a second factor.
+func (s *Sessions) RequireAdminMFA(required bool) {
+	s.requireAdminMFA = required
+}
+
+func (s *Sessions) AdminMFARequired() bool {
+	return s.requireAdminMFA
+}
+
+// Start issues a token pair that begins a new refresh token family. mfa
+// records whether the user passed a second factor.
+func (s *Sessions) Start(ctx context.Context, user store.User, mfa bool) (TokenPair, error) {
+	if user.DisabledAt != nil {
+		return TokenPair{}, ErrAccountDisabled
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, next, err := s.newRefreshToken(user.UserID, familyID, mfa)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := s.tokens.CreateRefreshToken(ctx, &next); err != nil {
+		return TokenPair{}, err
+	}
+
+	return s.pair(ctx, user, refreshToken, mfa)
+}
+
+// StartMFAChallenge issues the short-lived token that stands in for a
+// session between the password and the second factor.
+func (s *Sessions) StartMFAChallenge(user store.User) (string, error) {
+	return GenerateJWT(s.keys, mfaChallengeTTL, user.UserID, user.Email, user.Role, jwt.MapClaims{"typ": tokenTypeMFA})
+}
+
+// MFAChallengeUser returns the user an unused mfa challenge token belongs
+// to.
+func (s *Sessions) MFAChallengeUser(ctx context.Context, challenge string) (store.User, error) {
+	claims, err := ExtractToken(s.keys, challenge)
+	if err != nil || claims["typ"] != tokenTypeMFA {
+		return store.User{}, ErrInvalidMFAChallenge
+	}
+
+	jti, _ := claims["jti"].(string)
+	denied, err := s.tokens.IsAccessTokenDenied(ctx, jti)
+	if err != nil {
+		return store.User{}, err
+	}
+	if denied {
+		return store.User{}, ErrInvalidMFAChallenge
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	user, err := s.users.GetByID(ctx, int(userID))
+	if errors.Is(err, store.ErrNotFound) {
+		return store.User{}, ErrInvalidMFAChallenge
+	}
+	return user, err
+}
+
+// FinishMFAChallenge spends the challenge token and starts a session that
+// passed the second factor.
+func (s *Sessions) FinishMFAChallenge(ctx context.Context, challenge string, user store.User) (TokenPair, error) {
+	claims, err := ExtractToken(s.keys, challenge)
+	if err != nil {
+		return TokenPair{}, ErrInvalidMFAChallenge
+	}
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if err := s.tokens.DenyAccessToken(ctx, jti, time.Unix(int64(exp), 0)); err != nil {
+		return TokenPair{}, err
+	}
+
+	return s.Start(ctx, user, true)
+}
+
+// Refresh exchanges a refresh token for a new pair in the same family and
+// returns the user it belongs to.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (store.User, TokenPair, error) {
+	current, err := s.tokens.GetRefreshToken(ctx, HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.User{}, TokenPair{}, ErrInvalidRefreshToken
+		}
+		return store.User{}, TokenPair{}, err
+	}
+
+	if current.RevokedAt != nil {
+		return store.User{}, TokenPair{}, s.revokeReusedFamily(ctx, current)
+	}
+	if !current.ExpiresAt.After(time.Now()) {
+		return store.User{}, TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.User{}, TokenPair{}, ErrInvalidRefreshToken
+		}
+		return store.User{}, TokenPair{}, err
+	}
+	if user.DisabledAt != nil {
+		return store.User{}, TokenPair{}, ErrAccountDisabled
+	}
+
+	rotated, next, err := s.newRefreshToken(user.UserID, current.FamilyID, current.MFA)
+	if err != nil {
+		return store.User{}, TokenPair{}, err
+	}
+	if err := s.tokens.RotateRefreshToken(ctx, current.TokenID, &next); err != nil {
+		if errors.Is(err, store.ErrStatusChanged) {
+			// Someone else rotated this token between our read and write.
+			return store.User{}, TokenPair{}, s.revokeReusedFamily(ctx, current)
+		}
+		return store.User{}, TokenPair{}, err
+	}
+
+	tokens, err := s.pair(ctx, user, rotated, current.MFA)
+	return user, tokens, err
+}
+
+// Logout revokes the access token identified by jti and, when given, the
+// family of the caller's refresh token.
+func (s *Sessions) Logout(ctx context.Context, userID int, jti string, accessExpiresAt time.Time, refreshToken string) error {
+	if err := s.tokens.DenyAccessToken(ctx, jti, accessExpiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	current, err := s.tokens.GetRefreshToken(ctx, HashToken(refreshToken))
+	if errors.Is(err, store.ErrNotFound) || (err == nil && current.UserID != userID) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.tokens.RevokeRefreshFamily(ctx, current.FamilyID)
+}
+
+// Verify parses an access token and rejects it if it has been revoked, its
+// user has since been disabled or deleted, or its user's role has changed
+// since it was issued, so that embedded permissions never outlive the role.
+func (s *Sessions) Verify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims, err := ExtractToken(s.keys, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("token has no id")
+	}
+	if typ, ok := claims["typ"]; ok && typ != tokenTypeAccess {
+		return nil, errors.New("not an access token")
+	}
+
+	denied, err := s.tokens.IsAccessTokenDenied(ctx, jti)
+	if err != nil {
+		return nil, err
+	}
+	if denied {
+		return nil, ErrTokenRevoked
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		userID, _ = claims["id"].(float64)
+	}
+	user, err := s.users.GetByID(ctx, int(userID))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrTokenRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, ErrTokenRevoked
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	if role, ok := claims["role"].(string); ok && role != user.Role {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Permissions returns what the verified access token claims allow. Tokens
+// issued before permissions were embedded fall back to the role's current
+// permissions.
+func (s *Sessions) Permissions(ctx context.Context, claims jwt.MapClaims) (Permissions, error) {
+	if permissions, ok := permissionsFromClaim(claims["perms"]); ok {
+		return permissions, nil
+	}
+
+	role, _ := claims["role"].(string)
+	return s.rolePermissions(ctx, role)
+}
+
+func (s *Sessions) rolePermissions(ctx context.Context, name string) (Permissions, error) {
+	role, err := s.roles.Get(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return Permissions{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Permissions(role.Permissions), nil
+}
+
+// RunCleanup deletes expired refresh tokens and deny-list entries every
+// interval until ctx is cancelled.
+func (s *Sessions) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.tokens.DeleteExpired(ctx, now); err != nil {
+				slog.Error("Failed to delete expired tokens", "error", err)
+			}
+		}
+	}
+}
+
+func (s *Sessions) revokeReusedFamily(ctx context.Context, token store.RefreshToken) error {
+	slog.Warn("Refresh token reuse detected, revoking token family", "user_id", token.UserID, "family_id", token.FamilyID)
+	if err := s.tokens.RevokeRefreshFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (s *Sessions) newRefreshToken(userID int, familyID string, mfa bool) (string, store.RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", store.RefreshToken{}, err
+	}
+
+	return raw, store.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(raw),
+		MFA:       mfa,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}, nil
+}
+
+// pair embeds the role's permissions in the access token, so a change to
+// a role or a user's role applies from the next refresh.
+func (s *Sessions) pair(ctx context.Context, user store.User, refreshToken string, mfa bool) (TokenPair, error) {
+	permissions, err := s.rolePermissions(ctx, user.Role)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	accessToken, err := GenerateJWT(s.keys, s.accessTTL, user.UserID, user.Email, user.Role, jwt.MapClaims{
+		"typ":   tokenTypeAccess,
+		"mfa":   mfa,
+		"perms": permissions,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTTL,
+	}, nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token. Opaque tokens are
+// random enough that a fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewOpaqueToken returns a random token to hand out and the hash to store.
+func NewOpaqueToken() (string, string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return raw, HashToken(raw), nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
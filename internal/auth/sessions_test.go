@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+func TestVerifyRejectsTokenAfterRoleChange(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	users := memory.NewUserRepository(db)
+
+	user := store.User{Username: "player", Email: "player@example.com", Password: "x", Role: "user"}
+	if err := users.Create(ctx, &user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	keys, err := auth.NewHMACKeySet("test secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := auth.NewSessions(keys, 15*time.Minute, time.Hour, users, memory.NewTokenRepository(db), memory.NewRoleRepository(db))
+
+	pair, err := sessions.Start(ctx, user, false)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := sessions.Verify(ctx, pair.AccessToken); err != nil {
+		t.Fatalf("verify before role change: %v", err)
+	}
+
+	if err := users.SetRole(ctx, user.UserID, "admin"); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	if _, err := sessions.Verify(ctx, pair.AccessToken); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Fatalf("verify after role change = %v, want ErrTokenRevoked", err)
+	}
+}
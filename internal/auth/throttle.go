@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+type ThrottlePolicy struct {
+	// AccountMaxFailures and IPMaxFailures are how many failures within
+	// Window lock the account or IP address out for Lockout.
+	AccountMaxFailures int
+	IPMaxFailures      int
+	Window             time.Duration
+	Lockout            time.Duration
+	// MaxDelay caps the wait between attempts on one account, which
+	// doubles with every failure after the second.
+	MaxDelay time.Duration
+}
+
+// LoginThrottle slows down and then locks out repeated failed logins, per
+// account and per IP address.
+type LoginThrottle struct {
+	attempts store.LoginAttemptRepository
+	policy   ThrottlePolicy
+}
+
+func NewLoginThrottle(attempts store.LoginAttemptRepository, policy ThrottlePolicy) *LoginThrottle {
+	return &LoginThrottle{attempts: attempts, policy: policy}
+}
+
+// Attempt reserves a login attempt for email from ip, or returns how long
+// the caller must wait first. The attempt counts as a failure until Success
+// takes it back, so parallel guesses are limited like sequential ones.
+func (t *LoginThrottle) Attempt(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := time.Now()
+	account, address := accountKey(email), ipKey(ip)
+
+	counters, ok, err := t.attempts.Attempt(ctx, []string{account, address}, now, t.policy.Window, func(key string, failures int) time.Duration {
+		switch {
+		case key == account && failures >= t.policy.AccountMaxFailures, key == address && failures >= t.policy.IPMaxFailures:
+			return t.policy.Lockout
+		case key == account:
+			// Only the account is slowed down between attempts; many users
+			// can share an IP address, so it is only locked once it reaches
+			// its limit.
+			return t.delay(failures)
+		}
+		return 0
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		var wait time.Duration
+		for _, counter := range counters {
+			if counter.LockedUntil != nil && counter.LockedUntil.After(now) {
+				wait = max(wait, counter.LockedUntil.Sub(now))
+			}
+		}
+		return wait, nil
+	}
+
+	for _, limit := range []struct {
+		counter     store.LoginCounter
+		maxFailures int
+	}{
+		{counters[0], t.policy.AccountMaxFailures},
+		{counters[1], t.policy.IPMaxFailures},
+	} {
+		if limit.counter.Failures >= limit.maxFailures {
+			slog.Warn("Login locked out after repeated failures", "key", limit.counter.Key, "failures", limit.counter.Failures, "ip", ip, "locked_until", limit.counter.LockedUntil)
+		}
+	}
+
+	return 0, nil
+}
+
+// Success takes back an attempt that turned out to be right and clears
+// the account's failures. The IP address keeps its earlier failures, so
+// one valid account cannot be used to reset an attacker's budget.
+func (t *LoginThrottle) Success(ctx context.Context, email, ip string) error {
+	if err := t.attempts.Forgive(ctx, ipKey(ip)); err != nil {
+		return err
+	}
+	return t.attempts.Reset(ctx, accountKey(email))
+}
+
+// Unlock lifts an account lockout and clears its failures.
+func (t *LoginThrottle) Unlock(ctx context.Context, email string) error {
+	return t.attempts.Reset(ctx, accountKey(email))
+}
+
+// RunCleanup deletes counters that are no longer relevant every interval
+// until ctx is cancelled.
+func (t *LoginThrottle) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := t.attempts.DeleteStale(ctx, now.Add(-t.policy.Window)); err != nil {
+				slog.Error("Failed to delete stale login counters", "error", err)
+			}
+		}
+	}
+}
+
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if failures < 3 {
+		return 0
+	}
+	if failures-3 >= 30 {
+		return t.policy.MaxDelay
+	}
+	return min(time.Second<<(failures-3), t.policy.MaxDelay)
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -1,14 +1,29 @@
 package bookings
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/payments"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/promos"
+	"take-home-test/internal/store"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
 )
 
-func CreateBookingHandler(db *sql.DB) fiber.Handler {
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+func CreateBookingHandler(bookings store.BookingRepository, fieldRepo store.FieldRepository, promoCodes store.PromoRepository, pricer *pricing.Service, holdTTL time.Duration) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userID, ok := c.Locals("user_id").(int)
 		if !ok {
@@ -22,6 +37,7 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 			BookingDate string `json:"booking_date"`
 			StartTime   string `json:"start_time"`
 			EndTime     string `json:"end_time"`
+			PromoCode   string `json:"promo_code"`
 		}
 
 		if err := c.BodyParser(&req); err != nil {
@@ -43,21 +59,22 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		startTime, err := time.Parse("15:04", req.StartTime)
-		if err != nil {
+		startTime, ok := fields.ParseClock(req.StartTime)
+		if !ok {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid start time format. Use HH:MM",
 			})
 		}
 
-		endTime, err := time.Parse("15:04", req.EndTime)
-		if err != nil {
+		// Bookings may end at 24:00 when the field closes at midnight.
+		endTime, ok := fields.ParseClock(req.EndTime)
+		if !ok {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid end time format. Use HH:MM",
 			})
 		}
 
-		if endTime.Before(startTime) || endTime.Equal(startTime) {
+		if endTime <= startTime {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "End time must be after start time",
 			})
@@ -66,7 +83,7 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 		now := time.Now()
 		bookingDateTime := time.Date(
 			bookingDate.Year(), bookingDate.Month(), bookingDate.Day(),
-			startTime.Hour(), startTime.Minute(), 0, 0, time.Local,
+			0, startTime, 0, 0, time.Local,
 		)
 		if bookingDateTime.Before(now) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -74,10 +91,14 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		var pricePerHour int
-		err = db.QueryRow("SELECT price_per_hour FROM fields WHERE field_id = $1", req.FieldID).Scan(&pricePerHour)
+		// Normalise the inputs so stored values compare consistently.
+		req.BookingDate = bookingDate.Format("2006-01-02")
+		req.StartTime = fields.FormatClock(startTime)
+		req.EndTime = fields.FormatClock(endTime)
+
+		field, err := fieldRepo.Get(c.UserContext(), req.FieldID)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 					"error": "Field not found",
 				})
@@ -87,7 +108,19 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		isAvailable, err := checkTimeAvailability(db, req.FieldID, req.BookingDate, req.StartTime, req.EndTime)
+		if err := fields.CheckBooking(c.UserContext(), fieldRepo, field, bookingDate, req.StartTime, req.EndTime); err != nil {
+			var slotErr *fields.SlotError
+			if errors.As(err, &slotErr) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": slotErr.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check opening hours: " + err.Error(),
+			})
+		}
+
+		isAvailable, err := bookings.IsAvailable(c.UserContext(), req.FieldID, req.BookingDate, req.StartTime, req.EndTime)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to check availability: " + err.Error(),
@@ -99,57 +132,455 @@ func CreateBookingHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		duration := endTime.Sub(startTime).Hours()
-		totalPrice := int(duration * float64(pricePerHour))
+		duration := float64(endTime-startTime) / 60
+		quote, err := pricer.Quote(c.UserContext(), field, bookingDate, req.StartTime, req.EndTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to price booking: " + err.Error(),
+			})
+		}
 
-		var bookingID int
-		err = db.QueryRow(`
-			INSERT INTO bookings (user_id, field_id, booking_date, start_time, end_time, total_price, status)
-			VALUES ($1, $2, $3, $4, $5, $6, 'pending')
-			RETURNING booking_id
-		`, userID, req.FieldID, req.BookingDate, req.StartTime, req.EndTime, totalPrice).Scan(&bookingID)
+		discount := 0
+		req.PromoCode = strings.ToUpper(strings.TrimSpace(req.PromoCode))
+		if req.PromoCode != "" {
+			promo, err := promoCodes.GetByCode(c.UserContext(), req.PromoCode)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": "Invalid promo code",
+					})
+				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check promo code: " + err.Error(),
+				})
+			}
 
-		if err != nil {
+			discount, err = promos.Discount(promo, req.FieldID, quote.Total, now)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+		}
+
+		expiresAt := time.Now().Add(holdTTL)
+		booking := store.Booking{
+			UserID:         userID,
+			FieldID:        req.FieldID,
+			BookingDate:    req.BookingDate,
+			StartTime:      req.StartTime,
+			EndTime:        req.EndTime,
+			TotalPrice:     quote.Total - discount,
+			PriceBreakdown: quote.Segments,
+			DiscountAmount: discount,
+			PromoCode:      req.PromoCode,
+			Status:         store.BookingPending,
+			ExpiresAt:      &expiresAt,
+		}
+		if err := bookings.Create(c.UserContext(), &booking); err != nil {
+			if errors.Is(err, store.ErrSlotUnavailable) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Field is already booked at the selected time",
+				})
+			}
+			if errors.Is(err, store.ErrPromoUnavailable) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Promo code is no longer valid",
+				})
+			}
+			if errors.Is(err, store.ErrPromoExhausted) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Promo code has been fully redeemed",
+				})
+			}
+			if errors.Is(err, store.ErrPromoUserLimit) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "You have already used this promo code the maximum number of times",
+				})
+			}
+			// The code was deleted after it was looked up.
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid promo code",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create booking: " + err.Error(),
 			})
 		}
 
-		var fieldName, fieldLocation string
-		db.QueryRow("SELECT name, location FROM fields WHERE field_id = $1", req.FieldID).Scan(&fieldName, &fieldLocation)
-
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 			"message": "Booking created successfully",
 			"booking": fiber.Map{
-				"booking_id":   bookingID,
-				"field_id":     req.FieldID,
-				"field_name":   fieldName,
-				"location":     fieldLocation,
-				"booking_date": req.BookingDate,
-				"start_time":   req.StartTime,
-				"end_time":     req.EndTime,
-				"duration":     fmt.Sprintf("%.1f hours", duration),
-				"total_price":  totalPrice,
-				"status":       "pending",
+				"booking_id":      booking.BookingID,
+				"field_id":        req.FieldID,
+				"field_name":      field.Name,
+				"location":        field.Location,
+				"booking_date":    req.BookingDate,
+				"start_time":      req.StartTime,
+				"end_time":        req.EndTime,
+				"duration":        fmt.Sprintf("%.1f hours", duration),
+				"subtotal":        quote.Total,
+				"discount_amount": booking.DiscountAmount,
+				"promo_code":      booking.PromoCode,
+				"total_price":     booking.TotalPrice,
+				"price_breakdown": quote.Segments,
+				"status":          booking.Status,
+				"expires_at":      expiresAt,
 			},
 		})
 	}
 }
 
-func checkTimeAvailability(db *sql.DB, fieldID int, bookingDate, startTime, endTime string) (bool, error) {
-	var count int
-	err := db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM bookings 
-		WHERE field_id = $1 
-		AND booking_date = $2 
-		AND (status = 'pending' or status = 'paid')
-		AND (start_time, end_time) OVERLAPS ($3::time, $4::time)
-	`, fieldID, bookingDate, startTime, endTime).Scan(&count)
-
-	if err != nil {
-		return false, err
+func ListBookingsHandler(bookings store.BookingRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+
+		filter := store.BookingFilter{
+			UserID: userID,
+			Status: c.Query("status"),
+		}
+
+		// Staff see everyone's bookings, optionally narrowed to one user.
+		if permissions, _ := c.Locals("permissions").(auth.Permissions); permissions.Has(auth.PermBookingsReadAll) {
+			filter.UserID = c.QueryInt("user_id", 0)
+		}
+
+		if filter.Status != "" && !store.IsBookingStatus(filter.Status) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid status filter",
+			})
+		}
+
+		for param, target := range map[string]*string{"from": &filter.DateFrom, "to": &filter.DateTo} {
+			raw := c.Query(param)
+			if raw == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", raw); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid %s date format. Use YYYY-MM-DD", param),
+				})
+			}
+			*target = raw
+		}
+
+		sortBy := c.Query("sort", store.BookingSortDate)
+		if strings.HasPrefix(sortBy, "-") {
+			filter.SortDesc = true
+			sortBy = strings.TrimPrefix(sortBy, "-")
+		}
+		switch sortBy {
+		case store.BookingSortDate, store.BookingSortCreatedAt, store.BookingSortTotalPrice:
+			filter.SortBy = sortBy
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid sort. Use booking_date, created_at or total_price, prefixed with - for descending",
+			})
+		}
+
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", defaultPageSize)
+		if page < 1 || limit < 1 || limit > maxPageSize {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid pagination. page must be >= 1 and limit between 1 and %d", maxPageSize),
+			})
+		}
+		filter.Limit = limit
+		filter.Offset = (page - 1) * limit
+
+		list, total, err := bookings.List(c.UserContext(), filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch bookings: " + err.Error(),
+			})
+		}
+
+		result := make([]fiber.Map, 0, len(list))
+		for _, booking := range list {
+			result = append(result, bookingResponse(booking))
+		}
+
+		return c.JSON(fiber.Map{
+			"message":  "Bookings retrieved successfully",
+			"bookings": result,
+			"pagination": fiber.Map{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": (total + limit - 1) / limit,
+			},
+		})
 	}
+}
 
-	return count == 0, nil
+func GetBookingHandler(bookings store.BookingRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking ID",
+			})
+		}
+
+		booking, err := bookings.Get(c.UserContext(), id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch booking: " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Booking not found",
+			})
+		}
+		permissions, _ := c.Locals("permissions").(auth.Permissions)
+		if booking.UserID != userID && !permissions.Has(auth.PermBookingsReadAll) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You do not have access to this booking",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Booking retrieved successfully",
+			"booking": bookingResponse(booking),
+		})
+	}
+}
+
+// SlotReleaser is told when a booking gives up its slot before it starts,
+// so the slot can be offered to someone waiting for it.
+type SlotReleaser interface {
+	Released(fieldID int, date string)
+}
+
+func CancelBookingHandler(bookings store.BookingRepository, paymentRepo store.PaymentRepository, gateway payments.PaymentGateway, policy RefundPolicy, releaser SlotReleaser) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+		permissions, _ := c.Locals("permissions").(auth.Permissions)
+
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking ID",
+			})
+		}
+
+		booking, err := bookings.Get(c.UserContext(), id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch booking: " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Booking not found",
+			})
+		}
+		if booking.UserID != userID && !permissions.Has(auth.PermBookingsCancelAll) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You do not have access to this booking",
+			})
+		}
+
+		if !store.CanTransition(booking.Status, store.BookingCancelled) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Cannot cancel booking with status: %s", booking.Status),
+			})
+		}
+
+		startsAt, err := startOf(booking)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read booking time: " + err.Error(),
+			})
+		}
+		notice := time.Until(startsAt)
+		if notice <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot cancel a booking that has already started",
+			})
+		}
+
+		refund, err := cancelBooking(c.UserContext(), bookings, paymentRepo, gateway, policy, booking, notice)
+		if err != nil {
+			if errors.Is(err, store.ErrStatusChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Booking was updated by another request, please retry",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to cancel booking: " + err.Error(),
+			})
+		}
+		releaser.Released(booking.FieldID, booking.BookingDate)
+
+		booking, err = bookings.Get(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch cancelled booking: " + err.Error(),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Booking cancelled successfully",
+			"booking": bookingResponse(booking),
+			"refund":  refund.response(),
+		})
+	}
+}
+
+type refundResult struct {
+	Percent int
+	Amount  int
+	// Status is none, refunded, manual or failed.
+	Status string
+}
+
+func (r refundResult) response() fiber.Map {
+	return fiber.Map{
+		"percent": r.Percent,
+		"amount":  r.Amount,
+		"status":  r.Status,
+	}
+}
+
+// cancelBooking cancels booking, notice ahead of its start, and refunds
+// what policy allows if it was paid. A failed refund is logged rather than
+// returned, since the booking is cancelled either way.
+func cancelBooking(ctx context.Context, bookings store.BookingRepository, paymentRepo store.PaymentRepository, gateway payments.PaymentGateway, policy RefundPolicy, booking store.Booking, notice time.Duration) (refundResult, error) {
+	// Only money that was actually paid can be refunded. A reschedule may
+	// have moved the price away from what was paid.
+	refund := refundResult{Status: "none"}
+	if booking.Status == store.BookingPaid {
+		refund.Amount, refund.Percent = policy.Refund(booking.TotalPrice-booking.PriceAdjustment, notice)
+	}
+
+	if err := bookings.Cancel(ctx, booking.BookingID, booking.Status, refund.Amount); err != nil {
+		return refundResult{}, err
+	}
+
+	if refund.Amount > 0 {
+		_, err := payments.Refund(ctx, paymentRepo, gateway, booking.BookingID, refund.Amount)
+		switch {
+		case err == nil:
+			refund.Status = "refunded"
+		case errors.Is(err, store.ErrNotFound):
+			// Paid before payments went through a provider.
+			slog.Warn("No provider payment to refund, refund must be issued manually", "booking_id", booking.BookingID, "amount", refund.Amount)
+			refund.Status = "manual"
+		default:
+			slog.Error("Failed to refund cancelled booking", "booking_id", booking.BookingID, "amount", refund.Amount, "error", err)
+			refund.Status = "failed"
+		}
+	}
+
+	return refund, nil
+}
+
+// startOf returns when booking starts, in local time.
+func startOf(booking store.Booking) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02 15:04", booking.BookingDate+" "+booking.StartTime, time.Local)
+}
+
+// CheckInBookingHandler lets venue staff record that the players of a paid
+// booking arrived. Bookings can only be checked in on their date.
+func CheckInBookingHandler(bookings store.BookingRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking ID",
+			})
+		}
+
+		booking, err := bookings.Get(c.UserContext(), id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch booking: " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Booking not found",
+			})
+		}
+
+		if booking.CheckedInAt != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Booking is already checked in",
+			})
+		}
+		if booking.Status != store.BookingPaid {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Cannot check in booking with status: %s", booking.Status),
+			})
+		}
+		now := time.Now()
+		if booking.BookingDate != now.Format("2006-01-02") {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Bookings can only be checked in on the day they are for",
+			})
+		}
+
+		if err := bookings.CheckIn(c.UserContext(), id, now); err != nil {
+			if errors.Is(err, store.ErrStatusChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Booking was updated by another request, please retry",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check in booking: " + err.Error(),
+			})
+		}
+		booking.CheckedInAt = &now
+
+		return c.JSON(fiber.Map{
+			"message": "Booking checked in successfully",
+			"booking": bookingResponse(booking),
+		})
+	}
+}
+
+func bookingResponse(booking store.Booking) fiber.Map {
+	return fiber.Map{
+		"booking_id":       booking.BookingID,
+		"field_id":         booking.FieldID,
+		"field_name":       booking.FieldName,
+		"location":         booking.Location,
+		"booking_date":     booking.BookingDate,
+		"start_time":       booking.StartTime,
+		"end_time":         booking.EndTime,
+		"subtotal":         booking.TotalPrice + booking.DiscountAmount,
+		"discount_amount":  booking.DiscountAmount,
+		"promo_code":       booking.PromoCode,
+		"total_price":      booking.TotalPrice,
+		"price_breakdown":  booking.PriceBreakdown,
+		"status":           booking.Status,
+		"refund_amount":    booking.RefundAmount,
+		"cancelled_at":     booking.CancelledAt,
+		"expires_at":       booking.ExpiresAt,
+		"checked_in_at":    booking.CheckedInAt,
+		"series_id":        booking.SeriesID,
+		"price_adjustment": booking.PriceAdjustment,
+		"reschedule_count": booking.RescheduleCount,
+		"created_at":       booking.CreatedAt,
+	}
 }
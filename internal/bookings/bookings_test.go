@@ -0,0 +1,480 @@
+package bookings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/payments"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// releases records the slots handed to SlotReleaser.
+type releases []string
+
+func (r *releases) Released(fieldID int, date string) {
+	*r = append(*r, fmt.Sprintf("%d/%s", fieldID, date))
+}
+
+type testServer struct {
+	app      *fiber.App
+	bookings *memory.BookingRepository
+	promos   *memory.PromoRepository
+	payments *memory.PaymentRepository
+	field    store.Field
+	fields   *memory.FieldRepository
+	released releases
+}
+
+// newTestServer serves the booking handlers from in-memory repositories.
+// Requests authenticate with the X-User-ID and X-Permissions headers in
+// place of a token.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db := memory.NewDB()
+	s := &testServer{bookings: memory.NewBookingRepository(db), promos: memory.NewPromoRepository(db)}
+	fieldRepo := memory.NewFieldRepository(db)
+	s.fields = fieldRepo
+	paymentRepo := memory.NewPaymentRepository(db)
+	s.payments = paymentRepo
+	pricer := pricing.NewService(memory.NewPricingRuleRepository(db))
+
+	s.field = store.Field{Name: "Court 1", PricePerHour: 100000, Location: "Jakarta"}
+	if err := fieldRepo.Create(context.Background(), &s.field); err != nil {
+		t.Fatalf("create field: %v", err)
+	}
+
+	policy, err := ParseRefundPolicy("48h:100,24h:50,0s:0")
+	if err != nil {
+		t.Fatalf("parse refund policy: %v", err)
+	}
+
+	s.app = fiber.New()
+	s.app.Use(func(c *fiber.Ctx) error {
+		userID, _ := strconv.Atoi(c.Get("X-User-ID"))
+		c.Locals("user_id", userID)
+		c.Locals("permissions", auth.Permissions(strings.Split(c.Get("X-Permissions"), ",")))
+		return c.Next()
+	})
+	s.app.Post("/bookings", CreateBookingHandler(s.bookings, fieldRepo, s.promos, pricer, 15*time.Minute))
+	s.app.Get("/bookings", ListBookingsHandler(s.bookings))
+	s.app.Post("/bookings/:id/cancel", CancelBookingHandler(s.bookings, paymentRepo, payments.NewMockGateway(), policy, &s.released))
+	s.app.Post("/payments", payments.UpdatePayment(s.bookings, paymentRepo, payments.NewMockGateway(), "IDR"))
+	s.app.Patch("/bookings/:id", RescheduleBookingHandler(s.bookings, fieldRepo, s.promos, pricer, ReschedulePolicy{Cutoff: time.Hour, MaxReschedules: 3}, &s.released))
+	s.app.Get("/fields/:id/availability", fields.GetAvailabilityHandler(fieldRepo, s.bookings))
+	s.app.Post("/bookings/recurring", CreateRecurringBookingHandler(s.bookings, fieldRepo, pricer, 15*time.Minute))
+	s.app.Post("/bookings/series/:id/pay", PaySeriesHandler(s.bookings, paymentRepo, payments.NewMockGateway(), "IDR"))
+
+	return s
+}
+
+// do sends a request as userID and decodes the JSON response.
+func (s *testServer) do(t *testing.T, method, path string, userID int, body string, permissions ...string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", strconv.Itoa(userID))
+	req.Header.Set("X-Permissions", strings.Join(permissions, ","))
+
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+
+	var result map[string]any
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("%s %s: decode %q: %v", method, path, raw, err)
+	}
+	return resp.StatusCode, result
+}
+
+func (s *testServer) book(t *testing.T, userID int, date, start, end string) (int, map[string]any) {
+	t.Helper()
+	body := fmt.Sprintf(`{"field_id":%d,"booking_date":%q,"start_time":%q,"end_time":%q}`, s.field.FieldID, date, start, end)
+	return s.do(t, "POST", "/bookings", userID, body)
+}
+
+func bookingID(t *testing.T, result map[string]any) int {
+	t.Helper()
+	booking, ok := result["booking"].(map[string]any)
+	if !ok {
+		t.Fatalf("response has no booking: %v", result)
+	}
+	return int(booking["booking_id"].(float64))
+}
+
+// futureDate is a date far enough ahead for the full refund tier.
+func futureDate() string {
+	return time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+}
+
+func TestCreateBooking(t *testing.T) {
+	s := newTestServer(t)
+	date := futureDate()
+
+	status, result := s.book(t, 1, date, "10:00", "12:00")
+	if status != fiber.StatusCreated {
+		t.Fatalf("create: status %d, body %v", status, result)
+	}
+	booking := result["booking"].(map[string]any)
+	if booking["status"] != store.BookingPending || booking["total_price"] != float64(200000) {
+		t.Errorf("create: got status %v and total %v, want pending and 200000", booking["status"], booking["total_price"])
+	}
+
+	tests := []struct {
+		name       string
+		date       string
+		start, end string
+		want       int
+	}{
+		{"overlapping", date, "11:00", "13:00", fiber.StatusConflict},
+		{"adjacent", date, "12:00", "13:00", fiber.StatusCreated},
+		{"end before start", date, "15:00", "14:00", fiber.StatusBadRequest},
+		{"ends at midnight", date, "23:00", "24:00", fiber.StatusCreated},
+		{"starts at midnight", date, "24:00", "24:30", fiber.StatusBadRequest},
+		{"invalid date", "07-01-2030", "10:00", "11:00", fiber.StatusBadRequest},
+		{"in the past", "2020-01-01", "10:00", "11:00", fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, result := s.book(t, 2, tt.date, tt.start, tt.end)
+			if status != tt.want {
+				t.Errorf("status %d, want %d; body %v", status, tt.want, result)
+			}
+		})
+	}
+
+	status, _ = s.do(t, "POST", "/bookings", 1, `{"field_id":99,"booking_date":"`+date+`","start_time":"10:00","end_time":"11:00"}`)
+	if status != fiber.StatusNotFound {
+		t.Errorf("unknown field: status %d, want 404", status)
+	}
+}
+
+func TestListBookings(t *testing.T) {
+	s := newTestServer(t)
+	date := futureDate()
+
+	for _, slot := range [][2]string{{"08:00", "09:00"}, {"10:00", "11:00"}} {
+		if status, result := s.book(t, 1, date, slot[0], slot[1]); status != fiber.StatusCreated {
+			t.Fatalf("create: status %d, body %v", status, result)
+		}
+	}
+	if status, result := s.book(t, 2, date, "12:00", "13:00"); status != fiber.StatusCreated {
+		t.Fatalf("create: status %d, body %v", status, result)
+	}
+
+	count := func(result map[string]any) int {
+		return len(result["bookings"].([]any))
+	}
+
+	status, result := s.do(t, "GET", "/bookings", 1, "")
+	if status != fiber.StatusOK || count(result) != 2 {
+		t.Errorf("own bookings: status %d, %d bookings, want 200 and 2", status, count(result))
+	}
+
+	// user_id is ignored without bookings:read_all.
+	_, result = s.do(t, "GET", "/bookings?user_id=2", 1, "")
+	if count(result) != 2 {
+		t.Errorf("player filtering by another user: %d bookings, want their own 2", count(result))
+	}
+
+	_, result = s.do(t, "GET", "/bookings?user_id=2", 3, "", auth.PermBookingsReadAll)
+	if count(result) != 1 {
+		t.Errorf("admin filtering by user: %d bookings, want 1", count(result))
+	}
+
+	_, result = s.do(t, "GET", "/bookings?limit=1", 1, "")
+	pagination := result["pagination"].(map[string]any)
+	if count(result) != 1 || pagination["total"] != float64(2) || pagination["total_pages"] != float64(2) {
+		t.Errorf("paged: %d bookings, pagination %v, want 1 of 2 on 2 pages", count(result), pagination)
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	s := newTestServer(t)
+	date := futureDate()
+
+	_, result := s.book(t, 1, date, "10:00", "11:00")
+	id := bookingID(t, result)
+	path := fmt.Sprintf("/bookings/%d/cancel", id)
+
+	if status, _ := s.do(t, "POST", path, 2, ""); status != fiber.StatusForbidden {
+		t.Errorf("cancel by another player: status %d, want 403", status)
+	}
+
+	status, result := s.do(t, "POST", path, 1, "")
+	if status != fiber.StatusOK {
+		t.Fatalf("cancel: status %d, body %v", status, result)
+	}
+	if got := result["booking"].(map[string]any)["status"]; got != store.BookingCancelled {
+		t.Errorf("cancel: booking status %v, want cancelled", got)
+	}
+	if got := result["refund"].(map[string]any)["status"]; got != "none" {
+		t.Errorf("cancel unpaid: refund status %v, want none", got)
+	}
+	if want := fmt.Sprintf("%d/%s", s.field.FieldID, date); len(s.released) != 1 || s.released[0] != want {
+		t.Errorf("released %v, want [%s]", s.released, want)
+	}
+
+	if status, _ := s.do(t, "POST", path, 1, ""); status != fiber.StatusConflict {
+		t.Errorf("second cancel: status %d, want 409", status)
+	}
+
+	// The freed slot can be booked again.
+	if status, result := s.book(t, 2, date, "10:00", "11:00"); status != fiber.StatusCreated {
+		t.Errorf("rebook freed slot: status %d, body %v", status, result)
+	}
+}
+
+func TestCancelPaidBookingRefunds(t *testing.T) {
+	s := newTestServer(t)
+
+	paid := store.Booking{
+		UserID:      1,
+		FieldID:     s.field.FieldID,
+		BookingDate: futureDate(),
+		StartTime:   "10:00",
+		EndTime:     "11:00",
+		TotalPrice:  100000,
+		Status:      store.BookingPaid,
+	}
+	if err := s.bookings.Create(context.Background(), &paid); err != nil {
+		t.Fatalf("create paid booking: %v", err)
+	}
+
+	status, result := s.do(t, "POST", fmt.Sprintf("/bookings/%d/cancel", paid.BookingID), 3, "", auth.PermBookingsCancelAll)
+	if status != fiber.StatusOK {
+		t.Fatalf("cancel: status %d, body %v", status, result)
+	}
+	refund := result["refund"].(map[string]any)
+	// No provider payment exists, so the refund is left to be made by hand.
+	if refund["percent"] != float64(100) || refund["amount"] != float64(100000) || refund["status"] != "manual" {
+		t.Errorf("refund %v, want 100%% of 100000, manual", refund)
+	}
+}
+
+func TestPayFreeBooking(t *testing.T) {
+	s := newTestServer(t)
+	promo := store.PromoCode{Code: "FREE", DiscountType: store.DiscountPercent, DiscountValue: 100, Active: true}
+	if err := s.promos.Create(context.Background(), &promo); err != nil {
+		t.Fatalf("create promo: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"field_id":%d,"booking_date":%q,"start_time":"10:00","end_time":"11:00","promo_code":"free"}`, s.field.FieldID, futureDate())
+	status, result := s.do(t, "POST", "/bookings", 1, body)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create: status %d, body %v", status, result)
+	}
+	id := bookingID(t, result)
+
+	status, result = s.do(t, "POST", "/payments", 1, fmt.Sprintf(`{"booking_id":%d}`, id))
+	if status != fiber.StatusOK {
+		t.Fatalf("pay: status %d, body %v", status, result)
+	}
+	if booking := result["booking"].(map[string]any); booking["status"] != store.BookingPaid {
+		t.Errorf("pay: booking status %v, want paid", booking["status"])
+	}
+}
+
+func TestCreateBookingPromoDeactivated(t *testing.T) {
+	s := newTestServer(t)
+	promo := store.PromoCode{Code: "HALF", DiscountType: store.DiscountPercent, DiscountValue: 50}
+	if err := s.promos.Create(context.Background(), &promo); err != nil {
+		t.Fatalf("create promo: %v", err)
+	}
+
+	// The handler checked the code while it was active; it was switched
+	// off before the booking was stored.
+	booking := store.Booking{
+		UserID: 1, FieldID: s.field.FieldID, BookingDate: futureDate(), StartTime: "10:00", EndTime: "11:00",
+		TotalPrice: 50000, DiscountAmount: 50000, PromoCode: "HALF", Status: store.BookingPending,
+	}
+	if err := s.bookings.Create(context.Background(), &booking); !errors.Is(err, store.ErrPromoUnavailable) {
+		t.Fatalf("create = %v, want ErrPromoUnavailable", err)
+	}
+}
+
+func TestPaySeries(t *testing.T) {
+	s := newTestServer(t)
+	start := time.Now().AddDate(0, 0, 7)
+
+	body := fmt.Sprintf(`{"field_id":%d,"start_date":%q,"start_time":"10:00","end_time":"11:00","rrule":"FREQ=WEEKLY;COUNT=3"}`,
+		s.field.FieldID, start.Format("2006-01-02"))
+	status, result := s.do(t, "POST", "/bookings/recurring", 1, body)
+	if status != fiber.StatusCreated {
+		t.Fatalf("create series: status %d, body %v", status, result)
+	}
+	seriesID := int(result["series"].(map[string]any)["series_id"].(float64))
+	path := fmt.Sprintf("/bookings/series/%d/pay", seriesID)
+
+	if status, result := s.do(t, "POST", path, 2, ""); status != fiber.StatusForbidden {
+		t.Errorf("pay as another user: status %d, body %v", status, result)
+	}
+
+	status, result = s.do(t, "POST", path, 1, "")
+	if status != fiber.StatusOK {
+		t.Fatalf("pay: status %d, body %v", status, result)
+	}
+	if n := len(result["payments"].([]any)); n != 3 || result["total_price"] != float64(300000) {
+		t.Errorf("pay: %d payments totalling %v, want 3 totalling 300000", n, result["total_price"])
+	}
+
+	list, _, err := s.bookings.List(context.Background(), store.BookingFilter{SeriesID: seriesID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range list {
+		if b.Status != store.BookingPaid {
+			t.Errorf("booking %d on %s is %s, want paid", b.BookingID, b.BookingDate, b.Status)
+		}
+	}
+
+	if status, result := s.do(t, "POST", path, 1, ""); status != fiber.StatusConflict {
+		t.Errorf("pay twice: status %d, body %v", status, result)
+	}
+}
+
+func TestPayBookingWithPaymentInProgress(t *testing.T) {
+	s := newTestServer(t)
+	status, result := s.book(t, 1, futureDate(), "10:00", "11:00")
+	if status != fiber.StatusCreated {
+		t.Fatalf("create: status %d, body %v", status, result)
+	}
+	id := bookingID(t, result)
+
+	// A capture whose outcome is still unknown.
+	pending := store.Payment{BookingID: id, Provider: "mock", ProviderRef: "pi_unknown", Amount: 100000, Currency: "IDR", Status: store.PaymentPending}
+	if err := s.payments.Create(context.Background(), &pending); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+
+	status, result = s.do(t, "POST", "/payments", 1, fmt.Sprintf(`{"booking_id":%d}`, id))
+	if status != fiber.StatusConflict {
+		t.Fatalf("pay: status %d, body %v", status, result)
+	}
+	list, err := s.payments.ListByBooking(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("booking has %d payments, want 1", len(list))
+	}
+}
+
+func TestRescheduleReappliesPromo(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	other := store.Field{Name: "Court 2", PricePerHour: 100000, Location: "Jakarta"}
+	if err := s.fields.Create(ctx, &other); err != nil {
+		t.Fatalf("create field: %v", err)
+	}
+	for _, promo := range []store.PromoCode{
+		{Code: "HALF", DiscountType: store.DiscountPercent, DiscountValue: 50, Active: true},
+		{Code: "COURT1", DiscountType: store.DiscountFixed, DiscountValue: 10000, FieldIDs: []int{s.field.FieldID}, Active: true},
+		{Code: "BIG", DiscountType: store.DiscountFixed, DiscountValue: 10000, MinSpend: 150000, Active: true},
+	} {
+		if err := s.promos.Create(ctx, &promo); err != nil {
+			t.Fatalf("create promo: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		promo      string
+		start, end string
+		move       string
+		want       int
+		// wantDiscount and wantTotal are checked when the move succeeds.
+		wantDiscount, wantTotal float64
+	}{
+		{"percent follows the new price", "HALF", "10:00", "11:00", `{"end_time":"12:00"}`, fiber.StatusOK, 100000, 100000},
+		{"field the promo excludes", "COURT1", "10:00", "11:00", fmt.Sprintf(`{"field_id":%d}`, other.FieldID), fiber.StatusBadRequest, 0, 0},
+		{"below the minimum spend", "BIG", "10:00", "12:00", `{"end_time":"11:00"}`, fiber.StatusBadRequest, 0, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date := time.Now().AddDate(0, 0, 7+i).Format("2006-01-02")
+			body := fmt.Sprintf(`{"field_id":%d,"booking_date":%q,"start_time":%q,"end_time":%q,"promo_code":%q}`,
+				s.field.FieldID, date, tt.start, tt.end, tt.promo)
+			status, result := s.do(t, "POST", "/bookings", 1, body)
+			if status != fiber.StatusCreated {
+				t.Fatalf("create: status %d, body %v", status, result)
+			}
+			id := bookingID(t, result)
+
+			status, result = s.do(t, "PATCH", fmt.Sprintf("/bookings/%d", id), 1, tt.move)
+			if status != tt.want {
+				t.Fatalf("reschedule: status %d, want %d, body %v", status, tt.want, result)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			booking := result["booking"].(map[string]any)
+			if booking["discount_amount"] != tt.wantDiscount || booking["total_price"] != tt.wantTotal {
+				t.Errorf("reschedule: discount %v and total %v, want %v and %v",
+					booking["discount_amount"], booking["total_price"], tt.wantDiscount, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// freeSlots returns the start and end of every slot that availability
+// lists as free on date.
+func (s *testServer) freeSlots(t *testing.T, date string) [][2]string {
+	t.Helper()
+
+	path := fmt.Sprintf("/fields/%d/availability?from=%s&to=%s", s.field.FieldID, date, date)
+	status, result := s.do(t, "GET", path, 1, "")
+	if status != fiber.StatusOK {
+		t.Fatalf("availability: status %d, body %v", status, result)
+	}
+
+	var free [][2]string
+	day := result["days"].([]any)[0].(map[string]any)
+	for _, raw := range day["slots"].([]any) {
+		slot := raw.(map[string]any)
+		if slot["status"] == "free" {
+			free = append(free, [2]string{slot["start"].(string), slot["end"].(string)})
+		}
+	}
+	return free
+}
+
+func TestFreeSlotsCanBeBooked(t *testing.T) {
+	s := newTestServer(t)
+	// One slot is then exactly one bookable booking.
+	if err := s.fields.SetRules(context.Background(), s.field.FieldID, store.SlotRules{SlotMinutes: 60, MinMinutes: 60, MaxMinutes: 240}); err != nil {
+		t.Fatalf("set rules: %v", err)
+	}
+	date := futureDate()
+
+	free := s.freeSlots(t, date)
+	if len(free) == 0 || free[len(free)-1][1] != "24:00" {
+		t.Fatalf("free slots %v, want some up to 24:00", free)
+	}
+	for _, slot := range free {
+		if status, result := s.book(t, 1, date, slot[0], slot[1]); status != fiber.StatusCreated {
+			t.Errorf("book free slot %s-%s: status %d, body %v", slot[0], slot[1], status, result)
+		}
+	}
+
+	if left := s.freeSlots(t, date); len(left) != 0 {
+		t.Errorf("free slots after booking every one: %v", left)
+	}
+}
@@ -0,0 +1,155 @@
+package bookings
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence is a weekly pattern, written as a subset of an iCalendar
+// RRULE: FREQ=WEEKLY with an optional INTERVAL and BYDAY, and either COUNT
+// or UNTIL, such as FREQ=WEEKLY;BYDAY=TU;COUNT=10.
+type Recurrence struct {
+	Interval int
+	// Weekdays defaults to the weekday of the first date.
+	Weekdays []time.Weekday
+	Count    int
+	// Until is the last date an occurrence may fall on, or zero when Count
+	// is used.
+	Until time.Time
+}
+
+var byDayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRecurrence parses rule, returning why it is invalid or "" if not.
+func ParseRecurrence(rule string) (Recurrence, string) {
+	r := Recurrence{Interval: 1}
+	freq := ""
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Sprintf("Invalid rule part %q", part)
+		}
+
+		switch key {
+		case "FREQ":
+			freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return r, "INTERVAL must be a positive number"
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return r, "COUNT must be a positive number"
+			}
+			r.Count = n
+		case "UNTIL":
+			// Only the date matters; a time such as T235959Z is ignored.
+			date := strings.ReplaceAll(value, "-", "")
+			if len(date) > 8 {
+				date = date[:8]
+			}
+			until, err := time.ParseInLocation("20060102", date, time.Local)
+			if err != nil {
+				return r, "UNTIL must be a date such as 20300630"
+			}
+			r.Until = until
+		case "BYDAY":
+			seen := make(map[time.Weekday]bool)
+			for _, code := range strings.Split(value, ",") {
+				day, ok := byDayCodes[code]
+				if !ok {
+					return r, fmt.Sprintf("Invalid BYDAY day %q. Use MO, TU, WE, TH, FR, SA or SU", code)
+				}
+				if !seen[day] {
+					seen[day] = true
+					r.Weekdays = append(r.Weekdays, day)
+				}
+			}
+		default:
+			return r, fmt.Sprintf("Unsupported rule part %s", key)
+		}
+	}
+
+	if freq != "WEEKLY" {
+		return r, "Only FREQ=WEEKLY is supported"
+	}
+	if (r.Count == 0) == r.Until.IsZero() {
+		return r, "Give exactly one of COUNT or UNTIL"
+	}
+
+	// Weeks start on Monday, as in iCalendar.
+	sort.Slice(r.Weekdays, func(i, j int) bool {
+		return mondayFirst(r.Weekdays[i]) < mondayFirst(r.Weekdays[j])
+	})
+	return r, ""
+}
+
+// String returns the rule in its normal form.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=WEEKLY"}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		codes := make([]string, 0, len(r.Weekdays))
+		for _, day := range r.Weekdays {
+			codes = append(codes, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	} else {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Dates returns the dates the pattern falls on from start onwards. It
+// stops and returns false once there would be more than limit.
+func (r Recurrence) Dates(start time.Time, limit int) ([]time.Time, bool) {
+	weekdays := r.Weekdays
+	if len(weekdays) == 0 {
+		weekdays = []time.Weekday{start.Weekday()}
+	}
+	weekStart := start.AddDate(0, 0, -mondayFirst(start.Weekday()))
+
+	var dates []time.Time
+	for week := weekStart; ; week = week.AddDate(0, 0, 7*r.Interval) {
+		for _, day := range weekdays {
+			date := week.AddDate(0, 0, mondayFirst(day))
+			if date.Before(start) {
+				continue
+			}
+			if (r.Count > 0 && len(dates) == r.Count) || (!r.Until.IsZero() && date.After(r.Until)) {
+				return dates, true
+			}
+			if len(dates) == limit {
+				return dates, false
+			}
+			dates = append(dates, date)
+		}
+	}
+}
+
+func mondayFirst(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
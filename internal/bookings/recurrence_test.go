@@ -0,0 +1,131 @@
+package bookings
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    string
+		wantMsg string
+	}{
+		{rule: "FREQ=WEEKLY;COUNT=10", want: "FREQ=WEEKLY;COUNT=10"},
+		{rule: "rrule:freq=weekly;byday=fr,mo,fr;count=4", want: "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=4"},
+		{rule: "FREQ=WEEKLY;BYDAY=SU,SA,MO;COUNT=3", want: "FREQ=WEEKLY;BYDAY=MO,SA,SU;COUNT=3"},
+		{rule: "FREQ=WEEKLY;INTERVAL=1;UNTIL=2030-06-30", want: "FREQ=WEEKLY;UNTIL=20300630"},
+		{rule: "FREQ=WEEKLY;INTERVAL=2;UNTIL=20300630T235959Z", want: "FREQ=WEEKLY;INTERVAL=2;UNTIL=20300630"},
+		{rule: "FREQ=DAILY;COUNT=3", wantMsg: "Only FREQ=WEEKLY is supported"},
+		{rule: "COUNT=3", wantMsg: "Only FREQ=WEEKLY is supported"},
+		{rule: "FREQ=WEEKLY", wantMsg: "Give exactly one of COUNT or UNTIL"},
+		{rule: "FREQ=WEEKLY;COUNT=3;UNTIL=20300630", wantMsg: "Give exactly one of COUNT or UNTIL"},
+		{rule: "FREQ=WEEKLY;INTERVAL=0;COUNT=3", wantMsg: "INTERVAL must be a positive number"},
+		{rule: "FREQ=WEEKLY;COUNT=-1", wantMsg: "COUNT must be a positive number"},
+		{rule: "FREQ=WEEKLY;UNTIL=soon", wantMsg: "UNTIL must be a date such as 20300630"},
+		{rule: "FREQ=WEEKLY;BYDAY=MO,XX;COUNT=3", wantMsg: `Invalid BYDAY day "XX". Use MO, TU, WE, TH, FR, SA or SU`},
+		{rule: "FREQ=WEEKLY;BYMONTH=1;COUNT=3", wantMsg: "Unsupported rule part BYMONTH"},
+		{rule: "FREQ=WEEKLY;COUNT", wantMsg: `Invalid rule part "COUNT"`},
+	}
+	for _, tt := range tests {
+		r, msg := ParseRecurrence(tt.rule)
+		if msg != tt.wantMsg {
+			t.Errorf("ParseRecurrence(%q) message = %q, want %q", tt.rule, msg, tt.wantMsg)
+			continue
+		}
+		if msg == "" && r.String() != tt.want {
+			t.Errorf("ParseRecurrence(%q) = %s, want %s", tt.rule, r, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceDates(t *testing.T) {
+	date := func(day int) time.Time {
+		return time.Date(2024, 6, day, 0, 0, 0, 0, time.Local)
+	}
+	// 3 June 2024 is a Monday.
+	monday, wednesday := date(3), date(5)
+
+	tests := []struct {
+		name   string
+		rule   string
+		start  time.Time
+		limit  int
+		want   []time.Time
+		wantOK bool
+	}{
+		{
+			name:  "weekday of the start",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: wednesday, limit: 10,
+			want:   []time.Time{date(5), date(12), date(19)},
+			wantOK: true,
+		},
+		{
+			name:  "interval",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			start: monday, limit: 10,
+			want:   []time.Time{date(3), date(17), date(31)},
+			wantOK: true,
+		},
+		{
+			name:  "byday in any order",
+			rule:  "FREQ=WEEKLY;BYDAY=FR,MO;COUNT=4",
+			start: monday, limit: 10,
+			want:   []time.Time{date(3), date(7), date(10), date(14)},
+			wantOK: true,
+		},
+		{
+			name:  "start is not a byday day",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+			start: wednesday, limit: 10,
+			want:   []time.Time{date(6), date(10), date(13)},
+			wantOK: true,
+		},
+		{
+			name:  "interval counts from the week of the start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=3",
+			start: wednesday, limit: 10,
+			want:   []time.Time{date(6), date(17), date(20)},
+			wantOK: true,
+		},
+		{
+			name:  "until is inclusive",
+			rule:  "FREQ=WEEKLY;BYDAY=TU;UNTIL=20240618",
+			start: monday, limit: 10,
+			want:   []time.Time{date(4), date(11), date(18)},
+			wantOK: true,
+		},
+		{
+			name:  "until before the start",
+			rule:  "FREQ=WEEKLY;UNTIL=20240601",
+			start: monday, limit: 10,
+			wantOK: true,
+		},
+		{
+			name:  "count over the limit",
+			rule:  "FREQ=WEEKLY;COUNT=5",
+			start: monday, limit: 3,
+			want:   []time.Time{date(3), date(10), date(17)},
+			wantOK: false,
+		},
+		{
+			name:  "until over the limit",
+			rule:  "FREQ=WEEKLY;UNTIL=20301231",
+			start: monday, limit: 2,
+			want:   []time.Time{date(3), date(10)},
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		r, msg := ParseRecurrence(tt.rule)
+		if msg != "" {
+			t.Fatalf("%s: ParseRecurrence(%q): %s", tt.name, tt.rule, msg)
+		}
+		got, ok := r.Dates(tt.start, tt.limit)
+		if !reflect.DeepEqual(got, tt.want) || ok != tt.wantOK {
+			t.Errorf("%s: Dates = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package bookings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/payments"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxOccurrences caps a series at a year of weekly bookings.
+const maxOccurrences = 52
+
+// Series modes decide what happens when some occurrences cannot be booked.
+const (
+	seriesAllOrNothing  = "all_or_nothing"
+	seriesSkipConflicts = "skip_conflicts"
+)
+
+// CreateRecurringBookingHandler books the same slot on every date of an
+// RRULE. Each occurrence is checked like a single booking. With
+// all_or_nothing, the default, any conflict rejects the whole series; with
+// skip_conflicts the free dates are booked and the rest reported.
+func CreateRecurringBookingHandler(bookings store.BookingRepository, fieldRepo store.FieldRepository, pricer *pricing.Service, holdTTL time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+
+		var req struct {
+			FieldID   int    `json:"field_id"`
+			StartDate string `json:"start_date"`
+			StartTime string `json:"start_time"`
+			EndTime   string `json:"end_time"`
+			RRule     string `json:"rrule"`
+			Mode      string `json:"mode"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+
+		if req.FieldID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+		if req.Mode == "" {
+			req.Mode = seriesAllOrNothing
+		}
+		if req.Mode != seriesAllOrNothing && req.Mode != seriesSkipConflicts {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Mode must be all_or_nothing or skip_conflicts",
+			})
+		}
+
+		startDate, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start date format. Use YYYY-MM-DD",
+			})
+		}
+		startTime, ok := fields.ParseClock(req.StartTime)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start time format. Use HH:MM",
+			})
+		}
+		// Bookings may end at 24:00 when the field closes at midnight.
+		endTime, ok := fields.ParseClock(req.EndTime)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid end time format. Use HH:MM",
+			})
+		}
+		if endTime <= startTime {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End time must be after start time",
+			})
+		}
+		req.StartTime = fields.FormatClock(startTime)
+		req.EndTime = fields.FormatClock(endTime)
+
+		recurrence, msg := ParseRecurrence(req.RRule)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid rrule: " + msg,
+			})
+		}
+		dates, ok := recurrence.Dates(startDate, maxOccurrences)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("A series can have at most %d occurrences", maxOccurrences),
+			})
+		}
+		if len(dates) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "The rule has no dates on or after start_date",
+			})
+		}
+		firstStart := time.Date(dates[0].Year(), dates[0].Month(), dates[0].Day(), 0, startTime, 0, 0, time.Local)
+		if firstStart.Before(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot book in the past",
+			})
+		}
+
+		field, err := fieldRepo.Get(c.UserContext(), req.FieldID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check field: " + err.Error(),
+			})
+		}
+
+		expiresAt := time.Now().Add(holdTTL)
+		var occurrences []store.Booking
+		conflicts := []fiber.Map{}
+		for _, date := range dates {
+			bookingDate := date.Format("2006-01-02")
+
+			reason, err := occurrenceConflict(c, bookings, fieldRepo, field, date, req.StartTime, req.EndTime)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check availability: " + err.Error(),
+				})
+			}
+			if reason != "" {
+				conflicts = append(conflicts, fiber.Map{
+					"booking_date": bookingDate,
+					"reason":       reason,
+				})
+				continue
+			}
+
+			quote, err := pricer.Quote(c.UserContext(), field, date, req.StartTime, req.EndTime)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to price booking: " + err.Error(),
+				})
+			}
+
+			occurrences = append(occurrences, store.Booking{
+				UserID:         userID,
+				FieldID:        field.FieldID,
+				BookingDate:    bookingDate,
+				StartTime:      req.StartTime,
+				EndTime:        req.EndTime,
+				TotalPrice:     quote.Total,
+				PriceBreakdown: quote.Segments,
+				Status:         store.BookingPending,
+				ExpiresAt:      &expiresAt,
+			})
+		}
+
+		if len(conflicts) > 0 && (req.Mode == seriesAllOrNothing || len(occurrences) == 0) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":     "Some dates of the series cannot be booked",
+				"conflicts": conflicts,
+			})
+		}
+
+		series := store.BookingSeries{
+			UserID:    userID,
+			FieldID:   field.FieldID,
+			Rule:      recurrence.String(),
+			StartDate: startDate.Format("2006-01-02"),
+			StartTime: req.StartTime,
+			EndTime:   req.EndTime,
+		}
+		if err := bookings.CreateSeries(c.UserContext(), &series, occurrences); err != nil {
+			if errors.Is(err, store.ErrSlotUnavailable) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A date of the series was booked by another request, please retry",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create booking series: " + err.Error(),
+			})
+		}
+
+		result := []fiber.Map{}
+		total := 0
+		for _, b := range occurrences {
+			b.FieldName, b.Location = field.Name, field.Location
+			result = append(result, bookingResponse(b))
+			total += b.TotalPrice
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message":     "Booking series created successfully",
+			"series":      seriesResponse(series),
+			"bookings":    result,
+			"skipped":     conflicts,
+			"total_price": total,
+		})
+	}
+}
+
+// occurrenceConflict returns why the slot on date cannot be booked, or ""
+// if it can.
+func occurrenceConflict(c *fiber.Ctx, bookings store.BookingRepository, fieldRepo store.FieldRepository, field store.Field, date time.Time, start, end string) (string, error) {
+	if err := fields.CheckBooking(c.UserContext(), fieldRepo, field, date, start, end); err != nil {
+		var slotErr *fields.SlotError
+		if errors.As(err, &slotErr) {
+			return slotErr.Error(), nil
+		}
+		return "", err
+	}
+
+	isAvailable, err := bookings.IsAvailable(c.UserContext(), field.FieldID, date.Format("2006-01-02"), start, end)
+	if err != nil {
+		return "", err
+	}
+	if !isAvailable {
+		return "Field is already booked at the selected time", nil
+	}
+	return "", nil
+}
+
+func GetSeriesHandler(bookings store.BookingRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		series, ok, err := ownSeries(c, bookings, auth.PermBookingsReadAll)
+		if !ok {
+			return err
+		}
+
+		list, _, err := bookings.List(c.UserContext(), store.BookingFilter{SeriesID: series.SeriesID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch bookings: " + err.Error(),
+			})
+		}
+
+		result := []fiber.Map{}
+		for _, b := range list {
+			result = append(result, bookingResponse(b))
+		}
+
+		return c.JSON(fiber.Map{
+			"message":  "Booking series retrieved successfully",
+			"series":   seriesResponse(series),
+			"bookings": result,
+		})
+	}
+}
+
+// CancelSeriesHandler cancels every occurrence of a series that has not
+// started yet, refunding paid ones under the same policy as a single
+// cancellation. One occurrence is cancelled with POST /bookings/:id/cancel.
+func CancelSeriesHandler(bookings store.BookingRepository, paymentRepo store.PaymentRepository, gateway payments.PaymentGateway, policy RefundPolicy, releaser SlotReleaser) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		series, ok, err := ownSeries(c, bookings, auth.PermBookingsCancelAll)
+		if !ok {
+			return err
+		}
+
+		list, _, err := bookings.List(c.UserContext(), store.BookingFilter{SeriesID: series.SeriesID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch bookings: " + err.Error(),
+			})
+		}
+
+		cancelled := []fiber.Map{}
+		refunded := 0
+		for _, b := range list {
+			if !store.CanTransition(b.Status, store.BookingCancelled) {
+				continue
+			}
+			startsAt, err := startOf(b)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to read booking time: " + err.Error(),
+				})
+			}
+			notice := time.Until(startsAt)
+			if notice <= 0 {
+				continue
+			}
+
+			refund, err := cancelBooking(c.UserContext(), bookings, paymentRepo, gateway, policy, b, notice)
+			if errors.Is(err, store.ErrStatusChanged) {
+				// Paid or cancelled meanwhile; leave it to a single cancel.
+				continue
+			}
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to cancel booking: " + err.Error(),
+				})
+			}
+			releaser.Released(b.FieldID, b.BookingDate)
+
+			refunded += refund.Amount
+			cancelled = append(cancelled, fiber.Map{
+				"booking_id":   b.BookingID,
+				"booking_date": b.BookingDate,
+				"refund":       refund.response(),
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":       "Booking series cancelled successfully",
+			"series":        seriesResponse(series),
+			"cancelled":     cancelled,
+			"refund_amount": refunded,
+		})
+	}
+}
+
+// PaySeriesHandler pays for every occurrence of the caller's series that
+// is still held, one payment per occurrence, so that each can later be
+// cancelled and refunded on its own. Occurrences share the hold taken when
+// the series was booked, so they are paid together or expire together.
+func PaySeriesHandler(bookings store.BookingRepository, paymentRepo store.PaymentRepository, gateway payments.PaymentGateway, currency string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		series, ok, err := ownSeries(c, bookings, auth.PermBookingsReadAll)
+		if !ok {
+			return err
+		}
+		if userID, _ := c.Locals("user_id").(int); series.UserID != userID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You can only pay for your own bookings",
+			})
+		}
+
+		list, _, err := bookings.List(c.UserContext(), store.BookingFilter{SeriesID: series.SeriesID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch bookings: " + err.Error(),
+			})
+		}
+
+		now := time.Now()
+		var due []store.Booking
+		for _, b := range list {
+			if store.CanTransition(b.Status, store.BookingPaid) && store.HoldsSlot(b, now) {
+				due = append(due, b)
+			}
+		}
+		if len(due) == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Booking series has no held bookings to pay for",
+			})
+		}
+
+		paid := []fiber.Map{}
+		total := 0
+		for _, b := range due {
+			payment, err := payments.Pay(c.UserContext(), paymentRepo, gateway, currency, b)
+			if errors.Is(err, store.ErrAlreadyExists) {
+				// Already being paid through POST /payments.
+				continue
+			}
+			if err != nil && !errors.Is(err, payments.ErrOutcomeUnknown) {
+				// Stop at the first failure rather than retrying the provider
+				// for every remaining occurrence; what was paid stays paid.
+				status, message := fiber.StatusInternalServerError, "Failed to "+err.Error()
+				if errors.Is(err, payments.ErrProviderUnavailable) {
+					status, message = fiber.StatusBadGateway, "Payment provider is unavailable, please retry"
+				}
+				return c.Status(status).JSON(fiber.Map{
+					"error":       message,
+					"series":      seriesResponse(series),
+					"payments":    paid,
+					"total_price": total,
+				})
+			}
+
+			if payment.Status == store.PaymentSucceeded {
+				total += payment.Amount
+			}
+			paid = append(paid, fiber.Map{
+				"booking_id":     b.BookingID,
+				"booking_date":   b.BookingDate,
+				"payment_id":     payment.PaymentID,
+				"amount":         payment.Amount,
+				"status":         payment.Status,
+				"failure_reason": payment.FailureReason,
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":     "Booking series paid",
+			"series":      seriesResponse(series),
+			"payments":    paid,
+			"total_price": total,
+		})
+	}
+}
+
+// ownSeries loads the series in the id param if the caller made it or has
+// permission. When it returns false the response has been written.
+func ownSeries(c *fiber.Ctx, bookings store.BookingRepository, permission string) (store.BookingSeries, bool, error) {
+	userID, ok := c.Locals("user_id").(int)
+	if !ok {
+		return store.BookingSeries{}, false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not authenticated",
+		})
+	}
+	permissions, _ := c.Locals("permissions").(auth.Permissions)
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return store.BookingSeries{}, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid series ID",
+		})
+	}
+
+	series, err := bookings.GetSeries(c.UserContext(), id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return store.BookingSeries{}, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch booking series: " + err.Error(),
+		})
+	}
+	if err != nil {
+		return store.BookingSeries{}, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Booking series not found",
+		})
+	}
+	if series.UserID != userID && !permissions.Has(permission) {
+		return store.BookingSeries{}, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You do not have access to this booking series",
+		})
+	}
+
+	return series, true, nil
+}
+
+func seriesResponse(series store.BookingSeries) fiber.Map {
+	return fiber.Map{
+		"series_id":  series.SeriesID,
+		"user_id":    series.UserID,
+		"field_id":   series.FieldID,
+		"rrule":      series.Rule,
+		"start_date": series.StartDate,
+		"start_time": series.StartTime,
+		"end_time":   series.EndTime,
+		"created_at": series.CreatedAt,
+	}
+}
@@ -0,0 +1,67 @@
+package bookings
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefundTier refunds Percent of the paid amount when a booking is cancelled
+// at least MinNotice before it starts.
+type RefundTier struct {
+	MinNotice time.Duration
+	Percent   int
+}
+
+type RefundPolicy struct {
+	tiers []RefundTier
+}
+
+// ParseRefundPolicy parses a comma separated list of notice:percent pairs,
+// for example "48h:100,24h:50,0s:0". Notice periods use time.ParseDuration
+// syntax.
+func ParseRefundPolicy(spec string) (RefundPolicy, error) {
+	var tiers []RefundTier
+	for _, part := range strings.Split(spec, ",") {
+		rawNotice, rawPercent, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return RefundPolicy{}, fmt.Errorf("invalid refund tier %q, expected notice:percent", part)
+		}
+
+		notice, err := time.ParseDuration(rawNotice)
+		if err != nil || notice < 0 {
+			return RefundPolicy{}, fmt.Errorf("invalid refund notice %q", rawNotice)
+		}
+
+		percent, err := strconv.Atoi(rawPercent)
+		if err != nil || percent < 0 || percent > 100 {
+			return RefundPolicy{}, fmt.Errorf("invalid refund percent %q", rawPercent)
+		}
+
+		tiers = append(tiers, RefundTier{MinNotice: notice, Percent: percent})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinNotice > tiers[j].MinNotice
+	})
+
+	return RefundPolicy{tiers: tiers}, nil
+}
+
+// Percent returns the refund percentage for a cancellation made notice
+// before the booking starts. Notice below every tier refunds nothing.
+func (p RefundPolicy) Percent(notice time.Duration) int {
+	for _, tier := range p.tiers {
+		if notice >= tier.MinNotice {
+			return tier.Percent
+		}
+	}
+	return 0
+}
+
+func (p RefundPolicy) Refund(paidAmount int, notice time.Duration) (int, int) {
+	percent := p.Percent(notice)
+	return paidAmount * percent / 100, percent
+}
@@ -0,0 +1,275 @@
+package bookings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/promos"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReschedulePolicy limits when and how often a booking can be moved.
+type ReschedulePolicy struct {
+	// Cutoff is how long before it starts a booking can last be moved.
+	Cutoff         time.Duration
+	MaxReschedules int
+}
+
+// RescheduleBookingHandler moves a pending or paid booking to another
+// date, time or field in one step, so the old slot is only given up once
+// the new one is held. The booking is repriced; for a paid booking the
+// difference is recorded as price_adjustment, to be charged or credited.
+// A promo code on the booking is applied again to the new price; a move
+// the code does not cover is rejected.
+func RescheduleBookingHandler(bookings store.BookingRepository, fieldRepo store.FieldRepository, promoCodes store.PromoRepository, pricer *pricing.Service, policy ReschedulePolicy, releaser SlotReleaser) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+		permissions, _ := c.Locals("permissions").(auth.Permissions)
+
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking ID",
+			})
+		}
+
+		// Left out values keep the booking's current ones.
+		var req struct {
+			FieldID     int    `json:"field_id"`
+			BookingDate string `json:"booking_date"`
+			StartTime   string `json:"start_time"`
+			EndTime     string `json:"end_time"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+
+		booking, err := bookings.Get(c.UserContext(), id)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch booking: " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Booking not found",
+			})
+		}
+		if booking.UserID != userID && !permissions.Has(auth.PermBookingsRescheduleAll) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You do not have access to this booking",
+			})
+		}
+
+		now := time.Now()
+		held := booking.Status == store.BookingPaid ||
+			(booking.Status == store.BookingPending && (booking.ExpiresAt == nil || booking.ExpiresAt.After(now)))
+		if !held {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Cannot reschedule booking with status: %s", booking.Status),
+			})
+		}
+		if booking.RescheduleCount >= policy.MaxReschedules {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("A booking can be rescheduled at most %d times", policy.MaxReschedules),
+			})
+		}
+
+		startsAt, err := startOf(booking)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to read booking time: " + err.Error(),
+			})
+		}
+		if startsAt.Sub(now) < policy.Cutoff {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Bookings can only be rescheduled until %s before they start", policy.Cutoff),
+			})
+		}
+
+		moved := booking
+		if req.FieldID != 0 {
+			moved.FieldID = req.FieldID
+		}
+		if req.BookingDate != "" {
+			moved.BookingDate = req.BookingDate
+		}
+		if req.StartTime != "" {
+			moved.StartTime = req.StartTime
+		}
+		if req.EndTime != "" {
+			moved.EndTime = req.EndTime
+		}
+
+		bookingDate, err := time.ParseInLocation("2006-01-02", moved.BookingDate, time.Local)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking date format. Use YYYY-MM-DD",
+			})
+		}
+		startTime, ok := fields.ParseClock(moved.StartTime)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid start time format. Use HH:MM",
+			})
+		}
+		// Bookings may end at 24:00 when the field closes at midnight.
+		endTime, ok := fields.ParseClock(moved.EndTime)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid end time format. Use HH:MM",
+			})
+		}
+		if endTime <= startTime {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End time must be after start time",
+			})
+		}
+		moved.BookingDate = bookingDate.Format("2006-01-02")
+		moved.StartTime = fields.FormatClock(startTime)
+		moved.EndTime = fields.FormatClock(endTime)
+
+		if moved.FieldID == booking.FieldID && moved.BookingDate == booking.BookingDate &&
+			moved.StartTime == booking.StartTime && moved.EndTime == booking.EndTime {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "The booking is already at the requested time",
+			})
+		}
+		newStart, _ := startOf(moved)
+		if newStart.Before(now) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot book in the past",
+			})
+		}
+
+		field, err := fieldRepo.Get(c.UserContext(), moved.FieldID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check field: " + err.Error(),
+			})
+		}
+
+		if err := fields.CheckBooking(c.UserContext(), fieldRepo, field, bookingDate, moved.StartTime, moved.EndTime); err != nil {
+			var slotErr *fields.SlotError
+			if errors.As(err, &slotErr) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": slotErr.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check opening hours: " + err.Error(),
+			})
+		}
+
+		quote, err := pricer.Quote(c.UserContext(), field, bookingDate, moved.StartTime, moved.EndTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to price booking: " + err.Error(),
+			})
+		}
+
+		moved.DiscountAmount = 0
+		if booking.PromoCode != "" {
+			promo, err := promoCodes.GetByCode(c.UserContext(), booking.PromoCode)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": "The booking's promo code no longer exists, so it cannot be moved",
+					})
+				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to check promo code: " + err.Error(),
+				})
+			}
+
+			moved.DiscountAmount, err = promos.Discount(promo, moved.FieldID, quote.Total, now)
+			if err != nil {
+				var promoErr *promos.PromoError
+				if errors.As(err, &promoErr) {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error": "The booking's promo code does not cover the new time: " + promoErr.Error(),
+					})
+				}
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to apply promo code: " + err.Error(),
+				})
+			}
+		}
+		moved.TotalPrice = quote.Total - moved.DiscountAmount
+		moved.PriceBreakdown = quote.Segments
+		difference := moved.TotalPrice - booking.TotalPrice
+		if booking.Status == store.BookingPaid {
+			moved.PriceAdjustment += difference
+		}
+
+		change := store.BookingReschedule{ActorID: userID}
+		if err := bookings.Reschedule(c.UserContext(), &moved, booking.Status, &change); err != nil {
+			if errors.Is(err, store.ErrSlotUnavailable) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Field is already booked at the selected time",
+				})
+			}
+			if errors.Is(err, store.ErrStatusChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Booking was updated by another request, please retry",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to reschedule booking: " + err.Error(),
+			})
+		}
+		releaser.Released(change.FromFieldID, change.FromDate)
+
+		booking, err = bookings.Get(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch rescheduled booking: " + err.Error(),
+			})
+		}
+
+		// Pending bookings are simply charged the new price.
+		settlement := "none"
+		if booking.Status == store.BookingPaid && difference > 0 {
+			settlement = "charge"
+		} else if booking.Status == store.BookingPaid && difference < 0 {
+			settlement = "credit"
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Booking rescheduled successfully",
+			"booking": bookingResponse(booking),
+			"reschedule": fiber.Map{
+				"reschedule_id": change.RescheduleID,
+				"from": fiber.Map{
+					"field_id":     change.FromFieldID,
+					"booking_date": change.FromDate,
+					"start_time":   change.FromStart,
+					"end_time":     change.FromEnd,
+				},
+				"old_price":  change.OldPrice,
+				"new_price":  change.NewPrice,
+				"difference": difference,
+				"settlement": settlement,
+			},
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package bookings
+
+import (
+	"context"
+	"take-home-test/internal/store"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// RunSweeper applies the time-driven booking transitions every interval
+// until ctx is cancelled: lapsed pending holds expire and paid bookings
+// that have ended are completed.
+func RunSweeper(ctx context.Context, bookings store.BookingRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweep(ctx, bookings, now)
+		}
+	}
+}
+
+func sweep(ctx context.Context, bookings store.BookingRepository, now time.Time) {
+	expired, err := bookings.ExpirePending(ctx, now)
+	if err != nil {
+		slog.Error("Failed to expire pending bookings", "error", err)
+	} else if expired > 0 {
+		slog.Info("Expired pending bookings", "count", expired)
+	}
+
+	completed, err := bookings.CompleteFinished(ctx, now)
+	if err != nil {
+		slog.Error("Failed to complete finished bookings", "error", err)
+	} else if completed > 0 {
+		slog.Info("Completed finished bookings", "count", completed)
+	}
+}
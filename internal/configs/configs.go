@@ -5,14 +5,61 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/subosito/gotenv"
 )
 
 type Config struct {
 	AppConfig struct {
-		Port      int
-		JWTSecret string
+		Port            int
+		JWTAlgorithm    string
+		JWTSecret       string
+		JWTKeyDir       string
+		JWTSigningKeyID string
+		AccessTokenTTL  time.Duration
+		RefreshTokenTTL time.Duration
+		BootstrapToken  string
+		MFAIssuer       string
+		RequireAdminMFA bool
+	}
+	BookingConfig struct {
+		RefundPolicy  string
+		HoldTTL       time.Duration
+		SweepInterval time.Duration
+		// RescheduleCutoff is how long before it starts a booking can
+		// last be rescheduled.
+		RescheduleCutoff time.Duration
+		MaxReschedules   int
+		// WaitlistOfferTTL is how long a freed slot is held for the user
+		// it is offered to.
+		WaitlistOfferTTL time.Duration
+	}
+	PaymentConfig struct {
+		Provider         string
+		Currency         string
+		WebhookSecret    string
+		WebhookTolerance time.Duration
+	}
+	LoginConfig struct {
+		AccountMaxFailures int
+		IPMaxFailures      int
+		FailureWindow      time.Duration
+		Lockout            time.Duration
+		MaxDelay           time.Duration
+	}
+	MailConfig struct {
+		Driver          string
+		LogFile         string
+		From            string
+		SMTPHost        string
+		SMTPPort        int
+		SMTPUsername    string
+		SMTPPassword    string
+		BaseURL         string
+		VerifyTTL       time.Duration
+		ResetTTL        time.Duration
+		RequireVerified bool
 	}
 	PostgresConfig struct {
 		Host     string
@@ -32,7 +79,34 @@ func InitConfig() (*Config, error) {
 		return nil, err
 	}
 
-	if cfg.AppConfig.JWTSecret, err = getEnv("JWT_SECRET"); err != nil {
+	cfg.AppConfig.JWTAlgorithm = getEnvDefault("JWT_ALGORITHM", "HS256")
+
+	switch cfg.AppConfig.JWTAlgorithm {
+	case "HS256":
+		if cfg.AppConfig.JWTSecret, err = getEnv("JWT_SECRET"); err != nil {
+			return nil, err
+		}
+	case "RS256", "EdDSA":
+		if cfg.AppConfig.JWTKeyDir, err = getEnv("JWT_KEY_DIR"); err != nil {
+			return nil, err
+		}
+		cfg.AppConfig.JWTSigningKeyID = getEnvDefault("JWT_SIGNING_KEY_ID", "")
+	default:
+		return nil, fmt.Errorf("invalid JWT_ALGORITHM %q, expected HS256, RS256 or EdDSA", cfg.AppConfig.JWTAlgorithm)
+	}
+
+	if cfg.AppConfig.AccessTokenTTL, err = getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.AppConfig.RefreshTokenTTL, err = getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	cfg.AppConfig.BootstrapToken = getEnvDefault("ADMIN_BOOTSTRAP_TOKEN", "")
+	cfg.AppConfig.MFAIssuer = getEnvDefault("MFA_ISSUER", "Take Home Test")
+
+	if cfg.AppConfig.RequireAdminMFA, err = getEnvBool("REQUIRE_ADMIN_MFA", false); err != nil {
 		return nil, err
 	}
 
@@ -56,6 +130,88 @@ func InitConfig() (*Config, error) {
 		return nil, err
 	}
 
+	cfg.BookingConfig.RefundPolicy = getEnvDefault("REFUND_POLICY", "48h:100,24h:50,0s:0")
+
+	if cfg.BookingConfig.HoldTTL, err = getEnvDuration("BOOKING_HOLD_TTL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.BookingConfig.SweepInterval, err = getEnvDuration("BOOKING_SWEEP_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.BookingConfig.RescheduleCutoff, err = getEnvDuration("RESCHEDULE_CUTOFF", 24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	if cfg.BookingConfig.MaxReschedules, err = getEnvIntDefault("MAX_RESCHEDULES", 2); err != nil {
+		return nil, err
+	}
+
+	if cfg.BookingConfig.WaitlistOfferTTL, err = getEnvDuration("WAITLIST_OFFER_TTL", 30*time.Minute); err != nil {
+		return nil, err
+	}
+
+	cfg.PaymentConfig.Provider = getEnvDefault("PAYMENT_PROVIDER", "mock")
+	cfg.PaymentConfig.Currency = getEnvDefault("PAYMENT_CURRENCY", "IDR")
+	cfg.PaymentConfig.WebhookSecret = getEnvDefault("PAYMENT_WEBHOOK_SECRET", "")
+
+	if cfg.PaymentConfig.WebhookTolerance, err = getEnvDuration("PAYMENT_WEBHOOK_TOLERANCE", 5*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginConfig.AccountMaxFailures, err = getEnvIntDefault("LOGIN_MAX_FAILURES", 5); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginConfig.IPMaxFailures, err = getEnvIntDefault("LOGIN_IP_MAX_FAILURES", 50); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginConfig.FailureWindow, err = getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginConfig.Lockout, err = getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute); err != nil {
+		return nil, err
+	}
+
+	if cfg.LoginConfig.MaxDelay, err = getEnvDuration("LOGIN_MAX_DELAY", 30*time.Second); err != nil {
+		return nil, err
+	}
+
+	cfg.MailConfig.Driver = getEnvDefault("MAILER", "log")
+	cfg.MailConfig.LogFile = getEnvDefault("MAILER_LOG_FILE", "")
+	cfg.MailConfig.From = getEnvDefault("MAIL_FROM", "noreply@localhost")
+	cfg.MailConfig.BaseURL = strings.TrimRight(getEnvDefault("APP_BASE_URL", fmt.Sprintf("http://localhost:%d", cfg.AppConfig.Port)), "/")
+
+	switch cfg.MailConfig.Driver {
+	case "log":
+	case "smtp":
+		if cfg.MailConfig.SMTPHost, err = getEnv("SMTP_HOST"); err != nil {
+			return nil, err
+		}
+		if cfg.MailConfig.SMTPPort, err = getEnvInt("SMTP_PORT"); err != nil {
+			return nil, err
+		}
+		cfg.MailConfig.SMTPUsername = getEnvDefault("SMTP_USERNAME", "")
+		cfg.MailConfig.SMTPPassword = getEnvDefault("SMTP_PASSWORD", "")
+	default:
+		return nil, fmt.Errorf("invalid MAILER %q, expected log or smtp", cfg.MailConfig.Driver)
+	}
+
+	if cfg.MailConfig.VerifyTTL, err = getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	if cfg.MailConfig.ResetTTL, err = getEnvDuration("PASSWORD_RESET_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+
+	if cfg.MailConfig.RequireVerified, err = getEnvBool("REQUIRE_VERIFIED_EMAIL", false); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
@@ -67,6 +223,14 @@ func getEnv(key string) (string, error) {
 	return val, nil
 }
 
+func getEnvDefault(key string, fallback string) string {
+	val := strings.TrimSpace(os.Getenv(key))
+	if val == "" {
+		return fallback
+	}
+	return val
+}
+
 func getEnvInt(key string) (int, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -78,3 +242,39 @@ func getEnvInt(key string) (int, error) {
 	}
 	return v, nil
 }
+
+func getEnvIntDefault(key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid int for %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid duration for %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid bool for %s: %q", key, raw)
+	}
+	return v, nil
+}
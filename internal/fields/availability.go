@@ -0,0 +1,196 @@
+package fields
+
+import (
+	"errors"
+	"strconv"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultAvailabilityDays = 7
+	maxAvailabilityDays     = 31
+)
+
+const (
+	slotFree = "free"
+	slotBusy = "busy"
+	// slotPast marks slots that have already started.
+	slotPast = "past"
+)
+
+// GetAvailabilityHandler returns a day-by-day grid of a field's slots
+// between ?from and ?to, each free, busy or past. Busy slots come from
+// bookings that hold the field and from blackouts, and do not say which.
+func GetAvailabilityHandler(fields store.FieldRepository, bookings store.BookingRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		now := time.Now()
+		from, err := time.ParseInLocation("2006-01-02", c.Query("from", now.Format("2006-01-02")), time.Local)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from date format. Use YYYY-MM-DD",
+			})
+		}
+		to := from.AddDate(0, 0, defaultAvailabilityDays-1)
+		if raw := c.Query("to"); raw != "" {
+			to, err = time.ParseInLocation("2006-01-02", raw, time.Local)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid to date format. Use YYYY-MM-DD",
+				})
+			}
+		}
+		if to.Before(from) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "to must not be before from",
+			})
+		}
+		if to.Sub(from) >= maxAvailabilityDays*24*time.Hour {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Availability can be requested for at most 31 days at a time",
+			})
+		}
+		fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+
+		field, err := fields.Get(c.UserContext(), id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch field",
+			})
+		}
+
+		// Everything is loaded up front so the cost does not grow with the
+		// number of days.
+		hours, err := fields.Hours(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch opening hours",
+			})
+		}
+		exceptions, err := fields.Exceptions(c.UserContext(), id, fromDate, toDate)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch exceptions",
+			})
+		}
+		blackouts, err := fields.Blackouts(c.UserContext(), id, from, to.AddDate(0, 0, 1))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch blackouts",
+			})
+		}
+		busy, err := bookings.Busy(c.UserContext(), id, fromDate, toDate)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch bookings",
+			})
+		}
+
+		busyByDate := make(map[string][][2]int)
+		for _, b := range busy {
+			start, _ := ParseClock(b.StartTime)
+			end, _ := ParseClock(b.EndTime)
+			busyByDate[b.Date] = append(busyByDate[b.Date], [2]int{start, end})
+		}
+		byDate := exceptionsByDate(exceptions)
+
+		days := []fiber.Map{}
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			day := dayOf(date, hours, byDate)
+
+			taken := busyByDate[day.Date]
+			for _, b := range blackouts {
+				if start, end, ok := minutesOn(date, b.StartsAt, b.EndsAt); ok {
+					taken = append(taken, [2]int{start, end})
+				}
+			}
+
+			days = append(days, availabilityDay(day, field.Rules.SlotMinutes, taken, date, now))
+		}
+
+		return c.JSON(fiber.Map{
+			"message":  "Availability retrieved successfully",
+			"field_id": field.FieldID,
+			"from":     fromDate,
+			"to":       toDate,
+			"rules":    rulesResponse(field.Rules),
+			"days":     days,
+		})
+	}
+}
+
+func availabilityDay(day Day, slotMinutes int, taken [][2]int, date, now time.Time) fiber.Map {
+	slots := []fiber.Map{}
+	if day.Open {
+		// Slots line up with the boundaries bookings must use, so a field
+		// opening at 08:15 with 30-minute slots starts at 08:30.
+		first := (day.Opens + slotMinutes - 1) / slotMinutes * slotMinutes
+		for start := first; start+slotMinutes <= day.Closes; start += slotMinutes {
+			end := start + slotMinutes
+
+			status := slotFree
+			if clockTime(date, start).Before(now) {
+				status = slotPast
+			} else {
+				for _, t := range taken {
+					if t[0] < end && start < t[1] {
+						status = slotBusy
+						break
+					}
+				}
+			}
+
+			slots = append(slots, fiber.Map{
+				"start":  FormatClock(start),
+				"end":    FormatClock(end),
+				"status": status,
+			})
+		}
+	}
+
+	result := fiber.Map{
+		"date":  day.Date,
+		"open":  day.Open,
+		"slots": slots,
+	}
+	if day.Open {
+		result["opens"] = FormatClock(day.Opens)
+		result["closes"] = FormatClock(day.Closes)
+	}
+	if day.Reason != "" {
+		result["note"] = day.Reason
+	}
+	return result
+}
+
+// minutesOn returns the part of startsAt to endsAt that falls on date, in
+// minutes since local midnight.
+func minutesOn(date, startsAt, endsAt time.Time) (int, int, bool) {
+	dayStart, dayEnd := clockTime(date, 0), clockTime(date, 24*60)
+	if !startsAt.Before(dayEnd) || !endsAt.After(dayStart) {
+		return 0, 0, false
+	}
+
+	start, end := 0, 24*60
+	if startsAt.After(dayStart) {
+		start = int(startsAt.Sub(dayStart).Minutes())
+	}
+	if endsAt.Before(dayEnd) {
+		end = int(endsAt.Sub(dayStart).Minutes())
+	}
+	return start, end, true
+}
@@ -1,13 +1,14 @@
 package fields
 
 import (
-	"database/sql"
+	"errors"
 	"strconv"
+	"take-home-test/internal/store"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func CreateFieldHandler(db *sql.DB) fiber.Handler {
+func CreateFieldHandler(fields store.FieldRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req struct {
 			Name         string `json:"name"`
@@ -37,13 +38,12 @@ func CreateFieldHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		var fieldID int
-		err := db.QueryRow(
-			"INSERT INTO fields (name, price_per_hour, location) VALUES ($1, $2, $3) RETURNING field_id",
-			req.Name, req.PricePerHour, req.Location,
-		).Scan(&fieldID)
-
-		if err != nil {
+		field := store.Field{
+			Name:         req.Name,
+			PricePerHour: req.PricePerHour,
+			Location:     req.Location,
+		}
+		if err := fields.Create(c.UserContext(), &field); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create field",
 			})
@@ -51,65 +51,34 @@ func CreateFieldHandler(db *sql.DB) fiber.Handler {
 
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 			"message": "Field created successfully",
-			"field": fiber.Map{
-				"field_id":       fieldID,
-				"name":           req.Name,
-				"price_per_hour": req.PricePerHour,
-				"location":       req.Location,
-			},
+			"field":   fieldResponse(field),
 		})
 	}
 }
 
-func GetFieldsHandler(db *sql.DB) fiber.Handler {
+func GetFieldsHandler(fields store.FieldRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		rows, err := db.Query(`
-			SELECT field_id, name, price_per_hour, location 
-			FROM fields
-		`)
+		list, err := fields.List(c.UserContext())
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to fetch fields",
 			})
 		}
-		defer rows.Close()
 
-		var fields []fiber.Map
-		for rows.Next() {
-			var field struct {
-				FieldID      int
-				Name         string
-				PricePerHour int
-				Location     string
-			}
-			err := rows.Scan(
-				&field.FieldID,
-				&field.Name,
-				&field.PricePerHour,
-				&field.Location,
-			)
-			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to read field data",
-				})
-			}
-			fields = append(fields, fiber.Map{
-				"field_id":       field.FieldID,
-				"name":           field.Name,
-				"price_per_hour": field.PricePerHour,
-				"location":       field.Location,
-			})
+		var result []fiber.Map
+		for _, field := range list {
+			result = append(result, fieldResponse(field))
 		}
 
 		return c.JSON(fiber.Map{
 			"message": "Fields retrieved successfully",
-			"fields":  fields,
-			"count":   len(fields),
+			"fields":  result,
+			"count":   len(result),
 		})
 	}
 }
 
-func GetFieldHandler(db *sql.DB) fiber.Handler {
+func GetFieldHandler(fields store.FieldRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id, err := strconv.Atoi(c.Params("id"))
 		if err != nil {
@@ -118,26 +87,9 @@ func GetFieldHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		var field struct {
-			FieldID      int
-			Name         string
-			PricePerHour int
-			Location     string
-		}
-
-		err = db.QueryRow(`
-			SELECT field_id, name, price_per_hour, location 
-			FROM fields 
-			WHERE field_id = $1
-		`, id).Scan(
-			&field.FieldID,
-			&field.Name,
-			&field.PricePerHour,
-			&field.Location,
-		)
-
+		field, err := fields.Get(c.UserContext(), id)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 					"error": "Field not found",
 				})
@@ -149,17 +101,12 @@ func GetFieldHandler(db *sql.DB) fiber.Handler {
 
 		return c.JSON(fiber.Map{
 			"message": "Field retrieved successfully",
-			"field": fiber.Map{
-				"field_id":       field.FieldID,
-				"name":           field.Name,
-				"price_per_hour": field.PricePerHour,
-				"location":       field.Location,
-			},
+			"field":   fieldResponse(field),
 		})
 	}
 }
 
-func UpdateFieldHandler(db *sql.DB) fiber.Handler {
+func UpdateFieldHandler(fields store.FieldRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id, err := strconv.Atoi(c.Params("id"))
 		if err != nil {
@@ -196,43 +143,24 @@ func UpdateFieldHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		result, err := db.Exec(`
-			UPDATE fields 
-			SET name = $1, price_per_hour = $2, location = $3 
-			WHERE field_id = $4
-		`, req.Name, req.PricePerHour, req.Location, id)
-
+		err = fields.Update(c.UserContext(), store.Field{
+			FieldID:      id,
+			Name:         req.Name,
+			PricePerHour: req.PricePerHour,
+			Location:     req.Location,
+		})
 		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to update field",
 			})
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Field not found",
-			})
-		}
-
-		var field struct {
-			FieldID      int
-			Name         string
-			PricePerHour int
-			Location     string
-		}
-
-		err = db.QueryRow(`
-			SELECT field_id, name, price_per_hour, location 
-			FROM fields 
-			WHERE field_id = $1
-		`, id).Scan(
-			&field.FieldID,
-			&field.Name,
-			&field.PricePerHour,
-			&field.Location,
-		)
-
+		field, err := fields.Get(c.UserContext(), id)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to fetch updated field",
@@ -241,17 +169,12 @@ func UpdateFieldHandler(db *sql.DB) fiber.Handler {
 
 		return c.JSON(fiber.Map{
 			"message": "Field updated successfully",
-			"field": fiber.Map{
-				"field_id":       field.FieldID,
-				"name":           field.Name,
-				"price_per_hour": field.PricePerHour,
-				"location":       field.Location,
-			},
+			"field":   fieldResponse(field),
 		})
 	}
 }
 
-func DeleteFieldHandler(db *sql.DB) fiber.Handler {
+func DeleteFieldHandler(fields store.FieldRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id, err := strconv.Atoi(c.Params("id"))
 		if err != nil {
@@ -260,22 +183,29 @@ func DeleteFieldHandler(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		result, err := db.Exec("DELETE FROM fields WHERE field_id = $1", id)
-		if err != nil {
+		if err := fields.Delete(c.UserContext(), id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to delete field",
 			})
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Field not found",
-			})
-		}
-
 		return c.JSON(fiber.Map{
 			"message": "Field deleted successfully",
 		})
 	}
 }
+
+func fieldResponse(field store.Field) fiber.Map {
+	return fiber.Map{
+		"field_id":       field.FieldID,
+		"name":           field.Name,
+		"price_per_hour": field.PricePerHour,
+		"location":       field.Location,
+		"rules":          rulesResponse(field.Rules),
+	}
+}
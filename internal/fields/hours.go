@@ -0,0 +1,474 @@
+package fields
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultScheduleDays = 30
+
+// GetScheduleHandler returns a field's slot rules, weekly hours and the
+// exceptions and blackouts between ?from and ?to, by default the next 30
+// days.
+func GetScheduleHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		today := time.Now().Format("2006-01-02")
+		from, to := c.Query("from", today), c.Query("to")
+		fromDate, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from date format. Use YYYY-MM-DD",
+			})
+		}
+		toDate := fromDate.AddDate(0, 0, defaultScheduleDays)
+		if to == "" {
+			to = toDate.Format("2006-01-02")
+		} else if toDate, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to date format. Use YYYY-MM-DD",
+			})
+		}
+
+		field, err := fields.Get(c.UserContext(), id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch field",
+			})
+		}
+
+		hours, err := fields.Hours(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch opening hours",
+			})
+		}
+		exceptions, err := fields.Exceptions(c.UserContext(), id, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch exceptions",
+			})
+		}
+		blackouts, err := fields.Blackouts(c.UserContext(), id, fromDate, toDate.AddDate(0, 0, 1))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch blackouts",
+			})
+		}
+
+		hoursResult := []fiber.Map{}
+		for _, h := range hours {
+			hoursResult = append(hoursResult, hoursResponse(h))
+		}
+		exceptionsResult := []fiber.Map{}
+		for _, e := range exceptions {
+			exceptionsResult = append(exceptionsResult, exceptionResponse(e))
+		}
+		blackoutsResult := []fiber.Map{}
+		for _, b := range blackouts {
+			blackoutsResult = append(blackoutsResult, blackoutResponse(b))
+		}
+
+		return c.JSON(fiber.Map{
+			"message":    "Schedule retrieved successfully",
+			"field_id":   field.FieldID,
+			"rules":      rulesResponse(field.Rules),
+			"hours":      hoursResult,
+			"exceptions": exceptionsResult,
+			"blackouts":  blackoutsResult,
+		})
+	}
+}
+
+func SetRulesHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		var req struct {
+			SlotMinutes        int `json:"slot_minutes"`
+			MinDurationMinutes int `json:"min_duration_minutes"`
+			MaxDurationMinutes int `json:"max_duration_minutes"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		rules := store.SlotRules{
+			SlotMinutes: req.SlotMinutes,
+			MinMinutes:  req.MinDurationMinutes,
+			MaxMinutes:  req.MaxDurationMinutes,
+		}
+		if msg := validateRules(rules); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+
+		if err := fields.SetRules(c.UserContext(), id, rules); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update slot rules",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Slot rules updated successfully",
+			"rules":   rulesResponse(rules),
+		})
+	}
+}
+
+// SetHoursHandler replaces a field's weekly hours. Days left out are
+// closed; an empty list opens the field all day, every day.
+func SetHoursHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		var req struct {
+			Hours []struct {
+				Day    string `json:"day"`
+				Opens  string `json:"opens"`
+				Closes string `json:"closes"`
+			} `json:"hours"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		hours := make([]store.OpeningHours, 0, len(req.Hours))
+		seen := make(map[time.Weekday]bool)
+		for _, h := range req.Hours {
+			weekday, ok := ParseWeekday(h.Day)
+			if !ok {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid day %q. Use monday to sunday", h.Day),
+				})
+			}
+			if seen[weekday] {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": fmt.Sprintf("%s is listed more than once", WeekdayName(weekday)),
+				})
+			}
+			seen[weekday] = true
+
+			opens, closes, msg := parseRange(h.Opens, h.Closes)
+			if msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": WeekdayName(weekday) + ": " + msg,
+				})
+			}
+			hours = append(hours, store.OpeningHours{Weekday: weekday, Opens: opens, Closes: closes})
+		}
+
+		if err := fields.SetHours(c.UserContext(), id, hours); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update opening hours",
+			})
+		}
+
+		result := []fiber.Map{}
+		for _, h := range hours {
+			result = append(result, hoursResponse(h))
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Opening hours updated successfully",
+			"hours":   result,
+		})
+	}
+}
+
+// SetExceptionHandler replaces a field's hours on one date, or closes it
+// for the day when no hours are given.
+func SetExceptionHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		date, err := time.Parse("2006-01-02", c.Params("date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid date format. Use YYYY-MM-DD",
+			})
+		}
+
+		var req struct {
+			Opens  string `json:"opens"`
+			Closes string `json:"closes"`
+			Reason string `json:"reason"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		exception := store.FieldException{
+			FieldID: id,
+			Date:    date.Format("2006-01-02"),
+			Closed:  req.Opens == "" && req.Closes == "",
+			Reason:  req.Reason,
+		}
+		if !exception.Closed {
+			var msg string
+			exception.Opens, exception.Closes, msg = parseRange(req.Opens, req.Closes)
+			if msg != "" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": msg,
+				})
+			}
+		}
+
+		if err := fields.SetException(c.UserContext(), exception); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to save exception",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":   "Exception saved successfully",
+			"exception": exceptionResponse(exception),
+		})
+	}
+}
+
+func DeleteExceptionHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		date, err := time.Parse("2006-01-02", c.Params("date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid date format. Use YYYY-MM-DD",
+			})
+		}
+
+		if err := fields.DeleteException(c.UserContext(), id, date.Format("2006-01-02")); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Exception not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete exception",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Exception deleted successfully",
+		})
+	}
+}
+
+// CreateBlackoutHandler takes a field out of use between two times, for
+// example for maintenance. Existing bookings in that time are kept.
+func CreateBlackoutHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		var req struct {
+			StartsAt time.Time `json:"starts_at"`
+			EndsAt   time.Time `json:"ends_at"`
+			Reason   string    `json:"reason"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body. Times use RFC 3339, such as 2030-01-01T08:00:00+07:00",
+			})
+		}
+		if req.StartsAt.IsZero() || req.EndsAt.IsZero() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "starts_at and ends_at are required",
+			})
+		}
+		if !req.EndsAt.After(req.StartsAt) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "ends_at must be after starts_at",
+			})
+		}
+
+		blackout := store.FieldBlackout{
+			FieldID:  id,
+			StartsAt: req.StartsAt,
+			EndsAt:   req.EndsAt,
+			Reason:   req.Reason,
+		}
+		if err := fields.CreateBlackout(c.UserContext(), &blackout); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create blackout",
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message":  "Blackout created successfully",
+			"blackout": blackoutResponse(blackout),
+		})
+	}
+}
+
+func DeleteBlackoutHandler(fields store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+		blackoutID, err := strconv.Atoi(c.Params("blackout_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid blackout ID",
+			})
+		}
+
+		if err := fields.DeleteBlackout(c.UserContext(), id, blackoutID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Blackout not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete blackout",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Blackout deleted successfully",
+		})
+	}
+}
+
+// validateRules returns why rules are invalid, or "" if they are not.
+func validateRules(rules store.SlotRules) string {
+	switch {
+	case rules.SlotMinutes < 5 || (24*60)%rules.SlotMinutes != 0:
+		return "Slot minutes must be at least 5 and divide a day evenly, such as 15, 30 or 60"
+	case rules.MinMinutes < rules.SlotMinutes || rules.MinMinutes%rules.SlotMinutes != 0:
+		return "Minimum duration must be a multiple of the slot minutes"
+	case rules.MaxMinutes < rules.MinMinutes || rules.MaxMinutes%rules.SlotMinutes != 0:
+		return "Maximum duration must be a multiple of the slot minutes and at least the minimum duration"
+	case rules.MaxMinutes > 24*60:
+		return "Maximum duration cannot exceed 24 hours"
+	}
+	return ""
+}
+
+// parseRange normalises opens and closes to HH:MM, or returns why they are
+// invalid.
+func parseRange(opens, closes string) (string, string, string) {
+	openMinutes, ok := ParseClock(opens)
+	if !ok || openMinutes == 24*60 {
+		return "", "", "Invalid opening time format. Use HH:MM"
+	}
+	closeMinutes, ok := ParseClock(closes)
+	if !ok {
+		return "", "", "Invalid closing time format. Use HH:MM"
+	}
+	if closeMinutes <= openMinutes {
+		return "", "", "Closing time must be after opening time"
+	}
+	return FormatClock(openMinutes), FormatClock(closeMinutes), ""
+}
+
+func rulesResponse(rules store.SlotRules) fiber.Map {
+	return fiber.Map{
+		"slot_minutes":         rules.SlotMinutes,
+		"min_duration_minutes": rules.MinMinutes,
+		"max_duration_minutes": rules.MaxMinutes,
+	}
+}
+
+func hoursResponse(h store.OpeningHours) fiber.Map {
+	return fiber.Map{
+		"day":    WeekdayName(h.Weekday),
+		"opens":  h.Opens,
+		"closes": h.Closes,
+	}
+}
+
+func exceptionResponse(e store.FieldException) fiber.Map {
+	return fiber.Map{
+		"date":   e.Date,
+		"closed": e.Closed,
+		"opens":  e.Opens,
+		"closes": e.Closes,
+		"reason": e.Reason,
+	}
+}
+
+func blackoutResponse(b store.FieldBlackout) fiber.Map {
+	return fiber.Map{
+		"blackout_id": b.BlackoutID,
+		"starts_at":   b.StartsAt,
+		"ends_at":     b.EndsAt,
+		"reason":      b.Reason,
+		"created_at":  b.CreatedAt,
+	}
+}
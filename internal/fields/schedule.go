@@ -0,0 +1,189 @@
+package fields
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+)
+
+// SlotError explains why a booking does not fit a field's hours or slot
+// rules. Its message is meant for the person booking.
+type SlotError struct {
+	Reason string
+}
+
+func (e *SlotError) Error() string {
+	return e.Reason
+}
+
+// Day is when a field is open on one date. Opens and Closes are minutes
+// since midnight.
+type Day struct {
+	Date   string
+	Open   bool
+	Opens  int
+	Closes int
+	// Reason is set when an exception replaced the regular hours.
+	Reason string
+}
+
+// DayFor returns the field's hours on date, applying any exception. A field
+// without weekly hours is open all day.
+func DayFor(ctx context.Context, fields store.FieldRepository, fieldID int, date time.Time) (Day, error) {
+	d := date.Format("2006-01-02")
+
+	exceptions, err := fields.Exceptions(ctx, fieldID, d, d)
+	if err != nil {
+		return Day{}, err
+	}
+	hours, err := fields.Hours(ctx, fieldID)
+	if err != nil {
+		return Day{}, err
+	}
+
+	return dayOf(date, hours, exceptionsByDate(exceptions)), nil
+}
+
+// dayOf works out the hours on date from a field's weekly hours and its
+// exceptions keyed by date.
+func dayOf(date time.Time, hours []store.OpeningHours, exceptions map[string]store.FieldException) Day {
+	day := Day{Date: date.Format("2006-01-02")}
+
+	if e, ok := exceptions[day.Date]; ok {
+		day.Reason = e.Reason
+		if !e.Closed {
+			day.Open = true
+			day.Opens, _ = ParseClock(e.Opens)
+			day.Closes, _ = ParseClock(e.Closes)
+		}
+		return day
+	}
+
+	if len(hours) == 0 {
+		day.Open, day.Closes = true, 24*60
+		return day
+	}
+	for _, h := range hours {
+		if h.Weekday == date.Weekday() {
+			day.Open = true
+			day.Opens, _ = ParseClock(h.Opens)
+			day.Closes, _ = ParseClock(h.Closes)
+			break
+		}
+	}
+
+	return day
+}
+
+func exceptionsByDate(exceptions []store.FieldException) map[string]store.FieldException {
+	byDate := make(map[string]store.FieldException, len(exceptions))
+	for _, e := range exceptions {
+		byDate[e.Date] = e
+	}
+	return byDate
+}
+
+// CheckSlot returns a *SlotError if a booking from start to end, in
+// minutes since midnight, falls outside day or breaks the rules.
+func CheckSlot(rules store.SlotRules, day Day, start, end int) error {
+	if !day.Open {
+		if day.Reason != "" {
+			return &SlotError{fmt.Sprintf("Field is closed on %s: %s", day.Date, day.Reason)}
+		}
+		return &SlotError{fmt.Sprintf("Field is closed on %s", day.Date)}
+	}
+	if start < day.Opens || end > day.Closes {
+		return &SlotError{fmt.Sprintf("Field is open from %s to %s on %s", FormatClock(day.Opens), FormatClock(day.Closes), day.Date)}
+	}
+	if start%rules.SlotMinutes != 0 || end%rules.SlotMinutes != 0 {
+		return &SlotError{fmt.Sprintf("Bookings must start and end on a %d-minute boundary", rules.SlotMinutes)}
+	}
+	if end-start < rules.MinMinutes {
+		return &SlotError{fmt.Sprintf("Bookings must last at least %d minutes", rules.MinMinutes)}
+	}
+	if end-start > rules.MaxMinutes {
+		return &SlotError{fmt.Sprintf("Bookings must last at most %d minutes", rules.MaxMinutes)}
+	}
+	return nil
+}
+
+// CheckBooking checks a booking of field on date from start to end,
+// both HH:MM, against its hours and rules. It returns a *SlotError when
+// the booking is not allowed.
+func CheckBooking(ctx context.Context, fields store.FieldRepository, field store.Field, date time.Time, start, end string) error {
+	startMinutes, ok := ParseClock(start)
+	if !ok {
+		return &SlotError{"Invalid start time format. Use HH:MM"}
+	}
+	endMinutes, ok := ParseClock(end)
+	if !ok {
+		return &SlotError{"Invalid end time format. Use HH:MM"}
+	}
+
+	day, err := DayFor(ctx, fields, field.FieldID, date)
+	if err != nil {
+		return err
+	}
+	if err := CheckSlot(field.Rules, day, startMinutes, endMinutes); err != nil {
+		return err
+	}
+
+	startsAt, endsAt := clockTime(date, startMinutes), clockTime(date, endMinutes)
+	blackouts, err := fields.Blackouts(ctx, field.FieldID, startsAt, endsAt)
+	if err != nil {
+		return err
+	}
+	if len(blackouts) > 0 {
+		b := blackouts[0]
+		msg := fmt.Sprintf("Field is unavailable from %s to %s", b.StartsAt.In(time.Local).Format("2006-01-02 15:04"), b.EndsAt.In(time.Local).Format("2006-01-02 15:04"))
+		if b.Reason != "" {
+			msg += ": " + b.Reason
+		}
+		return &SlotError{msg}
+	}
+
+	return nil
+}
+
+// clockTime returns the local time minutes after midnight on date.
+func clockTime(date time.Time, minutes int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, minutes, 0, 0, time.Local)
+}
+
+// ParseClock parses HH:MM, allowing 24:00, into minutes since midnight.
+func ParseClock(s string) (int, bool) {
+	if s == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// ParseWeekday parses a day name such as "monday", in any case.
+func ParseWeekday(s string) (time.Weekday, bool) {
+	day, ok := weekdays[strings.ToLower(s)]
+	return day, ok
+}
+
+func WeekdayName(day time.Weekday) string {
+	return strings.ToLower(day.String())
+}
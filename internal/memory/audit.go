@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type AuditRepository struct {
+	db *DB
+}
+
+var _ store.AuditRepository = (*AuditRepository)(nil)
+
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, e *store.AuditEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e.AuditID = len(r.db.auditLog) + 1
+	e.CreatedAt = time.Now()
+	r.db.auditLog = append(r.db.auditLog, *e)
+
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, limit, offset int) ([]store.AuditEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var entries []store.AuditEntry
+	for i := len(r.db.auditLog) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, r.db.auditLog[i])
+	}
+
+	return entries, nil
+}
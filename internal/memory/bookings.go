@@ -0,0 +1,382 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type BookingRepository struct {
+	db *DB
+}
+
+var _ store.BookingRepository = (*BookingRepository)(nil)
+
+func NewBookingRepository(db *DB) *BookingRepository {
+	return &BookingRepository{db: db}
+}
+
+func (r *BookingRepository) IsAvailable(ctx context.Context, fieldID int, bookingDate, startTime, endTime string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return !r.db.overlaps(fieldID, bookingDate, startTime, endTime, 0), nil
+}
+
+func (r *BookingRepository) Busy(ctx context.Context, fieldID int, from, to string) ([]store.BusySlot, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var slots []store.BusySlot
+	for _, b := range r.db.bookings {
+		if b.FieldID != fieldID || b.BookingDate < from || b.BookingDate > to || !store.HoldsSlot(b, now) {
+			continue
+		}
+		slots = append(slots, store.BusySlot{Date: b.BookingDate, StartTime: b.StartTime, EndTime: b.EndTime})
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if slots[i].Date != slots[j].Date {
+			return slots[i].Date < slots[j].Date
+		}
+		return slots[i].StartTime < slots[j].StartTime
+	})
+
+	return slots, nil
+}
+
+func (r *BookingRepository) Create(ctx context.Context, b *store.Booking) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	promoID, err := r.db.checkBooking(*b)
+	if err != nil {
+		return err
+	}
+	r.db.insertBooking(b, promoID)
+
+	return nil
+}
+
+func (r *BookingRepository) CreateSeries(ctx context.Context, s *store.BookingSeries, occurrences []store.Booking) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// Check everything first so a conflict leaves nothing behind. Weekly
+	// occurrences cannot overlap each other.
+	promoIDs := make([]int, len(occurrences))
+	for i, b := range occurrences {
+		promoID, err := r.db.checkBooking(b)
+		if err != nil {
+			return err
+		}
+		promoIDs[i] = promoID
+	}
+
+	r.db.nextSeriesID++
+	s.SeriesID = r.db.nextSeriesID
+	s.CreatedAt = time.Now()
+	r.db.bookingSeries[s.SeriesID] = *s
+
+	for i := range occurrences {
+		seriesID := s.SeriesID
+		occurrences[i].SeriesID = &seriesID
+		r.db.insertBooking(&occurrences[i], promoIDs[i])
+	}
+
+	return nil
+}
+
+func (r *BookingRepository) GetSeries(ctx context.Context, seriesID int) (store.BookingSeries, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	s, ok := r.db.bookingSeries[seriesID]
+	if !ok {
+		return store.BookingSeries{}, store.ErrNotFound
+	}
+
+	return s, nil
+}
+
+func (r *BookingRepository) Reschedule(ctx context.Context, b *store.Booking, fromStatus string, change *store.BookingReschedule) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.bookings[b.BookingID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if existing.Status != fromStatus || existing.RescheduleCount != b.RescheduleCount {
+		return store.ErrStatusChanged
+	}
+	if r.db.overlaps(b.FieldID, b.BookingDate, b.StartTime, b.EndTime, b.BookingID) {
+		return store.ErrSlotUnavailable
+	}
+	r.db.expireOverlapping(b.FieldID, b.BookingDate, b.StartTime, b.EndTime)
+
+	change.BookingID = b.BookingID
+	change.FromFieldID, change.FromDate, change.FromStart, change.FromEnd = existing.FieldID, existing.BookingDate, existing.StartTime, existing.EndTime
+	change.ToFieldID, change.ToDate, change.ToStart, change.ToEnd = b.FieldID, b.BookingDate, b.StartTime, b.EndTime
+	change.OldPrice, change.NewPrice = existing.TotalPrice, b.TotalPrice
+	change.RescheduleID = len(r.db.bookingReschedules) + 1
+	change.CreatedAt = time.Now()
+	r.db.bookingReschedules = append(r.db.bookingReschedules, *change)
+
+	existing.FieldID, existing.BookingDate, existing.StartTime, existing.EndTime = b.FieldID, b.BookingDate, b.StartTime, b.EndTime
+	existing.TotalPrice = b.TotalPrice
+	existing.PriceBreakdown = b.PriceBreakdown
+	existing.DiscountAmount = b.DiscountAmount
+	existing.PriceAdjustment = b.PriceAdjustment
+	existing.RescheduleCount++
+	r.db.bookings[b.BookingID] = existing
+	b.RescheduleCount = existing.RescheduleCount
+
+	return nil
+}
+
+// checkBooking returns why b cannot be stored, or the ID of the promo code
+// it redeems, if any.
+func (db *DB) checkBooking(b store.Booking) (int, error) {
+	promoID := 0
+	if b.PromoCode != "" {
+		promo, ok := db.promoByCode(b.PromoCode)
+		if !ok {
+			return 0, store.ErrNotFound
+		}
+		now := time.Now()
+		if !promo.Active || (promo.ValidFrom != nil && now.Before(*promo.ValidFrom)) || (promo.ValidUntil != nil && !now.Before(*promo.ValidUntil)) {
+			return 0, store.ErrPromoUnavailable
+		}
+		total, byUser := db.promoRedemptions(promo.PromoID, b.UserID)
+		if promo.MaxRedemptions > 0 && total >= promo.MaxRedemptions {
+			return 0, store.ErrPromoExhausted
+		}
+		if promo.PerUserLimit > 0 && byUser >= promo.PerUserLimit {
+			return 0, store.ErrPromoUserLimit
+		}
+		promoID = promo.PromoID
+	}
+
+	if db.overlaps(b.FieldID, b.BookingDate, b.StartTime, b.EndTime, 0) {
+		return 0, store.ErrSlotUnavailable
+	}
+	return promoID, nil
+}
+
+func (db *DB) insertBooking(b *store.Booking, promoID int) {
+	db.expireOverlapping(b.FieldID, b.BookingDate, b.StartTime, b.EndTime)
+
+	db.nextBookingID++
+	b.BookingID = db.nextBookingID
+	b.CreatedAt = time.Now()
+	db.bookings[b.BookingID] = *b
+	if promoID != 0 {
+		db.bookingPromos[b.BookingID] = promoID
+	}
+}
+
+func (r *BookingRepository) Get(ctx context.Context, bookingID int) (store.Booking, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.bookings[bookingID]
+	if !ok {
+		return store.Booking{}, store.ErrNotFound
+	}
+
+	return r.db.withField(b), nil
+}
+
+func (r *BookingRepository) Cancel(ctx context.Context, bookingID int, fromStatus string, refundAmount int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.bookings[bookingID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if b.Status != fromStatus || !store.CanTransition(b.Status, store.BookingCancelled) {
+		return store.ErrStatusChanged
+	}
+
+	now := time.Now()
+	b.Status = store.BookingCancelled
+	b.RefundAmount = refundAmount
+	b.CancelledAt = &now
+	r.db.bookings[bookingID] = b
+
+	return nil
+}
+
+func (r *BookingRepository) CheckIn(ctx context.Context, bookingID int, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	b, ok := r.db.bookings[bookingID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if b.Status != store.BookingPaid || b.CheckedInAt != nil {
+		return store.ErrStatusChanged
+	}
+
+	b.CheckedInAt = &at
+	r.db.bookings[bookingID] = b
+
+	return nil
+}
+
+func (r *BookingRepository) ExpirePending(ctx context.Context, now time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	expired := 0
+	for id, b := range r.db.bookings {
+		if store.CanTransition(b.Status, store.BookingExpired) && b.ExpiresAt != nil && !b.ExpiresAt.After(now) {
+			b.Status = store.BookingExpired
+			r.db.bookings[id] = b
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+func (r *BookingRepository) CompleteFinished(ctx context.Context, now time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	completed := 0
+	for id, b := range r.db.bookings {
+		if !store.CanTransition(b.Status, store.BookingCompleted) {
+			continue
+		}
+		endsAt, err := endOf(b)
+		if err != nil {
+			return completed, err
+		}
+		if !endsAt.After(now) {
+			b.Status = store.BookingCompleted
+			r.db.bookings[id] = b
+			completed++
+		}
+	}
+
+	return completed, nil
+}
+
+// overlaps reports whether a booking other than exceptID holds part of the
+// slot. It mirrors the bookings_no_overlap exclusion constraint, except
+// that lapsed holds are ignored because Create expires them first. Times
+// are HH:MM strings, so they compare correctly as strings.
+func (db *DB) overlaps(fieldID int, bookingDate, startTime, endTime string, exceptID int) bool {
+	now := time.Now()
+	for id, b := range db.bookings {
+		if id == exceptID || b.FieldID != fieldID || b.BookingDate != bookingDate {
+			continue
+		}
+		if !store.HoldsSlot(b, now) {
+			continue
+		}
+		if b.StartTime < endTime && startTime < b.EndTime {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) expireOverlapping(fieldID int, bookingDate, startTime, endTime string) {
+	now := time.Now()
+	for id, b := range db.bookings {
+		if b.FieldID != fieldID || b.BookingDate != bookingDate || !store.CanTransition(b.Status, store.BookingExpired) {
+			continue
+		}
+		if store.HoldsSlot(b, now) {
+			continue
+		}
+		if b.StartTime < endTime && startTime < b.EndTime {
+			b.Status = store.BookingExpired
+			db.bookings[id] = b
+		}
+	}
+}
+
+func (db *DB) withField(b store.Booking) store.Booking {
+	f := db.fields[b.FieldID]
+	b.FieldName = f.Name
+	b.Location = f.Location
+	return b
+}
+
+func (r *BookingRepository) List(ctx context.Context, filter store.BookingFilter) ([]store.Booking, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var bookings []store.Booking
+	for _, b := range r.db.bookings {
+		if filter.UserID != 0 && b.UserID != filter.UserID {
+			continue
+		}
+		if filter.SeriesID != 0 && (b.SeriesID == nil || *b.SeriesID != filter.SeriesID) {
+			continue
+		}
+		if filter.Status != "" && b.Status != filter.Status {
+			continue
+		}
+		if filter.DateFrom != "" && b.BookingDate < filter.DateFrom {
+			continue
+		}
+		if filter.DateTo != "" && b.BookingDate > filter.DateTo {
+			continue
+		}
+		bookings = append(bookings, r.db.withField(b))
+	}
+
+	less := func(a, b store.Booking) bool {
+		switch filter.SortBy {
+		case store.BookingSortCreatedAt:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		case store.BookingSortTotalPrice:
+			if a.TotalPrice != b.TotalPrice {
+				return a.TotalPrice < b.TotalPrice
+			}
+		default:
+			if a.BookingDate != b.BookingDate {
+				return a.BookingDate < b.BookingDate
+			}
+			if a.StartTime != b.StartTime {
+				return a.StartTime < b.StartTime
+			}
+		}
+		return a.BookingID < b.BookingID
+	}
+	sort.Slice(bookings, func(i, j int) bool {
+		if filter.SortDesc {
+			return less(bookings[j], bookings[i])
+		}
+		return less(bookings[i], bookings[j])
+	})
+
+	total := len(bookings)
+	if filter.Limit > 0 {
+		start := min(filter.Offset, total)
+		end := min(start+filter.Limit, total)
+		bookings = bookings[start:end]
+	}
+
+	return bookings, total, nil
+}
+
+// endOf returns when b ends, in local time. A booking ending at 24:00
+// ends at midnight of the next day, which time.Parse does not accept.
+func endOf(b store.Booking) (time.Time, error) {
+	if b.EndTime == "24:00" {
+		day, err := time.ParseInLocation("2006-01-02", b.BookingDate, time.Local)
+		return day.AddDate(0, 0, 1), err
+	}
+	return time.ParseInLocation("2006-01-02 15:04", b.BookingDate+" "+b.EndTime, time.Local)
+}
@@ -0,0 +1,239 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/store"
+	"time"
+)
+
+// defaultSlotRules mirrors the column defaults on fields.
+var defaultSlotRules = store.SlotRules{SlotMinutes: 30, MinMinutes: 60, MaxMinutes: 240}
+
+type FieldRepository struct {
+	db *DB
+}
+
+var _ store.FieldRepository = (*FieldRepository)(nil)
+
+func NewFieldRepository(db *DB) *FieldRepository {
+	return &FieldRepository{db: db}
+}
+
+func (r *FieldRepository) Create(ctx context.Context, f *store.Field) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.nextFieldID++
+	f.FieldID = r.db.nextFieldID
+	f.Rules = defaultSlotRules
+	r.db.fields[f.FieldID] = *f
+
+	return nil
+}
+
+func (r *FieldRepository) List(ctx context.Context) ([]store.Field, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	fields := make([]store.Field, 0, len(r.db.fields))
+	for _, f := range r.db.fields {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].FieldID < fields[j].FieldID
+	})
+
+	return fields, nil
+}
+
+func (r *FieldRepository) Get(ctx context.Context, fieldID int) (store.Field, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	f, ok := r.db.fields[fieldID]
+	if !ok {
+		return store.Field{}, store.ErrNotFound
+	}
+
+	return f, nil
+}
+
+func (r *FieldRepository) Update(ctx context.Context, f store.Field) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.fields[f.FieldID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	f.Rules = existing.Rules
+	r.db.fields[f.FieldID] = f
+
+	return nil
+}
+
+func (r *FieldRepository) Delete(ctx context.Context, fieldID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fields[fieldID]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.db.fields, fieldID)
+	delete(r.db.fieldHours, fieldID)
+	delete(r.db.fieldExceptions, fieldID)
+	for id, b := range r.db.fieldBlackouts {
+		if b.FieldID == fieldID {
+			delete(r.db.fieldBlackouts, id)
+		}
+	}
+	for id, rule := range r.db.pricingRules {
+		if rule.FieldID == fieldID {
+			delete(r.db.pricingRules, id)
+		}
+	}
+
+	// Mirrors ON DELETE CASCADE on bookings.field_id and payments.booking_id.
+	for id, b := range r.db.bookings {
+		if b.FieldID == fieldID {
+			delete(r.db.bookings, id)
+		}
+	}
+	for id, p := range r.db.payments {
+		if _, ok := r.db.bookings[p.BookingID]; !ok {
+			delete(r.db.payments, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *FieldRepository) SetRules(ctx context.Context, fieldID int, rules store.SlotRules) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	f, ok := r.db.fields[fieldID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	f.Rules = rules
+	r.db.fields[fieldID] = f
+
+	return nil
+}
+
+func (r *FieldRepository) Hours(ctx context.Context, fieldID int) ([]store.OpeningHours, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	hours := append([]store.OpeningHours(nil), r.db.fieldHours[fieldID]...)
+	sort.Slice(hours, func(i, j int) bool {
+		return hours[i].Weekday < hours[j].Weekday
+	})
+
+	return hours, nil
+}
+
+func (r *FieldRepository) SetHours(ctx context.Context, fieldID int, hours []store.OpeningHours) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fields[fieldID]; !ok {
+		return store.ErrNotFound
+	}
+	r.db.fieldHours[fieldID] = append([]store.OpeningHours(nil), hours...)
+
+	return nil
+}
+
+func (r *FieldRepository) Exceptions(ctx context.Context, fieldID int, from, to string) ([]store.FieldException, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var exceptions []store.FieldException
+	for date, e := range r.db.fieldExceptions[fieldID] {
+		if date >= from && date <= to {
+			exceptions = append(exceptions, e)
+		}
+	}
+	sort.Slice(exceptions, func(i, j int) bool {
+		return exceptions[i].Date < exceptions[j].Date
+	})
+
+	return exceptions, nil
+}
+
+func (r *FieldRepository) SetException(ctx context.Context, e store.FieldException) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fields[e.FieldID]; !ok {
+		return store.ErrNotFound
+	}
+	if e.Closed {
+		e.Opens, e.Closes = "", ""
+	}
+	if r.db.fieldExceptions[e.FieldID] == nil {
+		r.db.fieldExceptions[e.FieldID] = make(map[string]store.FieldException)
+	}
+	r.db.fieldExceptions[e.FieldID][e.Date] = e
+
+	return nil
+}
+
+func (r *FieldRepository) DeleteException(ctx context.Context, fieldID int, date string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fieldExceptions[fieldID][date]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.db.fieldExceptions[fieldID], date)
+
+	return nil
+}
+
+func (r *FieldRepository) CreateBlackout(ctx context.Context, b *store.FieldBlackout) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fields[b.FieldID]; !ok {
+		return store.ErrNotFound
+	}
+	r.db.nextBlackoutID++
+	b.BlackoutID = r.db.nextBlackoutID
+	b.CreatedAt = time.Now()
+	r.db.fieldBlackouts[b.BlackoutID] = *b
+
+	return nil
+}
+
+func (r *FieldRepository) Blackouts(ctx context.Context, fieldID int, from, to time.Time) ([]store.FieldBlackout, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var blackouts []store.FieldBlackout
+	for _, b := range r.db.fieldBlackouts {
+		if b.FieldID == fieldID && b.StartsAt.Before(to) && b.EndsAt.After(from) {
+			blackouts = append(blackouts, b)
+		}
+	}
+	sort.Slice(blackouts, func(i, j int) bool {
+		return blackouts[i].StartsAt.Before(blackouts[j].StartsAt)
+	})
+
+	return blackouts, nil
+}
+
+func (r *FieldRepository) DeleteBlackout(ctx context.Context, fieldID, blackoutID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if b, ok := r.db.fieldBlackouts[blackoutID]; !ok || b.FieldID != fieldID {
+		return store.ErrNotFound
+	}
+	delete(r.db.fieldBlackouts, blackoutID)
+
+	return nil
+}
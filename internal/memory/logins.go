@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	db *DB
+}
+
+var _ store.LoginAttemptRepository = (*LoginAttemptRepository)(nil)
+
+func NewLoginAttemptRepository(db *DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Attempt(ctx context.Context, keys []string, now time.Time, window time.Duration, lockFor func(key string, failures int) time.Duration) ([]store.LoginCounter, bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	counters := make([]store.LoginCounter, len(keys))
+	locked := false
+	for i, key := range keys {
+		c, ok := r.db.loginAttempts[key]
+		if !ok {
+			c = store.LoginCounter{Key: key}
+		}
+		if c.LockedUntil != nil && c.LockedUntil.After(now) {
+			locked = true
+		}
+		counters[i] = c
+	}
+	if locked {
+		return counters, false, nil
+	}
+
+	for i, c := range counters {
+		if !c.LastFailureAt.After(now.Add(-window)) {
+			c.Failures = 0
+		}
+		c.Failures++
+		c.LastFailureAt = now
+		c.LockedUntil = nil
+		if lock := lockFor(c.Key, c.Failures); lock > 0 {
+			until := now.Add(lock)
+			c.LockedUntil = &until
+		}
+		r.db.loginAttempts[c.Key] = c
+		counters[i] = c
+	}
+
+	return counters, true, nil
+}
+
+func (r *LoginAttemptRepository) Forgive(ctx context.Context, key string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if c, ok := r.db.loginAttempts[key]; ok && c.Failures > 0 {
+		c.Failures--
+		r.db.loginAttempts[key] = c
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.loginAttempts, key)
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	deleted := 0
+	for key, c := range r.db.loginAttempts {
+		if c.LastFailureAt.After(before) || (c.LockedUntil != nil && c.LockedUntil.After(before)) {
+			continue
+		}
+		delete(r.db.loginAttempts, key)
+		deleted++
+	}
+
+	return deleted, nil
+}
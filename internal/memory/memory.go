@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"sync"
+	"take-home-test/internal/store"
+	"time"
+)
+
+// DB is an in-memory stand-in for the Postgres schema. Repositories that
+// share a DB see each other's writes, so bookings can be joined with
+// fields the same way the SQL queries do.
+type DB struct {
+	mu sync.Mutex
+
+	users    map[int]store.User
+	fields   map[int]store.Field
+	bookings map[int]store.Booking
+	payments map[int]store.Payment
+
+	bookingSeries map[int]store.BookingSeries
+	// bookingReschedules is append-only, like auditLog.
+	bookingReschedules []store.BookingReschedule
+	waitlist           map[int]store.WaitlistEntry
+
+	fieldHours map[int][]store.OpeningHours
+	// fieldExceptions is keyed by field, then date.
+	fieldExceptions map[int]map[string]store.FieldException
+	fieldBlackouts  map[int]store.FieldBlackout
+	pricingRules    map[int]store.PricingRule
+	promos          map[int]store.PromoCode
+	// bookingPromos maps a booking to the promo code it redeemed, like the
+	// bookings.promo_id column.
+	bookingPromos map[int]int
+	// paymentEvents is keyed by provider + "/" + event ID.
+	paymentEvents map[string]store.PaymentEvent
+
+	refreshTokens      map[int]store.RefreshToken
+	deniedAccessTokens map[string]time.Time
+	userTokens         map[int]store.UserToken
+
+	loginAttempts map[string]store.LoginCounter
+
+	roles map[string]store.Role
+
+	mfa map[int]store.MFA
+	// recoveryCodes maps a user to their code hashes and whether each was
+	// used.
+	recoveryCodes map[int]map[string]bool
+
+	// auditLog is append-only, so an entry's ID is its position plus one.
+	auditLog []store.AuditEntry
+
+	nextUserID    int
+	nextFieldID   int
+	nextBookingID int
+	nextPaymentID int
+	nextSeriesID  int
+	nextEntryID   int
+
+	nextBlackoutID    int
+	nextPricingRuleID int
+	nextPromoID       int
+
+	nextRefreshTokenID int
+	nextUserTokenID    int
+}
+
+func NewDB() *DB {
+	db := &DB{
+		users:         make(map[int]store.User),
+		fields:        make(map[int]store.Field),
+		bookings:      make(map[int]store.Booking),
+		payments:      make(map[int]store.Payment),
+		paymentEvents: make(map[string]store.PaymentEvent),
+
+		bookingSeries: make(map[int]store.BookingSeries),
+		waitlist:      make(map[int]store.WaitlistEntry),
+
+		fieldHours:      make(map[int][]store.OpeningHours),
+		fieldExceptions: make(map[int]map[string]store.FieldException),
+		fieldBlackouts:  make(map[int]store.FieldBlackout),
+		pricingRules:    make(map[int]store.PricingRule),
+		promos:          make(map[int]store.PromoCode),
+		bookingPromos:   make(map[int]int),
+
+		refreshTokens:      make(map[int]store.RefreshToken),
+		deniedAccessTokens: make(map[string]time.Time),
+		userTokens:         make(map[int]store.UserToken),
+
+		loginAttempts: make(map[string]store.LoginCounter),
+
+		mfa:           make(map[int]store.MFA),
+		recoveryCodes: make(map[int]map[string]bool),
+
+		roles: make(map[string]store.Role),
+	}
+	for _, role := range defaultRoles {
+		db.roles[role.Name] = copyRole(role)
+	}
+
+	return db
+}
@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type MFARepository struct {
+	db *DB
+}
+
+var _ store.MFARepository = (*MFARepository)(nil)
+
+func NewMFARepository(db *DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) Get(ctx context.Context, userID int) (store.MFA, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	m, ok := r.db.mfa[userID]
+	if !ok {
+		return store.MFA{}, store.ErrNotFound
+	}
+
+	return m, nil
+}
+
+func (r *MFARepository) SavePending(ctx context.Context, userID int, secret string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if m, ok := r.db.mfa[userID]; ok && m.EnabledAt != nil {
+		return store.ErrAlreadyExists
+	}
+	r.db.mfa[userID] = store.MFA{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+
+	return nil
+}
+
+func (r *MFARepository) Enable(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	m, ok := r.db.mfa[userID]
+	if !ok || m.EnabledAt != nil || m.LastUsedStep >= step {
+		return store.ErrStatusChanged
+	}
+
+	now := time.Now()
+	m.EnabledAt = &now
+	m.LastUsedStep = step
+	r.db.mfa[userID] = m
+
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	r.db.recoveryCodes[userID] = codes
+
+	return nil
+}
+
+func (r *MFARepository) UseStep(ctx context.Context, userID int, step int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	m, ok := r.db.mfa[userID]
+	if !ok || m.LastUsedStep >= step {
+		return store.ErrStatusChanged
+	}
+	m.LastUsedStep = step
+	r.db.mfa[userID] = m
+
+	return nil
+}
+
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	used, ok := r.db.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return store.ErrNotFound
+	}
+	r.db.recoveryCodes[userID][codeHash] = true
+
+	return nil
+}
+
+func (r *MFARepository) RemainingRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	remaining := 0
+	for _, used := range r.db.recoveryCodes[userID] {
+		if !used {
+			remaining++
+		}
+	}
+
+	return remaining, nil
+}
+
+func (r *MFARepository) Disable(ctx context.Context, userID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.mfa, userID)
+	delete(r.db.recoveryCodes, userID)
+
+	return nil
+}
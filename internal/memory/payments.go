@@ -0,0 +1,235 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type PaymentRepository struct {
+	db *DB
+}
+
+var _ store.PaymentRepository = (*PaymentRepository)(nil)
+
+func NewPaymentRepository(db *DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, p *store.Payment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.bookings[p.BookingID]; !ok {
+		return store.ErrNotFound
+	}
+	// Mirrors payments_one_open_per_booking_idx.
+	for _, existing := range r.db.payments {
+		if existing.BookingID == p.BookingID && (existing.Status == store.PaymentPending || existing.Status == store.PaymentSucceeded) {
+			return store.ErrAlreadyExists
+		}
+	}
+
+	r.db.nextPaymentID++
+	p.PaymentID = r.db.nextPaymentID
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	r.db.payments[p.PaymentID] = *p
+
+	return nil
+}
+
+func (r *PaymentRepository) Get(ctx context.Context, paymentID int) (store.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.payments[paymentID]
+	if !ok {
+		return store.Payment{}, store.ErrNotFound
+	}
+
+	return p, nil
+}
+
+func (r *PaymentRepository) List(ctx context.Context, filter store.PaymentFilter) ([]store.Payment, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var payments []store.Payment
+	for _, p := range r.db.payments {
+		if filter.UserID != 0 && r.db.bookings[p.BookingID].UserID != filter.UserID {
+			continue
+		}
+		if filter.Status != "" && p.Status != filter.Status {
+			continue
+		}
+		payments = append(payments, p)
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].PaymentID > payments[j].PaymentID
+	})
+
+	total := len(payments)
+	if filter.Limit > 0 {
+		start := min(filter.Offset, total)
+		payments = payments[start:min(start+filter.Limit, total)]
+	}
+
+	return payments, total, nil
+}
+
+func (r *PaymentRepository) ListByBooking(ctx context.Context, bookingID int) ([]store.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var payments []store.Payment
+	for _, p := range r.db.payments {
+		if p.BookingID == bookingID {
+			payments = append(payments, p)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].PaymentID > payments[j].PaymentID
+	})
+
+	return payments, nil
+}
+
+func (r *PaymentRepository) MarkSucceeded(ctx context.Context, paymentID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.payments[paymentID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if p.Status != store.PaymentPending {
+		return store.ErrStatusChanged
+	}
+	b, ok := r.db.bookings[p.BookingID]
+	if !ok || !store.CanTransition(b.Status, store.BookingPaid) {
+		return store.ErrBookingNotPayable
+	}
+
+	p.Status = store.PaymentSucceeded
+	p.UpdatedAt = time.Now()
+	r.db.payments[paymentID] = p
+
+	b.Status = store.BookingPaid
+	b.ExpiresAt = nil
+	r.db.bookings[b.BookingID] = b
+
+	return nil
+}
+
+func (r *PaymentRepository) MarkFailed(ctx context.Context, paymentID int, reason string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.payments[paymentID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if p.Status != store.PaymentPending {
+		return store.ErrStatusChanged
+	}
+
+	p.Status = store.PaymentFailed
+	p.FailureReason = reason
+	p.UpdatedAt = time.Now()
+	r.db.payments[paymentID] = p
+
+	return nil
+}
+
+func (r *PaymentRepository) MarkRefunded(ctx context.Context, paymentID int, amount int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.payments[paymentID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if p.Status != store.PaymentSucceeded && p.Status != store.PaymentRefunded {
+		return store.ErrStatusChanged
+	}
+
+	p.Status = store.PaymentRefunded
+	p.RefundedAmount += amount
+	p.UpdatedAt = time.Now()
+	r.db.payments[paymentID] = p
+
+	return nil
+}
+
+func (r *PaymentRepository) ApplyEvent(ctx context.Context, event store.PaymentEvent) (store.Payment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	key := event.Provider + "/" + event.EventID
+	if _, ok := r.db.paymentEvents[key]; ok {
+		return store.Payment{}, store.ErrDuplicateEvent
+	}
+
+	var payment store.Payment
+	found := false
+	for _, p := range r.db.payments {
+		if p.Provider == event.Provider && p.ProviderRef == event.ProviderRef {
+			payment, found = p, true
+			break
+		}
+	}
+	if !found {
+		return payment, store.ErrNotFound
+	}
+	booking := r.db.bookings[payment.BookingID]
+
+	switch event.Type {
+	case store.PaymentSucceeded:
+		if payment.Status != store.PaymentPending {
+			break
+		}
+		if !store.CanTransition(booking.Status, store.BookingPaid) {
+			return payment, store.ErrBookingNotPayable
+		}
+		payment.Status = store.PaymentSucceeded
+		booking.Status = store.BookingPaid
+		booking.ExpiresAt = nil
+
+	case store.PaymentFailed:
+		if payment.Status != store.PaymentPending {
+			break
+		}
+		payment.Status = store.PaymentFailed
+		payment.FailureReason = "reported failed by provider"
+
+	case store.PaymentRefunded:
+		if payment.Status != store.PaymentSucceeded && payment.Status != store.PaymentRefunded {
+			break
+		}
+		refunded := event.Amount
+		if refunded <= 0 || refunded > payment.Amount {
+			refunded = payment.Amount
+		}
+		if refunded <= payment.RefundedAmount {
+			break
+		}
+		payment.Status = store.PaymentRefunded
+		payment.RefundedAmount = refunded
+		if refunded == payment.Amount && store.CanTransition(booking.Status, store.BookingRefunded) {
+			booking.Status = store.BookingRefunded
+		}
+
+	default:
+		return payment, fmt.Errorf("unknown payment event type %q", event.Type)
+	}
+
+	payment.UpdatedAt = time.Now()
+	r.db.payments[payment.PaymentID] = payment
+	r.db.bookings[booking.BookingID] = booking
+	r.db.paymentEvents[key] = event
+
+	return payment, nil
+}
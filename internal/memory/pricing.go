@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type PricingRuleRepository struct {
+	db *DB
+}
+
+var _ store.PricingRuleRepository = (*PricingRuleRepository)(nil)
+
+func NewPricingRuleRepository(db *DB) *PricingRuleRepository {
+	return &PricingRuleRepository{db: db}
+}
+
+func (r *PricingRuleRepository) List(ctx context.Context, fieldID int) ([]store.PricingRule, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var rules []store.PricingRule
+	for _, rule := range r.db.pricingRules {
+		if rule.FieldID == fieldID {
+			rules = append(rules, copyPricingRule(rule))
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].RuleID < rules[j].RuleID
+	})
+
+	return rules, nil
+}
+
+func (r *PricingRuleRepository) Get(ctx context.Context, fieldID, ruleID int) (store.PricingRule, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rule, ok := r.db.pricingRules[ruleID]
+	if !ok || rule.FieldID != fieldID {
+		return store.PricingRule{}, store.ErrNotFound
+	}
+
+	return copyPricingRule(rule), nil
+}
+
+func (r *PricingRuleRepository) Create(ctx context.Context, rule *store.PricingRule) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.fields[rule.FieldID]; !ok {
+		return store.ErrNotFound
+	}
+	r.db.nextPricingRuleID++
+	rule.RuleID = r.db.nextPricingRuleID
+	rule.CreatedAt = time.Now()
+	r.db.pricingRules[rule.RuleID] = copyPricingRule(*rule)
+
+	return nil
+}
+
+func (r *PricingRuleRepository) Update(ctx context.Context, rule store.PricingRule) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.pricingRules[rule.RuleID]
+	if !ok || existing.FieldID != rule.FieldID {
+		return store.ErrNotFound
+	}
+	rule.CreatedAt = existing.CreatedAt
+	r.db.pricingRules[rule.RuleID] = copyPricingRule(rule)
+
+	return nil
+}
+
+func (r *PricingRuleRepository) Delete(ctx context.Context, fieldID, ruleID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rule, ok := r.db.pricingRules[ruleID]
+	if !ok || rule.FieldID != fieldID {
+		return store.ErrNotFound
+	}
+	delete(r.db.pricingRules, ruleID)
+
+	return nil
+}
+
+func copyPricingRule(rule store.PricingRule) store.PricingRule {
+	rule.Weekdays = append([]time.Weekday(nil), rule.Weekdays...)
+	return rule
+}
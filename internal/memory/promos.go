@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type PromoRepository struct {
+	db *DB
+}
+
+var _ store.PromoRepository = (*PromoRepository)(nil)
+
+func NewPromoRepository(db *DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+func (r *PromoRepository) Create(ctx context.Context, p *store.PromoCode) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p.Code = strings.ToUpper(p.Code)
+	if _, ok := r.db.promoByCode(p.Code); ok {
+		return store.ErrAlreadyExists
+	}
+	r.db.nextPromoID++
+	p.PromoID = r.db.nextPromoID
+	p.CreatedAt = time.Now()
+	p.Redemptions = 0
+	r.db.promos[p.PromoID] = copyPromo(*p)
+
+	return nil
+}
+
+func (r *PromoRepository) List(ctx context.Context) ([]store.PromoCode, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var promos []store.PromoCode
+	for _, p := range r.db.promos {
+		promos = append(promos, r.db.withRedemptions(p))
+	}
+	sort.Slice(promos, func(i, j int) bool {
+		return promos[i].PromoID < promos[j].PromoID
+	})
+
+	return promos, nil
+}
+
+func (r *PromoRepository) Get(ctx context.Context, promoID int) (store.PromoCode, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.promos[promoID]
+	if !ok {
+		return store.PromoCode{}, store.ErrNotFound
+	}
+
+	return r.db.withRedemptions(p), nil
+}
+
+func (r *PromoRepository) GetByCode(ctx context.Context, code string) (store.PromoCode, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	p, ok := r.db.promoByCode(code)
+	if !ok {
+		return store.PromoCode{}, store.ErrNotFound
+	}
+
+	return r.db.withRedemptions(p), nil
+}
+
+func (r *PromoRepository) Update(ctx context.Context, p store.PromoCode) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	existing, ok := r.db.promos[p.PromoID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	p.Code = strings.ToUpper(p.Code)
+	if other, ok := r.db.promoByCode(p.Code); ok && other.PromoID != p.PromoID {
+		return store.ErrAlreadyExists
+	}
+	p.CreatedAt = existing.CreatedAt
+	p.Redemptions = 0
+	r.db.promos[p.PromoID] = copyPromo(p)
+
+	return nil
+}
+
+func (r *PromoRepository) Delete(ctx context.Context, promoID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.promos[promoID]; !ok {
+		return store.ErrNotFound
+	}
+	delete(r.db.promos, promoID)
+	for bookingID, id := range r.db.bookingPromos {
+		if id == promoID {
+			delete(r.db.bookingPromos, bookingID)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) promoByCode(code string) (store.PromoCode, bool) {
+	for _, p := range db.promos {
+		if strings.EqualFold(p.Code, code) {
+			return p, true
+		}
+	}
+	return store.PromoCode{}, false
+}
+
+// promoRedemptions counts the bookings that used up a redemption of the
+// promo, overall and by userID. Cancelled, expired and refunded bookings
+// give theirs back.
+func (db *DB) promoRedemptions(promoID, userID int) (int, int) {
+	now := time.Now()
+	total, byUser := 0, 0
+	for bookingID, id := range db.bookingPromos {
+		b := db.bookings[bookingID]
+		if id != promoID || !(store.HoldsSlot(b, now) || b.Status == store.BookingCompleted) {
+			continue
+		}
+		total++
+		if b.UserID == userID {
+			byUser++
+		}
+	}
+	return total, byUser
+}
+
+func (db *DB) withRedemptions(p store.PromoCode) store.PromoCode {
+	p = copyPromo(p)
+	p.Redemptions, _ = db.promoRedemptions(p.PromoID, 0)
+	return p
+}
+
+func copyPromo(p store.PromoCode) store.PromoCode {
+	p.FieldIDs = append([]int(nil), p.FieldIDs...)
+	return p
+}
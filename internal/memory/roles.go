@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/store"
+)
+
+// defaultRoles mirrors the roles seeded by the 0015 and 0022 migrations.
+var defaultRoles = []store.Role{
+	{Name: "user", Description: "Books and pays for fields", Permissions: []string{
+		"bookings:read", "bookings:write", "payments:read",
+	}},
+	{Name: "staff", Description: "Venue staff who check players in", Permissions: []string{
+		"bookings:check_in", "bookings:read", "bookings:read_all",
+	}},
+	{Name: "finance", Description: "Reviews payments", Permissions: []string{
+		"bookings:read", "bookings:read_all", "payments:read", "payments:read_all",
+	}},
+	{Name: "admin", Description: "Manages fields, users and everything else", Permissions: []string{
+		"audit:read", "bookings:cancel_all", "bookings:check_in", "bookings:read", "bookings:read_all",
+		"bookings:reschedule_all", "bookings:write", "fields:write", "payments:read", "payments:read_all", "promos:manage", "users:manage",
+	}},
+}
+
+type RoleRepository struct {
+	db *DB
+}
+
+var _ store.RoleRepository = (*RoleRepository)(nil)
+
+func NewRoleRepository(db *DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) Get(ctx context.Context, name string) (store.Role, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	role, ok := r.db.roles[name]
+	if !ok {
+		return store.Role{}, store.ErrNotFound
+	}
+
+	return copyRole(role), nil
+}
+
+func (r *RoleRepository) List(ctx context.Context) ([]store.Role, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	roles := make([]store.Role, 0, len(r.db.roles))
+	for _, role := range r.db.roles {
+		roles = append(roles, copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+func copyRole(role store.Role) store.Role {
+	role.Permissions = append([]string{}, role.Permissions...)
+	return role
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"context"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type TokenRepository struct {
+	db *DB
+}
+
+var _ store.TokenRepository = (*TokenRepository)(nil)
+
+func NewTokenRepository(db *DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.insertRefreshToken(t)
+	return nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (store.RefreshToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+
+	return store.RefreshToken{}, store.ErrNotFound
+}
+
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldID int, next *store.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	old, ok := r.db.refreshTokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return store.ErrStatusChanged
+	}
+
+	now := time.Now()
+	old.RevokedAt = &now
+	r.db.refreshTokens[oldID] = old
+	r.db.insertRefreshToken(next)
+
+	return nil
+}
+
+func (r *TokenRepository) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.revokeRefreshTokens(func(t store.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *TokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.revokeRefreshTokens(func(t store.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *TokenRepository) DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.deniedAccessTokens[jti]; !ok {
+		r.db.deniedAccessTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (r *TokenRepository) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, denied := r.db.deniedAccessTokens[jti]
+	return denied, nil
+}
+
+func (r *TokenRepository) CreateUserToken(ctx context.Context, t *store.UserToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.nextUserTokenID++
+	t.TokenID = r.db.nextUserTokenID
+	t.CreatedAt = time.Now()
+	r.db.userTokens[t.TokenID] = *t
+
+	return nil
+}
+
+func (r *TokenRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string, now time.Time) (store.UserToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for id, t := range r.db.userTokens {
+		if t.Purpose != purpose || t.TokenHash != tokenHash {
+			continue
+		}
+		if t.UsedAt != nil || !t.ExpiresAt.After(now) {
+			break
+		}
+		t.UsedAt = &now
+		r.db.userTokens[id] = t
+		return t, nil
+	}
+
+	return store.UserToken{}, store.ErrNotFound
+}
+
+func (r *TokenRepository) InvalidateUserTokens(ctx context.Context, userID int, purpose string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range r.db.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+			r.db.userTokens[id] = t
+		}
+	}
+
+	return nil
+}
+
+func (r *TokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	deleted := 0
+	for id, t := range r.db.refreshTokens {
+		if !t.ExpiresAt.After(now) {
+			delete(r.db.refreshTokens, id)
+			deleted++
+		}
+	}
+	for id, t := range r.db.userTokens {
+		if !t.ExpiresAt.After(now) {
+			delete(r.db.userTokens, id)
+			deleted++
+		}
+	}
+	for jti, expiresAt := range r.db.deniedAccessTokens {
+		if !expiresAt.After(now) {
+			delete(r.db.deniedAccessTokens, jti)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (db *DB) insertRefreshToken(t *store.RefreshToken) {
+	db.nextRefreshTokenID++
+	t.TokenID = db.nextRefreshTokenID
+	t.CreatedAt = time.Now()
+	db.refreshTokens[t.TokenID] = *t
+}
+
+func (db *DB) revokeRefreshTokens(match func(store.RefreshToken) bool) {
+	now := time.Now()
+	for id, t := range db.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+			db.refreshTokens[id] = t
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type UserRepository struct {
+	db *DB
+}
+
+var _ store.UserRepository = (*UserRepository)(nil)
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *store.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, existing := range r.db.users {
+		if existing.Email == u.Email {
+			return store.ErrAlreadyExists
+		}
+	}
+
+	r.db.nextUserID++
+	u.UserID = r.db.nextUserID
+	u.CreatedAt = time.Now()
+	r.db.users[u.UserID] = *u
+
+	return nil
+}
+
+func (r *UserRepository) CreateFirstAdmin(ctx context.Context, u *store.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	emailTaken := false
+	for _, existing := range r.db.users {
+		if existing.Role == "admin" {
+			return store.ErrAdminExists
+		}
+		emailTaken = emailTaken || existing.Email == u.Email
+	}
+	if emailTaken {
+		return store.ErrAlreadyExists
+	}
+
+	r.db.nextUserID++
+	u.UserID = r.db.nextUserID
+	u.Role = "admin"
+	u.CreatedAt = time.Now()
+	r.db.users[u.UserID] = *u
+
+	return nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, userID int, role string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Role = role
+	r.db.users[userID] = u
+
+	return nil
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+		r.db.users[userID] = u
+	}
+
+	return nil
+}
+
+func (r *UserRepository) SetPassword(ctx context.Context, userID int, passwordHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Password = passwordHash
+	r.db.users[userID] = u
+
+	return nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, userID int) (store.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.User{}, store.ErrNotFound
+	}
+
+	return u, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (store.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return store.User{}, store.ErrNotFound
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := r.GetByEmail(ctx, email)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *UserRepository) List(ctx context.Context, filter store.UserFilter) ([]store.User, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	query := strings.ToLower(filter.Query)
+	var users []store.User
+	for _, u := range r.db.users {
+		if query != "" && !strings.Contains(strings.ToLower(u.Username), query) && !strings.Contains(strings.ToLower(u.Email), query) {
+			continue
+		}
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		if filter.Status == store.UserActive && u.DisabledAt != nil {
+			continue
+		}
+		if filter.Status == store.UserDisabled && u.DisabledAt == nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].UserID < users[j].UserID
+	})
+
+	total := len(users)
+	if filter.Limit > 0 {
+		start := min(filter.Offset, total)
+		users = users[start:min(start+filter.Limit, total)]
+	}
+
+	return users, total, nil
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, userID int, username, phone string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.Username = username
+	u.Phone = phone
+	r.db.users[userID] = u
+
+	return nil
+}
+
+func (r *UserRepository) SetPendingEmail(ctx context.Context, userID int, email string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	u.PendingEmail = email
+	r.db.users[userID] = u
+
+	return nil
+}
+
+func (r *UserRepository) ChangeEmail(ctx context.Context, userID int, email string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, existing := range r.db.users {
+		if existing.UserID != userID && existing.Email == email {
+			return store.ErrAlreadyExists
+		}
+	}
+
+	now := time.Now()
+	u.Email = email
+	u.EmailVerifiedAt = &now
+	u.PendingEmail = ""
+	r.db.users[userID] = u
+
+	return nil
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, userID int, disabled bool) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	switch {
+	case !disabled:
+		u.DisabledAt = nil
+	case u.DisabledAt == nil:
+		now := time.Now()
+		u.DisabledAt = &now
+	}
+	r.db.users[userID] = u
+	if disabled {
+		r.db.cancelWaitlistEntries(userID)
+	}
+
+	return nil
+}
+
+func (r *UserRepository) Anonymise(ctx context.Context, userID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok || u.DeletedAt != nil {
+		return store.ErrNotFound
+	}
+
+	now := time.Now()
+	u.Username = "Deleted user"
+	u.Email = fmt.Sprintf("deleted-%d@deleted.invalid", userID)
+	u.Password = ""
+	u.Phone = ""
+	u.PendingEmail = ""
+	u.EmailVerifiedAt = nil
+	if u.DisabledAt == nil {
+		u.DisabledAt = &now
+	}
+	u.DeletedAt = &now
+	r.db.users[userID] = u
+
+	delete(r.db.mfa, userID)
+	delete(r.db.recoveryCodes, userID)
+	for id, t := range r.db.userTokens {
+		if t.UserID == userID {
+			delete(r.db.userTokens, id)
+		}
+	}
+	r.db.revokeRefreshTokens(func(t store.RefreshToken) bool { return t.UserID == userID })
+	r.db.cancelWaitlistEntries(userID)
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type WaitlistRepository struct {
+	db *DB
+}
+
+var _ store.WaitlistRepository = (*WaitlistRepository)(nil)
+
+func NewWaitlistRepository(db *DB) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+func (r *WaitlistRepository) Create(ctx context.Context, e *store.WaitlistEntry) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[e.UserID]; !ok {
+		return store.ErrNotFound
+	}
+	if _, ok := r.db.fields[e.FieldID]; !ok {
+		return store.ErrNotFound
+	}
+	for _, other := range r.db.waitlist {
+		if other.Status == store.WaitlistWaiting && other.UserID == e.UserID && other.FieldID == e.FieldID &&
+			other.BookingDate == e.BookingDate && other.StartTime == e.StartTime && other.EndTime == e.EndTime {
+			return store.ErrAlreadyExists
+		}
+	}
+
+	r.db.nextEntryID++
+	e.EntryID = r.db.nextEntryID
+	e.Status = store.WaitlistWaiting
+	e.CreatedAt = time.Now()
+	r.db.waitlist[e.EntryID] = *e
+
+	return nil
+}
+
+func (r *WaitlistRepository) Get(ctx context.Context, entryID int) (store.WaitlistEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e, ok := r.db.waitlist[entryID]
+	if !ok {
+		return store.WaitlistEntry{}, store.ErrNotFound
+	}
+
+	return e, nil
+}
+
+func (r *WaitlistRepository) ListByUser(ctx context.Context, userID int) ([]store.WaitlistEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var entries []store.WaitlistEntry
+	for _, e := range r.db.waitlist {
+		if e.UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].EntryID > entries[j].EntryID
+	})
+
+	return entries, nil
+}
+
+func (r *WaitlistRepository) Waiting(ctx context.Context, fieldID int, date string) ([]store.WaitlistEntry, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var entries []store.WaitlistEntry
+	for _, e := range r.db.waitlist {
+		if e.Status != store.WaitlistWaiting || (fieldID != 0 && e.FieldID != fieldID) || (date != "" && e.BookingDate != date) {
+			continue
+		}
+		if u, ok := r.db.users[e.UserID]; !ok || u.DisabledAt != nil || u.DeletedAt != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].EntryID < entries[j].EntryID
+	})
+
+	return entries, nil
+}
+
+func (r *WaitlistRepository) MarkOffered(ctx context.Context, entryID, bookingID int, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e, ok := r.db.waitlist[entryID]
+	if !ok || e.Status != store.WaitlistWaiting {
+		return store.ErrStatusChanged
+	}
+	now := time.Now()
+	e.Status = store.WaitlistOffered
+	e.BookingID = &bookingID
+	e.OfferedAt = &now
+	e.OfferExpiresAt = &expiresAt
+	r.db.waitlist[entryID] = e
+
+	return nil
+}
+
+func (r *WaitlistRepository) Cancel(ctx context.Context, entryID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e, ok := r.db.waitlist[entryID]
+	if !ok || e.Status != store.WaitlistWaiting {
+		return store.ErrStatusChanged
+	}
+	e.Status = store.WaitlistCancelled
+	r.db.waitlist[entryID] = e
+
+	return nil
+}
+
+func (r *WaitlistRepository) Settle(ctx context.Context, now time.Time) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	changed := 0
+	localNow := now.In(time.Local).Format("2006-01-02 15:04")
+	for id, e := range r.db.waitlist {
+		switch e.Status {
+		case store.WaitlistOffered:
+			b, ok := r.db.bookings[*e.BookingID]
+			switch {
+			case ok && (b.Status == store.BookingPaid || b.Status == store.BookingCompleted):
+				e.Status = store.WaitlistBooked
+			case !ok || !store.HoldsSlot(b, now):
+				e.Status = store.WaitlistLapsed
+			default:
+				continue
+			}
+		case store.WaitlistWaiting:
+			if e.BookingDate+" "+e.StartTime > localNow {
+				continue
+			}
+			e.Status = store.WaitlistExpired
+		default:
+			continue
+		}
+		r.db.waitlist[id] = e
+		changed++
+	}
+
+	return changed, nil
+}
+
+// cancelWaitlistEntries takes a user who can no longer book off the
+// waitlists.
+func (db *DB) cancelWaitlistEntries(userID int) {
+	for id, e := range db.waitlist {
+		if e.UserID == userID && e.Status == store.WaitlistWaiting {
+			e.Status = store.WaitlistCancelled
+			db.waitlist[id] = e
+		}
+	}
+}
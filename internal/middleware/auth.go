@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 	"take-home-test/internal/auth"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
 )
 
-func AuthMiddleware(jwtSecret string, condition string) fiber.Handler {
+// AuthMiddleware authenticates the request and, when permissions are
+// given, requires the session to hold every one of them.
+func AuthMiddleware(sessions *auth.Sessions, permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authorization := c.Get("Authorization")
 
@@ -31,7 +36,12 @@ func AuthMiddleware(jwtSecret string, condition string) fiber.Handler {
 			})
 		}
 
-		claims, err := auth.ExtractToken(jwtSecret, userToken[1])
+		claims, err := sessions.Verify(c.UserContext(), userToken[1])
+		if errors.Is(err, auth.ErrAccountDisabled) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "account disabled",
+			})
+		}
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "invalid or expired token",
@@ -61,34 +71,48 @@ func AuthMiddleware(jwtSecret string, condition string) fiber.Handler {
 			role = r
 		}
 
-		if condition == "user" {
-			if role != "admin" && role != "user" {
-				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-					"error": "access forbidden - user role required",
-				})
-			}
+		granted, err := sessions.Permissions(c.UserContext(), claims)
+		if err != nil {
+			slog.Error("Failed to resolve permissions", "user_id", userID, "role", role, "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "internal server error",
+			})
 		}
 
-		if condition == "admin" {
-			if role != "admin" {
+		for _, permission := range permissions {
+			if !granted.Has(permission) {
 				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-					"error": "access forbidden - admin role required",
+					"error": "access forbidden - " + permission + " permission required",
 				})
 			}
 		}
 
+		mfa, _ := claims["mfa"].(bool)
+
+		// An admin without a second factor may still sign in to enroll,
+		// but not use any permission.
+		if len(permissions) > 0 && role == "admin" && sessions.AdminMFARequired() && !mfa {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "access forbidden - enroll in two-factor authentication and log in with it",
+			})
+		}
+
 		c.Locals("user_id", userID)
 		c.Locals("role", role)
 		c.Locals("email", email)
+		c.Locals("permissions", granted)
+		c.Locals("mfa", mfa)
+		c.Locals("jti", claims["jti"])
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Locals("token_expires_at", time.Unix(int64(exp), 0))
+		}
 
 		return c.Next()
 	}
 }
 
-func AdminMiddleware(jwtSecret string) fiber.Handler {
-	return AuthMiddleware(jwtSecret, "admin")
-}
-
-func UserMiddleware(jwtSecret string) fiber.Handler {
-	return AuthMiddleware(jwtSecret, "user")
+// RequirePermission authenticates the request and requires the session to
+// hold permission, such as auth.PermFieldsWrite.
+func RequirePermission(sessions *auth.Sessions, permission string) fiber.Handler {
+	return AuthMiddleware(sessions, permission)
 }
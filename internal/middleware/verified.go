@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"take-home-test/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
+)
+
+// VerifiedEmailMiddleware rejects users who have not verified their email
+// when required is set. It must run after AuthMiddleware. Accounts with
+// any role other than user were given it by an admin and are let through.
+func VerifiedEmailMiddleware(users store.UserRepository, required bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if role, _ := c.Locals("role").(string); !required || role != "user" {
+			return c.Next()
+		}
+
+		userID, _ := c.Locals("user_id").(int)
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "unauthorized",
+			})
+		}
+
+		if user.EmailVerifiedAt == nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "access forbidden - verify your email address first",
+			})
+		}
+
+		return c.Next()
+	}
+}
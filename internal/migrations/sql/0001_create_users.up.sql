@@ -0,0 +1,9 @@
+CREATE TABLE users (
+    user_id    SERIAL PRIMARY KEY,
+    username   VARCHAR(100) NOT NULL,
+    email      VARCHAR(255) NOT NULL UNIQUE,
+    password   VARCHAR(255) NOT NULL,
+    role       VARCHAR(20)  NOT NULL DEFAULT 'user',
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'))
+);
@@ -0,0 +1,8 @@
+CREATE TABLE fields (
+    field_id       SERIAL PRIMARY KEY,
+    name           VARCHAR(255) NOT NULL,
+    price_per_hour INTEGER      NOT NULL,
+    location       VARCHAR(255) NOT NULL,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT fields_price_per_hour_check CHECK (price_per_hour > 0)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE bookings (
+    booking_id   SERIAL PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    field_id     INTEGER     NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    booking_date DATE        NOT NULL,
+    start_time   TIME        NOT NULL,
+    end_time     TIME        NOT NULL,
+    total_price  INTEGER     NOT NULL,
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT bookings_time_check CHECK (end_time > start_time),
+    CONSTRAINT bookings_status_check CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled'))
+);
+
+CREATE INDEX bookings_user_id_idx ON bookings (user_id);
+CREATE INDEX bookings_field_date_idx ON bookings (field_id, booking_date);
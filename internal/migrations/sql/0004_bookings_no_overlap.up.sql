@@ -0,0 +1,14 @@
+-- Overlapping pending/paid bookings on the same field are rejected by the
+-- database itself, so two concurrent requests can no longer both pass the
+-- availability check and book the same slot. Existing overlaps must be
+-- resolved before this migration can be applied.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE bookings
+    ADD COLUMN period TSRANGE
+    GENERATED ALWAYS AS (tsrange(booking_date + start_time, booking_date + end_time, '[)')) STORED;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (field_id WITH =, period WITH &&)
+    WHERE (status IN ('pending', 'paid'));
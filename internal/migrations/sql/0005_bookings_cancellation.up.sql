@@ -0,0 +1,3 @@
+ALTER TABLE bookings
+    ADD COLUMN refund_amount INTEGER,
+    ADD COLUMN cancelled_at  TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS bookings_pending_expires_at_idx;
+
+UPDATE bookings SET status = 'cancelled' WHERE status = 'expired';
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled'));
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS expires_at;
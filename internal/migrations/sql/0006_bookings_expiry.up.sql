@@ -0,0 +1,7 @@
+ALTER TABLE bookings ADD COLUMN expires_at TIMESTAMPTZ;
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled', 'expired'));
+
+CREATE INDEX bookings_pending_expires_at_idx ON bookings (expires_at) WHERE status = 'pending';
@@ -0,0 +1,21 @@
+CREATE TABLE payments (
+    payment_id      SERIAL PRIMARY KEY,
+    booking_id      INTEGER      NOT NULL REFERENCES bookings (booking_id) ON DELETE CASCADE,
+    provider        VARCHAR(50)  NOT NULL,
+    provider_ref    VARCHAR(255) NOT NULL,
+    amount          INTEGER      NOT NULL,
+    refunded_amount INTEGER      NOT NULL DEFAULT 0,
+    currency        VARCHAR(3)   NOT NULL,
+    status          VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    failure_reason  TEXT,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT payments_status_check CHECK (status IN ('pending', 'succeeded', 'failed', 'refunded')),
+    CONSTRAINT payments_amount_check CHECK (amount >= 0 AND refunded_amount BETWEEN 0 AND amount),
+    CONSTRAINT payments_provider_ref_key UNIQUE (provider, provider_ref)
+);
+
+CREATE INDEX payments_booking_id_idx ON payments (booking_id);
+
+-- A booking can only be paid once.
+CREATE UNIQUE INDEX payments_one_success_per_booking_idx ON payments (booking_id) WHERE status = 'succeeded';
@@ -0,0 +1,7 @@
+UPDATE bookings SET status = 'cancelled' WHERE status = 'refunded';
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled', 'expired'));
+
+DROP TABLE IF EXISTS payment_events;
@@ -0,0 +1,12 @@
+CREATE TABLE payment_events (
+    provider     VARCHAR(50)  NOT NULL,
+    event_id     VARCHAR(255) NOT NULL,
+    type         VARCHAR(50)  NOT NULL,
+    provider_ref VARCHAR(255) NOT NULL,
+    received_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, event_id)
+);
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled', 'expired', 'refunded'));
@@ -0,0 +1,5 @@
+UPDATE bookings SET status = 'paid' WHERE status = 'completed';
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'paid', 'cancelled', 'expired', 'refunded'));
@@ -0,0 +1,7 @@
+-- 'confirmed' was never written by the application; such rows are still
+-- awaiting payment.
+UPDATE bookings SET status = 'pending' WHERE status = 'confirmed';
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'paid', 'completed', 'cancelled', 'expired', 'refunded'));
@@ -0,0 +1,12 @@
+-- target_user_id has no foreign key so entries outlive the user they
+-- describe.
+CREATE TABLE admin_audit_log (
+    audit_id       SERIAL PRIMARY KEY,
+    actor_id       INTEGER REFERENCES users (user_id) ON DELETE SET NULL,
+    action         VARCHAR(50) NOT NULL,
+    target_user_id INTEGER     NOT NULL,
+    details        TEXT        NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX admin_audit_log_created_at_idx ON admin_audit_log (created_at DESC);
@@ -0,0 +1,7 @@
+-- Failed login counters. key is "account:<email>" or "ip:<address>".
+CREATE TABLE login_attempts (
+    key             VARCHAR(320) PRIMARY KEY,
+    failures        INTEGER      NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    locked_until    TIMESTAMPTZ
+);
@@ -0,0 +1,43 @@
+-- Permission names are checked in code, so only roles and their grants
+-- are stored.
+CREATE TABLE roles (
+    name        VARCHAR(20) PRIMARY KEY,
+    description TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role       VARCHAR(20) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Books and pays for fields'),
+    ('staff', 'Venue staff who check players in'),
+    ('finance', 'Reviews payments'),
+    ('admin', 'Manages fields, users and everything else');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'bookings:read'),
+    ('user', 'bookings:write'),
+    ('user', 'payments:read'),
+    ('staff', 'bookings:read'),
+    ('staff', 'bookings:read_all'),
+    ('staff', 'bookings:check_in'),
+    ('finance', 'bookings:read'),
+    ('finance', 'bookings:read_all'),
+    ('finance', 'payments:read'),
+    ('finance', 'payments:read_all'),
+    ('admin', 'bookings:read'),
+    ('admin', 'bookings:write'),
+    ('admin', 'bookings:read_all'),
+    ('admin', 'bookings:cancel_all'),
+    ('admin', 'bookings:check_in'),
+    ('admin', 'payments:read'),
+    ('admin', 'payments:read_all'),
+    ('admin', 'fields:write'),
+    ('admin', 'users:manage'),
+    ('admin', 'audit:read');
+
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles (name);
@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN checked_in_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN phone VARCHAR(20);
+-- pending_email is the address a user asked to change to, until they
+-- confirm it from that mailbox.
+ALTER TABLE users ADD COLUMN pending_email VARCHAR(255);
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+
+ALTER TABLE user_tokens DROP CONSTRAINT user_tokens_purpose_check;
+ALTER TABLE user_tokens ADD CONSTRAINT user_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'change_email'));
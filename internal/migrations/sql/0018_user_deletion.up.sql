@@ -0,0 +1,3 @@
+-- Deleted accounts keep their row, with personal data replaced, so their
+-- bookings and payments stay intact for accounting.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
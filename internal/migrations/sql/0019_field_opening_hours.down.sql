@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS field_exceptions;
+DROP TABLE IF EXISTS field_opening_hours;
+
+ALTER TABLE fields DROP CONSTRAINT IF EXISTS fields_slot_rules_check;
+ALTER TABLE fields DROP COLUMN IF EXISTS slot_minutes;
+ALTER TABLE fields DROP COLUMN IF EXISTS min_minutes;
+ALTER TABLE fields DROP COLUMN IF EXISTS max_minutes;
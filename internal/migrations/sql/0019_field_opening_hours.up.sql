@@ -0,0 +1,29 @@
+ALTER TABLE fields ADD COLUMN slot_minutes INTEGER NOT NULL DEFAULT 30;
+ALTER TABLE fields ADD COLUMN min_minutes INTEGER NOT NULL DEFAULT 60;
+ALTER TABLE fields ADD COLUMN max_minutes INTEGER NOT NULL DEFAULT 240;
+ALTER TABLE fields ADD CONSTRAINT fields_slot_rules_check
+    CHECK (slot_minutes > 0 AND min_minutes >= slot_minutes AND max_minutes >= min_minutes);
+
+-- A field with no rows here is open all day. weekday counts from Sunday = 0,
+-- and closes may be 24:00.
+CREATE TABLE field_opening_hours (
+    field_id INTEGER  NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    weekday  SMALLINT NOT NULL,
+    opens    TIME     NOT NULL,
+    closes   TIME     NOT NULL,
+    PRIMARY KEY (field_id, weekday),
+    CONSTRAINT field_opening_hours_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT field_opening_hours_range_check CHECK (closes > opens)
+);
+
+-- An exception without opens and closes closes the field for the day.
+CREATE TABLE field_exceptions (
+    field_id INTEGER      NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    date     DATE         NOT NULL,
+    opens    TIME,
+    closes   TIME,
+    reason   VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (field_id, date),
+    CONSTRAINT field_exceptions_range_check
+        CHECK ((opens IS NULL AND closes IS NULL) OR closes > opens)
+);
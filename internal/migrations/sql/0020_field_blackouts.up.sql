@@ -0,0 +1,11 @@
+CREATE TABLE field_blackouts (
+    blackout_id SERIAL PRIMARY KEY,
+    field_id    INTEGER      NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    starts_at   TIMESTAMPTZ  NOT NULL,
+    ends_at     TIMESTAMPTZ  NOT NULL,
+    reason      VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT field_blackouts_range_check CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX field_blackouts_field_id_idx ON field_blackouts (field_id, starts_at);
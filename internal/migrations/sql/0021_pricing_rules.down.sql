@@ -0,0 +1,2 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS price_breakdown;
+DROP TABLE IF EXISTS field_pricing_rules;
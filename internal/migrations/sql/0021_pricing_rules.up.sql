@@ -0,0 +1,23 @@
+-- weekdays counts from Sunday = 0; an empty array means every day.
+CREATE TABLE field_pricing_rules (
+    rule_id        SERIAL PRIMARY KEY,
+    field_id       INTEGER      NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    name           VARCHAR(100) NOT NULL,
+    weekdays       SMALLINT[]   NOT NULL DEFAULT '{}',
+    start_time     TIME         NOT NULL,
+    end_time       TIME         NOT NULL,
+    date_from      DATE,
+    date_to        DATE,
+    price_per_hour INTEGER      NOT NULL,
+    priority       INTEGER      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT field_pricing_rules_time_check CHECK (end_time > start_time),
+    CONSTRAINT field_pricing_rules_date_check CHECK (date_to >= date_from),
+    CONSTRAINT field_pricing_rules_price_check CHECK (price_per_hour > 0)
+);
+
+CREATE INDEX field_pricing_rules_field_id_idx ON field_pricing_rules (field_id);
+
+-- price_breakdown is a JSON array of the segments total_price was summed
+-- from. Bookings made before this migration have none.
+ALTER TABLE bookings ADD COLUMN price_breakdown JSONB;
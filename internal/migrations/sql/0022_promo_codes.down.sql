@@ -0,0 +1,8 @@
+DELETE FROM role_permissions WHERE permission = 'promos:manage';
+
+DROP INDEX IF EXISTS bookings_promo_id_idx;
+ALTER TABLE bookings DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE bookings DROP COLUMN IF EXISTS promo_code;
+ALTER TABLE bookings DROP COLUMN IF EXISTS promo_id;
+
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,31 @@
+-- Codes are stored upper case. Zero limits and an empty field_ids mean no
+-- restriction.
+CREATE TABLE promo_codes (
+    promo_id        SERIAL PRIMARY KEY,
+    code            VARCHAR(50)  NOT NULL UNIQUE,
+    description     VARCHAR(255) NOT NULL DEFAULT '',
+    discount_type   VARCHAR(10)  NOT NULL,
+    discount_value  INTEGER      NOT NULL,
+    valid_from      TIMESTAMPTZ,
+    valid_until     TIMESTAMPTZ,
+    max_redemptions INTEGER      NOT NULL DEFAULT 0,
+    per_user_limit  INTEGER      NOT NULL DEFAULT 0,
+    min_spend       INTEGER      NOT NULL DEFAULT 0,
+    field_ids       INTEGER[]    NOT NULL DEFAULT '{}',
+    active          BOOLEAN      NOT NULL DEFAULT true,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    CONSTRAINT promo_codes_discount_check CHECK (
+        (discount_type = 'percent' AND discount_value BETWEEN 1 AND 100)
+        OR (discount_type = 'fixed' AND discount_value > 0)
+    ),
+    CONSTRAINT promo_codes_validity_check CHECK (valid_until > valid_from),
+    CONSTRAINT promo_codes_limits_check CHECK (max_redemptions >= 0 AND per_user_limit >= 0 AND min_spend >= 0)
+);
+
+-- A booking keeps its code and discount if the promo is deleted.
+ALTER TABLE bookings ADD COLUMN promo_id INTEGER REFERENCES promo_codes (promo_id) ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN promo_code VARCHAR(50);
+ALTER TABLE bookings ADD COLUMN discount_amount INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX bookings_promo_id_idx ON bookings (promo_id, user_id) WHERE promo_id IS NOT NULL;
+
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'promos:manage');
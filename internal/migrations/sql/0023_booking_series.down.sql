@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS bookings_series_id_idx;
+ALTER TABLE bookings DROP COLUMN IF EXISTS series_id;
+
+DROP TABLE IF EXISTS booking_series;
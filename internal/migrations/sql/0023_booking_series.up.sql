@@ -0,0 +1,15 @@
+-- A recurring booking. Its occurrences are rows in bookings pointing back
+-- at it.
+CREATE TABLE booking_series (
+    series_id  SERIAL PRIMARY KEY,
+    user_id    INTEGER      NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    field_id   INTEGER      NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    rule       VARCHAR(255) NOT NULL,
+    start_date DATE         NOT NULL,
+    start_time TIME         NOT NULL,
+    end_time   TIME         NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+
+ALTER TABLE bookings ADD COLUMN series_id INTEGER REFERENCES booking_series (series_id);
+CREATE INDEX bookings_series_id_idx ON bookings (series_id) WHERE series_id IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS booking_reschedules;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS reschedule_count;
+ALTER TABLE bookings DROP COLUMN IF EXISTS price_adjustment;
@@ -0,0 +1,23 @@
+-- price_adjustment is what rescheduling a paid booking changed its price
+-- by: positive is still to be charged, negative is owed back as credit.
+ALTER TABLE bookings ADD COLUMN price_adjustment INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE bookings ADD COLUMN reschedule_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE booking_reschedules (
+    reschedule_id SERIAL PRIMARY KEY,
+    booking_id    INTEGER     NOT NULL REFERENCES bookings (booking_id) ON DELETE CASCADE,
+    actor_id      INTEGER     REFERENCES users (user_id) ON DELETE SET NULL,
+    from_field_id INTEGER     NOT NULL,
+    from_date     DATE        NOT NULL,
+    from_start    TIME        NOT NULL,
+    from_end      TIME        NOT NULL,
+    to_field_id   INTEGER     NOT NULL,
+    to_date       DATE        NOT NULL,
+    to_start      TIME        NOT NULL,
+    to_end        TIME        NOT NULL,
+    old_price     INTEGER     NOT NULL,
+    new_price     INTEGER     NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX booking_reschedules_booking_id_idx ON booking_reschedules (booking_id);
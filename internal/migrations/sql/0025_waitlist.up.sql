@@ -0,0 +1,23 @@
+-- Users waiting for a slot to free up. booking_id is the pending booking
+-- an entry was offered.
+CREATE TABLE waitlist_entries (
+    entry_id         SERIAL PRIMARY KEY,
+    user_id          INTEGER     NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    field_id         INTEGER     NOT NULL REFERENCES fields (field_id) ON DELETE CASCADE,
+    booking_date     DATE        NOT NULL,
+    start_time       TIME        NOT NULL,
+    end_time         TIME        NOT NULL,
+    status           VARCHAR(20) NOT NULL DEFAULT 'waiting',
+    booking_id       INTEGER     REFERENCES bookings (booking_id) ON DELETE SET NULL,
+    offered_at       TIMESTAMPTZ,
+    offer_expires_at TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT waitlist_entries_time_check CHECK (end_time > start_time)
+);
+
+CREATE INDEX waitlist_entries_waiting_idx ON waitlist_entries (field_id, booking_date) WHERE status = 'waiting';
+CREATE INDEX waitlist_entries_user_id_idx ON waitlist_entries (user_id);
+-- A user waits for a slot once at a time.
+CREATE UNIQUE INDEX waitlist_entries_unique_waiting_idx
+    ON waitlist_entries (user_id, field_id, booking_date, start_time, end_time)
+    WHERE status = 'waiting';
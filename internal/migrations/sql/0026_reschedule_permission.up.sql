@@ -0,0 +1 @@
+INSERT INTO role_permissions (role, permission) VALUES ('admin', 'bookings:reschedule_all');
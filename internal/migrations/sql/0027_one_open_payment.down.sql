@@ -0,0 +1 @@
+DROP INDEX IF EXISTS payments_one_open_per_booking_idx;
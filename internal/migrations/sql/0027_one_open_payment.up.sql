@@ -0,0 +1,3 @@
+-- A booking has at most one payment in flight or paid, so a second
+-- attempt is turned away before the provider captures it.
+CREATE UNIQUE INDEX payments_one_open_per_booking_idx ON payments (booking_id) WHERE status IN ('pending', 'succeeded');
@@ -0,0 +1,32 @@
+package payments
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrPaymentDeclined = errors.New("payment declined")
+	ErrUnknownIntent   = errors.New("unknown payment intent")
+)
+
+// Intent is a provider's view of a payment. Status is one of the
+// store.Payment* constants.
+type Intent struct {
+	Reference      string
+	Amount         int
+	RefundedAmount int
+	Currency       string
+	Status         string
+}
+
+// PaymentGateway is implemented by every payment provider. Capture and
+// Refund may return an intent that is still pending when the provider
+// settles asynchronously; the outcome then arrives through FetchStatus.
+type PaymentGateway interface {
+	Name() string
+	CreateIntent(ctx context.Context, amount int, currency string, description string) (Intent, error)
+	Capture(ctx context.Context, reference string) (Intent, error)
+	Refund(ctx context.Context, reference string, amount int) (Intent, error)
+	FetchStatus(ctx context.Context, reference string) (Intent, error)
+}
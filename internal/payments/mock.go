@@ -0,0 +1,109 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"take-home-test/internal/store"
+)
+
+// MockDeclineSuffix makes the mock gateway decline any capture whose amount
+// ends in these digits, for example 100013.
+const MockDeclineSuffix = 13
+
+// MockGateway is a deterministic in-process provider for tests and local
+// development. References are numbered in creation order and captures
+// succeed immediately unless the amount ends in MockDeclineSuffix.
+type MockGateway struct {
+	mu      sync.Mutex
+	intents map[string]Intent
+	next    int
+}
+
+var _ PaymentGateway = (*MockGateway)(nil)
+
+func NewMockGateway() *MockGateway {
+	return &MockGateway{intents: make(map[string]Intent)}
+}
+
+func (g *MockGateway) Name() string {
+	return "mock"
+}
+
+func (g *MockGateway) CreateIntent(ctx context.Context, amount int, currency string, description string) (Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if amount <= 0 {
+		return Intent{}, fmt.Errorf("mock gateway: amount must be greater than 0")
+	}
+
+	g.next++
+	intent := Intent{
+		Reference: fmt.Sprintf("mock_pi_%d", g.next),
+		Amount:    amount,
+		Currency:  currency,
+		Status:    store.PaymentPending,
+	}
+	g.intents[intent.Reference] = intent
+
+	return intent, nil
+}
+
+func (g *MockGateway) Capture(ctx context.Context, reference string) (Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return Intent{}, ErrUnknownIntent
+	}
+	if intent.Status != store.PaymentPending {
+		return intent, nil
+	}
+
+	if intent.Amount%100 == MockDeclineSuffix {
+		intent.Status = store.PaymentFailed
+		g.intents[reference] = intent
+		return intent, ErrPaymentDeclined
+	}
+
+	intent.Status = store.PaymentSucceeded
+	g.intents[reference] = intent
+
+	return intent, nil
+}
+
+func (g *MockGateway) Refund(ctx context.Context, reference string, amount int) (Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return Intent{}, ErrUnknownIntent
+	}
+	if intent.Status != store.PaymentSucceeded && intent.Status != store.PaymentRefunded {
+		return intent, fmt.Errorf("mock gateway: cannot refund a %s payment", intent.Status)
+	}
+	if amount <= 0 || intent.RefundedAmount+amount > intent.Amount {
+		return intent, fmt.Errorf("mock gateway: invalid refund amount %d", amount)
+	}
+
+	intent.RefundedAmount += amount
+	intent.Status = store.PaymentRefunded
+	g.intents[reference] = intent
+
+	return intent, nil
+}
+
+func (g *MockGateway) FetchStatus(ctx context.Context, reference string) (Intent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return Intent{}, ErrUnknownIntent
+	}
+
+	return intent, nil
+}
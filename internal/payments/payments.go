@@ -1,14 +1,27 @@
 package payments
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
 )
 
-func UpdatePayment(db *sql.DB) fiber.Handler {
+func UpdatePayment(bookings store.BookingRepository, payments store.PaymentRepository, gateway PaymentGateway, currency string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+
 		var req struct {
 			BookingID int `json:"booking_id"`
 		}
@@ -25,14 +38,9 @@ func UpdatePayment(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		var currentStatus string
-		var totalPrice int
-		err := db.QueryRow(`
-			SELECT status, total_price FROM bookings WHERE booking_id = $1
-		`, req.BookingID).Scan(&currentStatus, &totalPrice)
-
+		booking, err := bookings.Get(c.UserContext(), req.BookingID)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 					"error": "Booking not found",
 				})
@@ -42,82 +50,340 @@ func UpdatePayment(db *sql.DB) fiber.Handler {
 			})
 		}
 
-		if currentStatus != "confirmed" && currentStatus != "pending" {
+		if booking.UserID != userID {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You can only pay for your own bookings",
+			})
+		}
+
+		if !store.CanTransition(booking.Status, store.BookingPaid) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": fmt.Sprintf("Cannot pay for booking with status: %s. Only pending bookings can be paid.", booking.Status),
+			})
+		}
+
+		if booking.ExpiresAt != nil && !booking.ExpiresAt.After(time.Now()) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Booking hold has expired. Please book again.",
+			})
+		}
+
+		payment, err := Pay(c.UserContext(), payments, gateway, currency, booking)
+		switch {
+		case errors.Is(err, ErrProviderUnavailable):
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "Payment provider is unavailable, please retry",
+			})
+		case errors.Is(err, ErrOutcomeUnknown):
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":   "Payment provider is unavailable, please check the payment status later",
+				"payment": paymentResponse(payment),
+			})
+		case errors.Is(err, store.ErrAlreadyExists):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Booking already has a payment in progress",
+			})
+		case err != nil:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to " + err.Error(),
+			})
+		}
+
+		return paymentResult(c, bookings, payment)
+	}
+}
+
+var (
+	// ErrProviderUnavailable is returned by Pay when the provider could not
+	// start the payment. Nothing was recorded.
+	ErrProviderUnavailable = errors.New("payment provider is unavailable")
+	// ErrOutcomeUnknown is returned by Pay, with the payment it recorded,
+	// when the provider did not say whether the capture went through. The
+	// payment stays pending until it is reconciled through GET
+	// /payments/:id.
+	ErrOutcomeUnknown = errors.New("payment outcome is unknown")
+)
+
+// freeProvider records payments of bookings a promo code made free. No
+// money moves, so they never reach the gateway, which rejects a zero
+// amount.
+const freeProvider = "free"
+
+// Pay charges booking's total through gateway and returns the payment as
+// stored. The caller checks that the booking can be paid. It returns
+// store.ErrAlreadyExists, before anything is captured, if the booking
+// already has a pending or succeeded payment.
+func Pay(ctx context.Context, payments store.PaymentRepository, gateway PaymentGateway, currency string, booking store.Booking) (store.Payment, error) {
+	if booking.TotalPrice == 0 {
+		return payNothing(ctx, payments, currency, booking)
+	}
+
+	intent, err := gateway.CreateIntent(ctx, booking.TotalPrice, currency, fmt.Sprintf("Booking #%d", booking.BookingID))
+	if err != nil {
+		slog.Error("Failed to create payment intent", "booking_id", booking.BookingID, "error", err)
+		return store.Payment{}, ErrProviderUnavailable
+	}
+
+	payment := store.Payment{
+		BookingID:   booking.BookingID,
+		Provider:    gateway.Name(),
+		ProviderRef: intent.Reference,
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+		Status:      store.PaymentPending,
+	}
+	if err := payments.Create(ctx, &payment); err != nil {
+		return store.Payment{}, fmt.Errorf("record payment: %w", err)
+	}
+
+	intent, err = gateway.Capture(ctx, payment.ProviderRef)
+	if err != nil && !errors.Is(err, ErrPaymentDeclined) {
+		slog.Error("Failed to capture payment", "payment_id", payment.PaymentID, "error", err)
+		return payment, ErrOutcomeUnknown
+	}
+
+	payment, err = reconcile(ctx, payments, gateway, payment, intent)
+	if err != nil {
+		return payment, fmt.Errorf("update payment: %w", err)
+	}
+	return payment, nil
+}
+
+// payNothing marks a booking whose total is zero as paid, recording a
+// zero payment so that it shows up like any other paid booking.
+func payNothing(ctx context.Context, payments store.PaymentRepository, currency string, booking store.Booking) (store.Payment, error) {
+	payment := store.Payment{
+		BookingID:   booking.BookingID,
+		Provider:    freeProvider,
+		ProviderRef: fmt.Sprintf("booking-%d-%d", booking.BookingID, time.Now().UnixNano()),
+		Currency:    currency,
+		Status:      store.PaymentPending,
+	}
+	if err := payments.Create(ctx, &payment); err != nil {
+		return store.Payment{}, fmt.Errorf("record payment: %w", err)
+	}
+
+	err := payments.MarkSucceeded(ctx, payment.PaymentID)
+	if errors.Is(err, store.ErrBookingNotPayable) {
+		err = payments.MarkFailed(ctx, payment.PaymentID, "booking is no longer pending")
+	}
+	if err == nil {
+		payment, err = payments.Get(ctx, payment.PaymentID)
+	}
+	if err != nil {
+		return payment, fmt.Errorf("update payment: %w", err)
+	}
+	return payment, nil
+}
+
+func GetPaymentHandler(bookings store.BookingRepository, payments store.PaymentRepository, gateway PaymentGateway) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("Cannot update payment for booking with status: %s. Only 'confirmed' or 'pending' bookings can be paid.", currentStatus),
+				"error": "Invalid payment ID",
 			})
 		}
 
-		result, err := db.Exec(`
-			UPDATE bookings SET status = $1 WHERE booking_id = $2
-		`, "paid", req.BookingID)
+		userID, _ := c.Locals("user_id").(int)
+		permissions, _ := c.Locals("permissions").(auth.Permissions)
 
+		payment, err := payments.Get(c.UserContext(), id)
 		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Payment not found",
+				})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update payment: " + err.Error(),
-			})
-		}
-
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Booking not found",
-			})
-		}
-
-		var booking struct {
-			BookingID   int
-			UserID      int
-			FieldID     int
-			FieldName   string
-			BookingDate string
-			StartTime   string
-			EndTime     string
-			TotalPrice  int
-			Status      string
-			CreatedAt   string
-		}
-
-		err = db.QueryRow(`
-			SELECT 
-				b.booking_id, b.user_id, b.field_id, f.name as field_name,
-				b.booking_date, b.start_time, b.end_time, 
-				b.total_price, b.status, b.created_at
-			FROM bookings b
-			JOIN fields f ON b.field_id = f.field_id
-			WHERE b.booking_id = $1
-		`, req.BookingID).Scan(
-			&booking.BookingID,
-			&booking.UserID,
-			&booking.FieldID,
-			&booking.FieldName,
-			&booking.BookingDate,
-			&booking.StartTime,
-			&booking.EndTime,
-			&booking.TotalPrice,
-			&booking.Status,
-			&booking.CreatedAt,
-		)
+				"error": "Failed to fetch payment: " + err.Error(),
+			})
+		}
 
+		booking, err := bookings.Get(c.UserContext(), payment.BookingID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to fetch updated booking: " + err.Error(),
+				"error": "Failed to fetch booking: " + err.Error(),
+			})
+		}
+		if booking.UserID != userID && !permissions.Has(auth.PermPaymentsReadAll) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You do not have access to this payment",
 			})
 		}
 
+		if payment.Status == store.PaymentPending && payment.Provider == gateway.Name() {
+			intent, err := gateway.FetchStatus(c.UserContext(), payment.ProviderRef)
+			if err != nil {
+				slog.Error("Failed to fetch payment status", "payment_id", payment.PaymentID, "error", err)
+			} else if payment, err = reconcile(c.UserContext(), payments, gateway, payment, intent); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to update payment: " + err.Error(),
+				})
+			}
+		}
+
+		return paymentResult(c, bookings, payment)
+	}
+}
+
+// ListPaymentsHandler lists every payment, newest first, for finance.
+func ListPaymentsHandler(payments store.PaymentRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := store.PaymentFilter{Status: c.Query("status")}
+		switch filter.Status {
+		case "", store.PaymentPending, store.PaymentSucceeded, store.PaymentFailed, store.PaymentRefunded:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid status filter",
+			})
+		}
+
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", 50)
+		if page < 1 || limit < 1 || limit > 200 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid pagination. page must be >= 1 and limit between 1 and 200",
+			})
+		}
+		filter.Limit = limit
+		filter.Offset = (page - 1) * limit
+
+		list, total, err := payments.List(c.UserContext(), filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch payments: " + err.Error(),
+			})
+		}
+
+		result := make([]fiber.Map, 0, len(list))
+		for _, payment := range list {
+			result = append(result, paymentResponse(payment))
+		}
+
 		return c.JSON(fiber.Map{
-			"message": "Payment completed successfully",
-			"payment": fiber.Map{
-				"booking_id":   booking.BookingID,
-				"user_id":      booking.UserID,
-				"field_id":     booking.FieldID,
-				"field_name":   booking.FieldName,
-				"booking_date": booking.BookingDate,
-				"start_time":   booking.StartTime,
-				"end_time":     booking.EndTime,
-				"total_price":  booking.TotalPrice,
-				"status":       booking.Status,
+			"message":  "Payments retrieved successfully",
+			"payments": result,
+			"pagination": fiber.Map{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": (total + limit - 1) / limit,
 			},
 		})
 	}
 }
+
+// Refund returns amount of the booking's successful payment through the
+// gateway and records it. It returns store.ErrNotFound when the booking
+// has no successful payment.
+func Refund(ctx context.Context, payments store.PaymentRepository, gateway PaymentGateway, bookingID int, amount int) (store.Payment, error) {
+	list, err := payments.ListByBooking(ctx, bookingID)
+	if err != nil {
+		return store.Payment{}, err
+	}
+
+	for _, payment := range list {
+		if payment.Status != store.PaymentSucceeded {
+			continue
+		}
+		if payment.Provider != gateway.Name() {
+			return payment, fmt.Errorf("payment %d was made through %s, not %s", payment.PaymentID, payment.Provider, gateway.Name())
+		}
+
+		if _, err := gateway.Refund(ctx, payment.ProviderRef, amount); err != nil {
+			return payment, err
+		}
+		if err := payments.MarkRefunded(ctx, payment.PaymentID, amount); err != nil {
+			return payment, err
+		}
+		return payments.Get(ctx, payment.PaymentID)
+	}
+
+	return store.Payment{}, store.ErrNotFound
+}
+
+// reconcile applies the provider's view of a pending payment to the stored
+// payment and booking. Bookings only become paid here, once the provider
+// has confirmed the money moved.
+func reconcile(ctx context.Context, payments store.PaymentRepository, gateway PaymentGateway, payment store.Payment, intent Intent) (store.Payment, error) {
+	if payment.Status != store.PaymentPending {
+		return payment, nil
+	}
+
+	var err error
+	switch intent.Status {
+	case store.PaymentSucceeded:
+		err = payments.MarkSucceeded(ctx, payment.PaymentID)
+		if errors.Is(err, store.ErrBookingNotPayable) {
+			// The booking was cancelled or expired while the payment was in
+			// flight, so hand the money back.
+			slog.Warn("Payment succeeded for a booking that is no longer pending", "payment_id", payment.PaymentID, "booking_id", payment.BookingID)
+			if _, refundErr := gateway.Refund(ctx, payment.ProviderRef, payment.Amount); refundErr != nil {
+				return payment, refundErr
+			}
+			err = payments.MarkFailed(ctx, payment.PaymentID, "booking is no longer pending, payment refunded")
+		}
+	case store.PaymentFailed:
+		err = payments.MarkFailed(ctx, payment.PaymentID, "declined by provider")
+	default:
+		return payment, nil
+	}
+
+	if err != nil && !errors.Is(err, store.ErrStatusChanged) {
+		return payment, err
+	}
+
+	return payments.Get(ctx, payment.PaymentID)
+}
+
+func paymentResult(c *fiber.Ctx, bookings store.BookingRepository, payment store.Payment) error {
+	booking, err := bookings.Get(c.UserContext(), payment.BookingID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch booking: " + err.Error(),
+		})
+	}
+
+	status, message := fiber.StatusOK, "Payment completed successfully"
+	switch payment.Status {
+	case store.PaymentPending:
+		status, message = fiber.StatusAccepted, "Payment is being processed"
+	case store.PaymentFailed:
+		status, message = fiber.StatusPaymentRequired, "Payment failed"
+	case store.PaymentRefunded:
+		message = "Payment refunded"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"message": message,
+		"payment": paymentResponse(payment),
+		"booking": fiber.Map{
+			"booking_id":   booking.BookingID,
+			"user_id":      booking.UserID,
+			"field_id":     booking.FieldID,
+			"field_name":   booking.FieldName,
+			"booking_date": booking.BookingDate,
+			"start_time":   booking.StartTime,
+			"end_time":     booking.EndTime,
+			"total_price":  booking.TotalPrice,
+			"status":       booking.Status,
+		},
+	})
+}
+
+func paymentResponse(payment store.Payment) fiber.Map {
+	return fiber.Map{
+		"payment_id":      payment.PaymentID,
+		"booking_id":      payment.BookingID,
+		"provider":        payment.Provider,
+		"provider_ref":    payment.ProviderRef,
+		"amount":          payment.Amount,
+		"refunded_amount": payment.RefundedAmount,
+		"currency":        payment.Currency,
+		"status":          payment.Status,
+		"failure_reason":  payment.FailureReason,
+		"created_at":      payment.CreatedAt,
+		"updated_at":      payment.UpdatedAt,
+	}
+}
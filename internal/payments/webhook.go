@@ -0,0 +1,152 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+)
+
+var eventTypes = map[string]string{
+	"payment.succeeded": store.PaymentSucceeded,
+	"payment.failed":    store.PaymentFailed,
+	"payment.refunded":  store.PaymentRefunded,
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>", which
+// providers send in SignatureHeader.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookHandler receives payment events from gateway's provider. Events
+// must be signed with secret and be no older than tolerance; each event
+// ID is applied at most once.
+func WebhookHandler(payments store.PaymentRepository, gateway PaymentGateway, secret string, tolerance time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := c.Body()
+
+		if secret == "" {
+			slog.Error("Rejected payment webhook: webhook secret is not configured")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Webhook is not configured",
+			})
+		}
+
+		timestamp, err := strconv.ParseInt(c.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			slog.Warn("Rejected payment webhook: missing timestamp", "ip", c.IP())
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid signature",
+			})
+		}
+
+		signature, err := hex.DecodeString(c.Get(SignatureHeader))
+		expected, _ := hex.DecodeString(Sign(secret, timestamp, body))
+		if err != nil || !hmac.Equal(signature, expected) {
+			slog.Warn("Rejected payment webhook: bad signature", "ip", c.IP())
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid signature",
+			})
+		}
+
+		// A valid signature on an old timestamp is a replay.
+		age := time.Since(time.Unix(timestamp, 0))
+		if math.Abs(float64(age)) > float64(tolerance) {
+			slog.Warn("Rejected payment webhook: timestamp outside tolerance", "ip", c.IP(), "age", age)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Stale webhook timestamp",
+			})
+		}
+
+		var req struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+			Data struct {
+				ProviderRef string `json:"provider_ref"`
+				Amount      int    `json:"amount"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+
+		eventType, ok := eventTypes[req.Type]
+		if !ok || req.ID == "" || req.Data.ProviderRef == "" {
+			slog.Warn("Rejected payment webhook: malformed event", "event_id", req.ID, "type", req.Type)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid event",
+			})
+		}
+
+		event := store.PaymentEvent{
+			Provider:    gateway.Name(),
+			EventID:     req.ID,
+			Type:        eventType,
+			ProviderRef: req.Data.ProviderRef,
+			Amount:      req.Data.Amount,
+		}
+
+		payment, err := payments.ApplyEvent(c.UserContext(), event)
+		switch {
+		case err == nil:
+			slog.Info("Applied payment webhook", "event_id", event.EventID, "type", req.Type, "payment_id", payment.PaymentID, "status", payment.Status)
+			return c.JSON(fiber.Map{
+				"message": "Event processed",
+				"payment": paymentResponse(payment),
+			})
+
+		case errors.Is(err, store.ErrDuplicateEvent):
+			slog.Warn("Rejected payment webhook: event replayed", "event_id", event.EventID, "ip", c.IP())
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Event already processed",
+			})
+
+		case errors.Is(err, store.ErrNotFound):
+			slog.Warn("Rejected payment webhook: unknown payment", "event_id", event.EventID, "provider_ref", event.ProviderRef)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Payment not found",
+			})
+
+		case errors.Is(err, store.ErrBookingNotPayable):
+			// Same as a synchronous capture for a booking that was cancelled
+			// or expired meanwhile: the money goes back.
+			payment, err = reconcile(c.UserContext(), payments, gateway, payment, Intent{Status: store.PaymentSucceeded})
+			if err != nil {
+				slog.Error("Failed to refund payment for unpayable booking", "event_id", event.EventID, "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to process event",
+				})
+			}
+			return c.JSON(fiber.Map{
+				"message": "Booking is no longer payable, payment refunded",
+				"payment": paymentResponse(payment),
+			})
+
+		default:
+			slog.Error("Failed to apply payment webhook", "event_id", event.EventID, "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to process event",
+			})
+		}
+	}
+}
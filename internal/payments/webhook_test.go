@@ -0,0 +1,143 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testSecret = "whsec_test"
+
+// newWebhookApp serves the webhook for one pending payment of a pending
+// booking, referenced as "pi_1".
+func newWebhookApp(t *testing.T) (*fiber.App, *memory.PaymentRepository, store.Payment) {
+	t.Helper()
+	ctx := context.Background()
+
+	db := memory.NewDB()
+	bookings := memory.NewBookingRepository(db)
+	payments := memory.NewPaymentRepository(db)
+
+	expiresAt := time.Now().Add(time.Hour)
+	booking := store.Booking{
+		UserID: 1, FieldID: 1, BookingDate: "2030-01-07", StartTime: "10:00", EndTime: "11:00",
+		TotalPrice: 100000, Status: store.BookingPending, ExpiresAt: &expiresAt,
+	}
+	if err := bookings.Create(ctx, &booking); err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	payment := store.Payment{
+		BookingID: booking.BookingID, Provider: "mock", ProviderRef: "pi_1",
+		Amount: 100000, Currency: "IDR", Status: store.PaymentPending,
+	}
+	if err := payments.Create(ctx, &payment); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+
+	app := fiber.New()
+	app.Post("/payments/webhook", WebhookHandler(payments, NewMockGateway(), testSecret, 5*time.Minute))
+	return app, payments, payment
+}
+
+// deliver posts body signed with secret at timestamp and returns the status
+// and decoded response.
+func deliver(t *testing.T, app *fiber.App, secret string, timestamp time.Time, body string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp.Unix(), []byte(body)))
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+
+	var result map[string]any
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("decode %q: %v", raw, err)
+	}
+	return resp.StatusCode, result
+}
+
+const succeeded = `{"id":"evt_1","type":"payment.succeeded","data":{"provider_ref":"pi_1","amount":100000}}`
+
+func TestWebhookRejectsUnverifiedEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		want      int
+	}{
+		{"bad signature", "someone else's secret", time.Now(), fiber.StatusUnauthorized},
+		{"stale timestamp", testSecret, time.Now().Add(-10 * time.Minute), fiber.StatusUnauthorized},
+		{"timestamp from the future", testSecret, time.Now().Add(10 * time.Minute), fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, payments, payment := newWebhookApp(t)
+
+			if status, result := deliver(t, app, tt.secret, tt.timestamp, succeeded); status != tt.want {
+				t.Fatalf("status %d, want %d, body %v", status, tt.want, result)
+			}
+			got, err := payments.Get(context.Background(), payment.PaymentID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != store.PaymentPending {
+				t.Errorf("payment is %s after a rejected event, want pending", got.Status)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsTamperedBody(t *testing.T) {
+	app, _, _ := newWebhookApp(t)
+	timestamp := time.Now()
+
+	req := httptest.NewRequest("POST", "/payments/webhook", strings.NewReader(strings.Replace(succeeded, "100000", "1", 1)))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(testSecret, timestamp.Unix(), []byte(succeeded)))
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestWebhookSkipsReplayedEvent(t *testing.T) {
+	app, payments, payment := newWebhookApp(t)
+
+	if status, result := deliver(t, app, testSecret, time.Now(), succeeded); status != fiber.StatusOK {
+		t.Fatalf("first delivery: status %d, body %v", status, result)
+	}
+	if status, result := deliver(t, app, testSecret, time.Now(), succeeded); status != fiber.StatusConflict {
+		t.Fatalf("replay: status %d, body %v", status, result)
+	}
+
+	// A replay of the same ID with another type is skipped too.
+	refunded := `{"id":"evt_1","type":"payment.refunded","data":{"provider_ref":"pi_1","amount":100000}}`
+	if status, result := deliver(t, app, testSecret, time.Now(), refunded); status != fiber.StatusConflict {
+		t.Fatalf("replay as refund: status %d, body %v", status, result)
+	}
+	got, err := payments.Get(context.Background(), payment.PaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != store.PaymentSucceeded || got.RefundedAmount != 0 {
+		t.Errorf("payment is %s with %d refunded, want succeeded with nothing refunded", got.Status, got.RefundedAmount)
+	}
+}
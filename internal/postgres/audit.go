@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"take-home-test/internal/store"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+var _ store.AuditRepository = (*AuditRepository)(nil)
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, e *store.AuditEntry) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO admin_audit_log (actor_id, action, target_user_id, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING audit_id, created_at
+	`, e.ActorID, e.Action, e.TargetUserID, e.Details).Scan(&e.AuditID, &e.CreatedAt)
+}
+
+func (r *AuditRepository) List(ctx context.Context, limit, offset int) ([]store.AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT audit_id, actor_id, action, target_user_id, details, created_at
+		FROM admin_audit_log
+		ORDER BY audit_id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []store.AuditEntry
+	for rows.Next() {
+		var e store.AuditEntry
+		if err := rows.Scan(&e.AuditID, &e.ActorID, &e.Action, &e.TargetUserID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,520 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// overlapConstraint is the exclusion constraint that rejects overlapping
+// pending/paid bookings on the same field.
+const overlapConstraint = "bookings_no_overlap"
+
+const selectBooking = `
+	SELECT
+		b.booking_id, b.user_id, b.field_id, f.name, f.location,
+		to_char(b.booking_date, 'YYYY-MM-DD'),
+		to_char(b.start_time, 'HH24:MI'),
+		to_char(b.end_time, 'HH24:MI'),
+		b.total_price, b.status, COALESCE(b.refund_amount, 0), b.cancelled_at,
+		b.expires_at, b.checked_in_at, b.price_breakdown, b.discount_amount, COALESCE(b.promo_code, ''),
+		b.series_id, b.price_adjustment, b.reschedule_count, b.created_at
+	FROM bookings b
+	JOIN fields f ON b.field_id = f.field_id
+`
+
+// promoRedeemedCondition matches bookings that use up a redemption of their
+// promo code: everything but cancelled, expired, refunded and lapsed holds.
+const promoRedeemedCondition = `(status IN ('paid', 'completed') OR (status = 'pending' AND (expires_at IS NULL OR expires_at > now())))`
+
+type BookingRepository struct {
+	db *sql.DB
+}
+
+var _ store.BookingRepository = (*BookingRepository)(nil)
+
+func NewBookingRepository(db *sql.DB) *BookingRepository {
+	return &BookingRepository{db: db}
+}
+
+func (r *BookingRepository) IsAvailable(ctx context.Context, fieldID int, bookingDate, startTime, endTime string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM bookings
+		WHERE field_id = $1
+		AND booking_date = $2
+		AND (status = 'paid' OR (status = 'pending' AND (expires_at IS NULL OR expires_at > now())))
+		AND (start_time, end_time) OVERLAPS ($3::time, $4::time)
+	`, fieldID, bookingDate, startTime, endTime).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
+
+func (r *BookingRepository) Busy(ctx context.Context, fieldID int, from, to string) ([]store.BusySlot, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT to_char(booking_date, 'YYYY-MM-DD'), to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI')
+		FROM bookings
+		WHERE field_id = $1
+		AND booking_date BETWEEN $2 AND $3
+		AND (status = 'paid' OR (status = 'pending' AND (expires_at IS NULL OR expires_at > now())))
+		ORDER BY booking_date, start_time
+	`, fieldID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []store.BusySlot
+	for rows.Next() {
+		var s store.BusySlot
+		if err := rows.Scan(&s.Date, &s.StartTime, &s.EndTime); err != nil {
+			return nil, err
+		}
+		slots = append(slots, s)
+	}
+
+	return slots, rows.Err()
+}
+
+func (r *BookingRepository) Create(ctx context.Context, b *store.Booking) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertBooking(ctx, tx, b); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BookingRepository) CreateSeries(ctx context.Context, s *store.BookingSeries, occurrences []store.Booking) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO booking_series (user_id, field_id, rule, start_date, start_time, end_time)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING series_id, created_at
+	`, s.UserID, s.FieldID, s.Rule, s.StartDate, s.StartTime, s.EndTime).Scan(&s.SeriesID, &s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range occurrences {
+		occurrences[i].SeriesID = &s.SeriesID
+		if err := insertBooking(ctx, tx, &occurrences[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *BookingRepository) GetSeries(ctx context.Context, seriesID int) (store.BookingSeries, error) {
+	var s store.BookingSeries
+	err := r.db.QueryRowContext(ctx, `
+		SELECT series_id, user_id, field_id, rule, to_char(start_date, 'YYYY-MM-DD'),
+			to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), created_at
+		FROM booking_series
+		WHERE series_id = $1
+	`, seriesID).Scan(&s.SeriesID, &s.UserID, &s.FieldID, &s.Rule, &s.StartDate, &s.StartTime, &s.EndTime, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, store.ErrNotFound
+	}
+	return s, err
+}
+
+func (r *BookingRepository) Reschedule(ctx context.Context, b *store.Booking, fromStatus string, change *store.BookingReschedule) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var rescheduleCount int
+	err = tx.QueryRowContext(ctx, `
+		SELECT field_id, to_char(booking_date, 'YYYY-MM-DD'), to_char(start_time, 'HH24:MI'),
+			to_char(end_time, 'HH24:MI'), total_price, status, reschedule_count
+		FROM bookings
+		WHERE booking_id = $1
+		FOR UPDATE
+	`, b.BookingID).Scan(&change.FromFieldID, &change.FromDate, &change.FromStart, &change.FromEnd,
+		&change.OldPrice, &status, &rescheduleCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != fromStatus || rescheduleCount != b.RescheduleCount {
+		return store.ErrStatusChanged
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'expired'
+		WHERE field_id = $1
+		AND status = ANY($6)
+		AND expires_at <= now()
+		AND period && tsrange($2::date + $3::time, $2::date + $4::time, '[)')
+		AND booking_id <> $5
+	`, b.FieldID, b.BookingDate, b.StartTime, b.EndTime, b.BookingID, pq.Array(store.AllowedFrom(store.BookingExpired)))
+	if err != nil {
+		return err
+	}
+
+	breakdown, err := priceBreakdownJSON(b.PriceBreakdown)
+	if err != nil {
+		return err
+	}
+
+	// The exclusion constraint compares the new period with other rows
+	// only, so the booking never conflicts with its old slot.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET field_id = $2, booking_date = $3, start_time = $4, end_time = $5,
+			total_price = $6, price_breakdown = $7, discount_amount = $8, price_adjustment = $9,
+			reschedule_count = reschedule_count + 1
+		WHERE booking_id = $1
+	`, b.BookingID, b.FieldID, b.BookingDate, b.StartTime, b.EndTime,
+		b.TotalPrice, breakdown, b.DiscountAmount, b.PriceAdjustment)
+	if isConstraintViolation(err, "23P01", overlapConstraint) {
+		return store.ErrSlotUnavailable
+	}
+	if err != nil {
+		return err
+	}
+
+	change.BookingID = b.BookingID
+	change.ToFieldID, change.ToDate, change.ToStart, change.ToEnd = b.FieldID, b.BookingDate, b.StartTime, b.EndTime
+	change.NewPrice = b.TotalPrice
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO booking_reschedules (booking_id, actor_id, from_field_id, from_date, from_start, from_end,
+			to_field_id, to_date, to_start, to_end, old_price, new_price)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING reschedule_id, created_at
+	`, change.BookingID, change.ActorID, change.FromFieldID, change.FromDate, change.FromStart, change.FromEnd,
+		change.ToFieldID, change.ToDate, change.ToStart, change.ToEnd, change.OldPrice, change.NewPrice,
+	).Scan(&change.RescheduleID, &change.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	b.RescheduleCount++
+	return nil
+}
+
+// insertBooking stores b within tx, redeeming its promo code if it has one.
+func insertBooking(ctx context.Context, tx *sql.Tx, b *store.Booking) error {
+	var promoID *int
+	if b.PromoCode != "" {
+		id, err := redeemPromo(ctx, tx, b.PromoCode, b.UserID)
+		if err != nil {
+			return err
+		}
+		promoID = &id
+	}
+
+	// Holds that have lapsed but not been swept yet still count for the
+	// exclusion constraint, so release the ones in the way first.
+	_, err := tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'expired'
+		WHERE field_id = $1
+		AND status = ANY($5)
+		AND expires_at <= now()
+		AND period && tsrange($2::date + $3::time, $2::date + $4::time, '[)')
+	`, b.FieldID, b.BookingDate, b.StartTime, b.EndTime, pq.Array(store.AllowedFrom(store.BookingExpired)))
+	if err != nil {
+		return err
+	}
+
+	breakdown, err := priceBreakdownJSON(b.PriceBreakdown)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO bookings (user_id, field_id, booking_date, start_time, end_time, total_price, price_breakdown,
+			promo_id, promo_code, discount_amount, series_id, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13)
+		RETURNING booking_id, created_at
+	`, b.UserID, b.FieldID, b.BookingDate, b.StartTime, b.EndTime, b.TotalPrice, breakdown,
+		promoID, b.PromoCode, b.DiscountAmount, b.SeriesID, b.Status, b.ExpiresAt).Scan(&b.BookingID, &b.CreatedAt)
+	if isConstraintViolation(err, "23P01", overlapConstraint) {
+		return store.ErrSlotUnavailable
+	}
+	return err
+}
+
+// redeemPromo locks the promo code for the rest of tx, so concurrent
+// bookings and edits of the code are handled one at a time, and checks
+// that it is still active and valid and that userID may use it once more.
+// It returns the code's ID.
+func redeemPromo(ctx context.Context, tx *sql.Tx, code string, userID int) (int, error) {
+	var promoID, maxRedemptions, perUserLimit int
+	var valid bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT promo_id, max_redemptions, per_user_limit,
+			active
+			AND (valid_from IS NULL OR valid_from <= now())
+			AND (valid_until IS NULL OR valid_until > now())
+		FROM promo_codes
+		WHERE code = upper($1)
+		FOR UPDATE
+	`, code).Scan(&promoID, &maxRedemptions, &perUserLimit, &valid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, store.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !valid {
+		return 0, store.ErrPromoUnavailable
+	}
+
+	var total, byUser int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+		FROM bookings
+		WHERE promo_id = $1
+		AND `+promoRedeemedCondition+`
+	`, promoID, userID).Scan(&total, &byUser)
+	if err != nil {
+		return 0, err
+	}
+
+	if maxRedemptions > 0 && total >= maxRedemptions {
+		return 0, store.ErrPromoExhausted
+	}
+	if perUserLimit > 0 && byUser >= perUserLimit {
+		return 0, store.ErrPromoUserLimit
+	}
+	return promoID, nil
+}
+
+func (r *BookingRepository) Get(ctx context.Context, bookingID int) (store.Booking, error) {
+	row := r.db.QueryRowContext(ctx, selectBooking+" WHERE b.booking_id = $1", bookingID)
+	return scanBooking(row)
+}
+
+func scanBooking(row interface{ Scan(...any) error }) (store.Booking, error) {
+	var b store.Booking
+	var breakdown []byte
+	err := row.Scan(
+		&b.BookingID,
+		&b.UserID,
+		&b.FieldID,
+		&b.FieldName,
+		&b.Location,
+		&b.BookingDate,
+		&b.StartTime,
+		&b.EndTime,
+		&b.TotalPrice,
+		&b.Status,
+		&b.RefundAmount,
+		&b.CancelledAt,
+		&b.ExpiresAt,
+		&b.CheckedInAt,
+		&breakdown,
+		&b.DiscountAmount,
+		&b.PromoCode,
+		&b.SeriesID,
+		&b.PriceAdjustment,
+		&b.RescheduleCount,
+		&b.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b, store.ErrNotFound
+	}
+	if err == nil && breakdown != nil {
+		err = json.Unmarshal(breakdown, &b.PriceBreakdown)
+	}
+	return b, err
+}
+
+// priceBreakdownJSON encodes segments for the price_breakdown column, or
+// returns nil to store NULL when there are none.
+func priceBreakdownJSON(segments []store.PriceSegment) (any, error) {
+	if segments == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(segments)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *BookingRepository) Cancel(ctx context.Context, bookingID int, fromStatus string, refundAmount int) error {
+	if !store.CanTransition(fromStatus, store.BookingCancelled) {
+		return store.ErrStatusChanged
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'cancelled', refund_amount = $3, cancelled_at = now()
+		WHERE booking_id = $1 AND status = $2
+	`, bookingID, fromStatus, refundAmount)
+	if err != nil {
+		return err
+	}
+	return r.expectTransition(ctx, result, bookingID)
+}
+
+func (r *BookingRepository) CheckIn(ctx context.Context, bookingID int, at time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE bookings
+		SET checked_in_at = $2
+		WHERE booking_id = $1 AND status = 'paid' AND checked_in_at IS NULL
+	`, bookingID, at)
+	if err != nil {
+		return err
+	}
+	return r.expectTransition(ctx, result, bookingID)
+}
+
+func (r *BookingRepository) ExpirePending(ctx context.Context, now time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'expired'
+		WHERE status = ANY($2) AND expires_at <= $1
+	`, now, pq.Array(store.AllowedFrom(store.BookingExpired)))
+	if err != nil {
+		return 0, err
+	}
+
+	expired, err := result.RowsAffected()
+	return int(expired), err
+}
+
+func (r *BookingRepository) CompleteFinished(ctx context.Context, now time.Time) (int, error) {
+	// Booking times are local wall-clock times, so compare against the
+	// local wall clock rather than the session time zone.
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'completed'
+		WHERE status = ANY($2) AND booking_date + end_time <= $1::timestamp
+	`, now.In(time.Local).Format("2006-01-02 15:04:05"), pq.Array(store.AllowedFrom(store.BookingCompleted)))
+	if err != nil {
+		return 0, err
+	}
+
+	completed, err := result.RowsAffected()
+	return int(completed), err
+}
+
+// expectTransition turns a compare-and-set update that touched no rows into
+// ErrNotFound or ErrStatusChanged.
+func (r *BookingRepository) expectTransition(ctx context.Context, result sql.Result, bookingID int) error {
+	err := expectRows(result)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM bookings WHERE booking_id = $1)", bookingID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrStatusChanged
+	}
+	return store.ErrNotFound
+}
+
+var bookingSortColumns = map[string]string{
+	store.BookingSortDate:       "b.booking_date, b.start_time",
+	store.BookingSortCreatedAt:  "b.created_at",
+	store.BookingSortTotalPrice: "b.total_price",
+}
+
+func (r *BookingRepository) List(ctx context.Context, filter store.BookingFilter) ([]store.Booking, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		addCondition("b.user_id = $%d", filter.UserID)
+	}
+	if filter.SeriesID != 0 {
+		addCondition("b.series_id = $%d", filter.SeriesID)
+	}
+	if filter.Status != "" {
+		addCondition("b.status = $%d", filter.Status)
+	}
+	if filter.DateFrom != "" {
+		addCondition("b.booking_date >= $%d", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		addCondition("b.booking_date <= $%d", filter.DateTo)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM bookings b"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orderBy, ok := bookingSortColumns[filter.SortBy]
+	if !ok {
+		orderBy = bookingSortColumns[store.BookingSortDate]
+	}
+	direction := " ASC"
+	if filter.SortDesc {
+		direction = " DESC"
+	}
+	orderBy = strings.ReplaceAll(orderBy, ",", direction+",") + direction + ", b.booking_id" + direction
+
+	query := selectBooking + where + " ORDER BY " + orderBy
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var bookings []store.Booking
+	for rows.Next() {
+		b, err := scanBooking(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		bookings = append(bookings, b)
+	}
+
+	return bookings, total, rows.Err()
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+func TestBookingCreateConcurrentSameSlot(t *testing.T) {
+	db := openTestDB(t)
+	user := createTestUser(t, db)
+	field := createTestField(t, db)
+	bookings := NewBookingRepository(db)
+
+	const attempts = 20
+	expiresAt := time.Now().Add(time.Hour)
+	errs := make([]error, attempts)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			b := store.Booking{
+				UserID:      user.UserID,
+				FieldID:     field.FieldID,
+				BookingDate: "2030-01-07",
+				// Every attempt overlaps every other one, whether or not
+				// the windows are identical.
+				StartTime:  []string{"10:00", "10:30"}[i%2],
+				EndTime:    []string{"11:00", "11:30"}[i%2],
+				TotalPrice: 100000,
+				Status:     store.BookingPending,
+				ExpiresAt:  &expiresAt,
+			}
+			errs[i] = bookings.Create(context.Background(), &b)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, store.ErrSlotUnavailable):
+		default:
+			t.Errorf("attempt %d: unexpected error %v", i, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d bookings succeeded, want exactly 1", succeeded)
+	}
+}
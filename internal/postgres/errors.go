@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"take-home-test/internal/store"
+
+	"github.com/lib/pq"
+)
+
+func isConstraintViolation(err error, code pq.ErrorCode, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == code && (constraint == "" || pqErr.Constraint == constraint)
+}
+
+func isUniqueViolation(err error) bool {
+	return isConstraintViolation(err, "23505", "")
+}
+
+func expectRows(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type FieldRepository struct {
+	db *sql.DB
+}
+
+var _ store.FieldRepository = (*FieldRepository)(nil)
+
+func NewFieldRepository(db *sql.DB) *FieldRepository {
+	return &FieldRepository{db: db}
+}
+
+func (r *FieldRepository) Create(ctx context.Context, f *store.Field) error {
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO fields (name, price_per_hour, location) VALUES ($1, $2, $3)
+		RETURNING field_id, slot_minutes, min_minutes, max_minutes`,
+		f.Name, f.PricePerHour, f.Location,
+	).Scan(&f.FieldID, &f.Rules.SlotMinutes, &f.Rules.MinMinutes, &f.Rules.MaxMinutes)
+}
+
+func (r *FieldRepository) List(ctx context.Context) ([]store.Field, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT field_id, name, price_per_hour, location, slot_minutes, min_minutes, max_minutes
+		FROM fields
+		ORDER BY field_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fields []store.Field
+	for rows.Next() {
+		var f store.Field
+		if err := rows.Scan(&f.FieldID, &f.Name, &f.PricePerHour, &f.Location, &f.Rules.SlotMinutes, &f.Rules.MinMinutes, &f.Rules.MaxMinutes); err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, rows.Err()
+}
+
+func (r *FieldRepository) Get(ctx context.Context, fieldID int) (store.Field, error) {
+	var f store.Field
+	err := r.db.QueryRowContext(ctx, `
+		SELECT field_id, name, price_per_hour, location, slot_minutes, min_minutes, max_minutes
+		FROM fields
+		WHERE field_id = $1
+	`, fieldID).Scan(&f.FieldID, &f.Name, &f.PricePerHour, &f.Location, &f.Rules.SlotMinutes, &f.Rules.MinMinutes, &f.Rules.MaxMinutes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return f, store.ErrNotFound
+	}
+	return f, err
+}
+
+func (r *FieldRepository) Update(ctx context.Context, f store.Field) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE fields
+		SET name = $1, price_per_hour = $2, location = $3
+		WHERE field_id = $4
+	`, f.Name, f.PricePerHour, f.Location, f.FieldID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *FieldRepository) Delete(ctx context.Context, fieldID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM fields WHERE field_id = $1", fieldID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *FieldRepository) SetRules(ctx context.Context, fieldID int, rules store.SlotRules) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE fields
+		SET slot_minutes = $1, min_minutes = $2, max_minutes = $3
+		WHERE field_id = $4
+	`, rules.SlotMinutes, rules.MinMinutes, rules.MaxMinutes, fieldID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *FieldRepository) Hours(ctx context.Context, fieldID int) ([]store.OpeningHours, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT weekday, to_char(opens, 'HH24:MI'), to_char(closes, 'HH24:MI')
+		FROM field_opening_hours
+		WHERE field_id = $1
+		ORDER BY weekday
+	`, fieldID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hours []store.OpeningHours
+	for rows.Next() {
+		var h store.OpeningHours
+		if err := rows.Scan(&h.Weekday, &h.Opens, &h.Closes); err != nil {
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+
+	return hours, rows.Err()
+}
+
+func (r *FieldRepository) SetHours(ctx context.Context, fieldID int, hours []store.OpeningHours) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the field row serialises concurrent replacements and fails
+	// cleanly when the field is gone.
+	var id int
+	err = tx.QueryRowContext(ctx, "SELECT field_id FROM fields WHERE field_id = $1 FOR UPDATE", fieldID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM field_opening_hours WHERE field_id = $1", fieldID); err != nil {
+		return err
+	}
+	for _, h := range hours {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO field_opening_hours (field_id, weekday, opens, closes) VALUES ($1, $2, $3, $4)
+		`, fieldID, int(h.Weekday), h.Opens, h.Closes); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *FieldRepository) Exceptions(ctx context.Context, fieldID int, from, to string) ([]store.FieldException, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT field_id, to_char(date, 'YYYY-MM-DD'), opens IS NULL,
+			COALESCE(to_char(opens, 'HH24:MI'), ''), COALESCE(to_char(closes, 'HH24:MI'), ''), reason
+		FROM field_exceptions
+		WHERE field_id = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date
+	`, fieldID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exceptions []store.FieldException
+	for rows.Next() {
+		var e store.FieldException
+		if err := rows.Scan(&e.FieldID, &e.Date, &e.Closed, &e.Opens, &e.Closes, &e.Reason); err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, e)
+	}
+
+	return exceptions, rows.Err()
+}
+
+func (r *FieldRepository) SetException(ctx context.Context, e store.FieldException) error {
+	var opens, closes any
+	if !e.Closed {
+		opens, closes = e.Opens, e.Closes
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO field_exceptions (field_id, date, opens, closes, reason) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (field_id, date) DO UPDATE SET opens = $3, closes = $4, reason = $5
+	`, e.FieldID, e.Date, opens, closes, e.Reason)
+	if isConstraintViolation(err, "23503", "") {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (r *FieldRepository) DeleteException(ctx context.Context, fieldID int, date string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM field_exceptions WHERE field_id = $1 AND date = $2", fieldID, date)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *FieldRepository) CreateBlackout(ctx context.Context, b *store.FieldBlackout) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO field_blackouts (field_id, starts_at, ends_at, reason) VALUES ($1, $2, $3, $4)
+		RETURNING blackout_id, created_at
+	`, b.FieldID, b.StartsAt, b.EndsAt, b.Reason).Scan(&b.BlackoutID, &b.CreatedAt)
+	if isConstraintViolation(err, "23503", "") {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (r *FieldRepository) Blackouts(ctx context.Context, fieldID int, from, to time.Time) ([]store.FieldBlackout, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT blackout_id, field_id, starts_at, ends_at, reason, created_at
+		FROM field_blackouts
+		WHERE field_id = $1 AND starts_at < $3 AND ends_at > $2
+		ORDER BY starts_at
+	`, fieldID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blackouts []store.FieldBlackout
+	for rows.Next() {
+		var b store.FieldBlackout
+		if err := rows.Scan(&b.BlackoutID, &b.FieldID, &b.StartsAt, &b.EndsAt, &b.Reason, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blackouts = append(blackouts, b)
+	}
+
+	return blackouts, rows.Err()
+}
+
+func (r *FieldRepository) DeleteBlackout(ctx context.Context, fieldID, blackoutID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM field_blackouts WHERE field_id = $1 AND blackout_id = $2", fieldID, blackoutID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+var _ store.LoginAttemptRepository = (*LoginAttemptRepository)(nil)
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Attempt(ctx context.Context, keys []string, now time.Time, window time.Duration, lockFor func(key string, failures int) time.Duration) ([]store.LoginCounter, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Make sure every counter has a row to lock. Rows are locked in key
+	// order so two attempts cannot deadlock.
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		SELECT unnest($1::text[]), 0, 'epoch'
+		ON CONFLICT (key) DO NOTHING
+	`, pq.Array(keys))
+	if err != nil {
+		return nil, false, err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key = ANY($1)
+		ORDER BY key
+		FOR UPDATE
+	`, pq.Array(keys))
+	if err != nil {
+		return nil, false, err
+	}
+	byKey := make(map[string]store.LoginCounter, len(keys))
+	for rows.Next() {
+		c, err := scanLoginCounter(rows)
+		if err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		byKey[c.Key] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	counters := make([]store.LoginCounter, len(keys))
+	locked := false
+	for i, key := range keys {
+		counters[i] = byKey[key]
+		if c := counters[i]; c.LockedUntil != nil && c.LockedUntil.After(now) {
+			locked = true
+		}
+	}
+	if locked {
+		return counters, false, nil
+	}
+
+	for i, c := range counters {
+		if !c.LastFailureAt.After(now.Add(-window)) {
+			c.Failures = 0
+		}
+		c.Failures++
+		c.LastFailureAt = now
+		c.LockedUntil = nil
+		if lock := lockFor(c.Key, c.Failures); lock > 0 {
+			until := now.Add(lock)
+			c.LockedUntil = &until
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE login_attempts SET failures = $2, last_failure_at = $3, locked_until = $4 WHERE key = $1
+		`, c.Key, c.Failures, c.LastFailureAt, c.LockedUntil)
+		if err != nil {
+			return nil, false, err
+		}
+		counters[i] = c
+	}
+
+	return counters, true, tx.Commit()
+}
+
+func (r *LoginAttemptRepository) Forgive(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE login_attempts SET failures = failures - 1 WHERE key = $1 AND failures > 0
+	`, key)
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM login_attempts
+		WHERE last_failure_at <= $1 AND (locked_until IS NULL OR locked_until <= $1)
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func scanLoginCounter(row interface{ Scan(...any) error }) (store.LoginCounter, error) {
+	var c store.LoginCounter
+	err := row.Scan(&c.Key, &c.Failures, &c.LastFailureAt, &c.LockedUntil)
+	return c, err
+}
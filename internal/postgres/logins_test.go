@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginAttemptConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	attempts := NewLoginAttemptRepository(db)
+
+	const (
+		parallel    = 20
+		maxFailures = 5
+	)
+	suffix := time.Now().UnixNano()
+	keys := []string{fmt.Sprintf("account:test%d@example.com", suffix), fmt.Sprintf("ip:test%d", suffix)}
+	lockFor := func(key string, failures int) time.Duration {
+		if key == keys[0] && failures >= maxFailures {
+			return time.Hour
+		}
+		return 0
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	start := make(chan struct{})
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, ok, err := attempts.Attempt(context.Background(), keys, time.Now(), time.Hour, lockFor)
+			if err != nil {
+				t.Errorf("Attempt: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if allowed != maxFailures {
+		t.Errorf("%d attempts allowed, want %d", allowed, maxFailures)
+	}
+}
@@ -0,0 +1,325 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"take-home-test/internal/store"
+
+	"github.com/lib/pq"
+)
+
+const selectPayment = `
+	SELECT
+		payment_id, booking_id, provider, provider_ref, amount, refunded_amount,
+		currency, status, COALESCE(failure_reason, ''), created_at, updated_at
+	FROM payments
+`
+
+type PaymentRepository struct {
+	db *sql.DB
+}
+
+var _ store.PaymentRepository = (*PaymentRepository)(nil)
+
+func NewPaymentRepository(db *sql.DB) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+const (
+	oneOpenPaymentIndex    = "payments_one_open_per_booking_idx"
+	oneSuccessPaymentIndex = "payments_one_success_per_booking_idx"
+)
+
+func (r *PaymentRepository) Create(ctx context.Context, p *store.Payment) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO payments (booking_id, provider, provider_ref, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING payment_id, created_at, updated_at
+	`, p.BookingID, p.Provider, p.ProviderRef, p.Amount, p.Currency, p.Status).Scan(&p.PaymentID, &p.CreatedAt, &p.UpdatedAt)
+	if isConstraintViolation(err, "23505", oneOpenPaymentIndex) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *PaymentRepository) Get(ctx context.Context, paymentID int) (store.Payment, error) {
+	return scanPayment(r.db.QueryRowContext(ctx, selectPayment+" WHERE payment_id = $1", paymentID))
+}
+
+func (r *PaymentRepository) List(ctx context.Context, filter store.PaymentFilter) ([]store.Payment, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		addCondition("booking_id IN (SELECT booking_id FROM bookings WHERE user_id = $%d)", filter.UserID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM payments"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := selectPayment + where + " ORDER BY payment_id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var payments []store.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, total, rows.Err()
+}
+
+func (r *PaymentRepository) ListByBooking(ctx context.Context, bookingID int) ([]store.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, selectPayment+" WHERE booking_id = $1 ORDER BY payment_id DESC", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []store.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+func (r *PaymentRepository) MarkSucceeded(ctx context.Context, paymentID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, selectPayment+" WHERE payment_id = $1 FOR UPDATE", paymentID))
+	if err != nil {
+		return err
+	}
+	if payment.Status != store.PaymentPending {
+		return store.ErrStatusChanged
+	}
+
+	if err := succeedPayment(ctx, tx, payment); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// succeedPayment moves the locked, pending payment to succeeded and its
+// booking to paid. The booking is updated first, so a booking that is no
+// longer pending, including one already paid by another payment, leaves
+// the payment untouched and returns ErrBookingNotPayable.
+func succeedPayment(ctx context.Context, tx *sql.Tx, payment store.Payment) error {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE bookings
+		SET status = 'paid', expires_at = NULL
+		WHERE booking_id = $1 AND status = ANY($2)
+	`, payment.BookingID, pq.Array(store.AllowedFrom(store.BookingPaid)))
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.ErrBookingNotPayable
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments SET status = 'succeeded', updated_at = now() WHERE payment_id = $1
+	`, payment.PaymentID)
+	if isConstraintViolation(err, "23505", oneSuccessPaymentIndex) {
+		return store.ErrBookingNotPayable
+	}
+	return err
+}
+
+func (r *PaymentRepository) MarkFailed(ctx context.Context, paymentID int, reason string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE payments
+		SET status = 'failed', failure_reason = $2, updated_at = now()
+		WHERE payment_id = $1 AND status = 'pending'
+	`, paymentID, reason)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return r.missingOrChanged(ctx, paymentID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *PaymentRepository) MarkRefunded(ctx context.Context, paymentID int, amount int) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE payments
+		SET status = 'refunded', refunded_amount = refunded_amount + $2, updated_at = now()
+		WHERE payment_id = $1 AND status IN ('succeeded', 'refunded')
+	`, paymentID, amount)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return r.missingOrChanged(ctx, paymentID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *PaymentRepository) ApplyEvent(ctx context.Context, event store.PaymentEvent) (store.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return store.Payment{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_events (provider, event_id, type, provider_ref)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`, event.Provider, event.EventID, event.Type, event.ProviderRef)
+	if err != nil {
+		return store.Payment{}, err
+	}
+	if err := expectRows(result); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.Payment{}, store.ErrDuplicateEvent
+		}
+		return store.Payment{}, err
+	}
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx,
+		selectPayment+" WHERE provider = $1 AND provider_ref = $2 FOR UPDATE",
+		event.Provider, event.ProviderRef,
+	))
+	if err != nil {
+		return payment, err
+	}
+
+	switch event.Type {
+	case store.PaymentSucceeded:
+		if payment.Status != store.PaymentPending {
+			break
+		}
+		if err := succeedPayment(ctx, tx, payment); err != nil {
+			return payment, err
+		}
+
+	case store.PaymentFailed:
+		if payment.Status != store.PaymentPending {
+			break
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments
+			SET status = 'failed', failure_reason = 'reported failed by provider', updated_at = now()
+			WHERE payment_id = $1
+		`, payment.PaymentID)
+		if err != nil {
+			return payment, err
+		}
+
+	case store.PaymentRefunded:
+		if payment.Status != store.PaymentSucceeded && payment.Status != store.PaymentRefunded {
+			break
+		}
+		refunded := event.Amount
+		if refunded <= 0 || refunded > payment.Amount {
+			refunded = payment.Amount
+		}
+		if refunded <= payment.RefundedAmount {
+			break
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments
+			SET status = 'refunded', refunded_amount = $2, updated_at = now()
+			WHERE payment_id = $1
+		`, payment.PaymentID, refunded)
+		if err != nil {
+			return payment, err
+		}
+		if refunded == payment.Amount {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE bookings SET status = 'refunded' WHERE booking_id = $1 AND status = ANY($2)
+			`, payment.BookingID, pq.Array(store.AllowedFrom(store.BookingRefunded)))
+			if err != nil {
+				return payment, err
+			}
+		}
+
+	default:
+		return payment, fmt.Errorf("unknown payment event type %q", event.Type)
+	}
+
+	payment, err = scanPayment(tx.QueryRowContext(ctx, selectPayment+" WHERE payment_id = $1", payment.PaymentID))
+	if err != nil {
+		return payment, err
+	}
+
+	return payment, tx.Commit()
+}
+
+func (r *PaymentRepository) missingOrChanged(ctx context.Context, paymentID int) error {
+	if _, err := r.Get(ctx, paymentID); err != nil {
+		return err
+	}
+	return store.ErrStatusChanged
+}
+
+func scanPayment(row interface{ Scan(...any) error }) (store.Payment, error) {
+	var p store.Payment
+	err := row.Scan(
+		&p.PaymentID,
+		&p.BookingID,
+		&p.Provider,
+		&p.ProviderRef,
+		&p.Amount,
+		&p.RefundedAmount,
+		&p.Currency,
+		&p.Status,
+		&p.FailureReason,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, store.ErrNotFound
+	}
+	return p, err
+}
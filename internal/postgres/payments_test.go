@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+func createTestBooking(t *testing.T, bookings *BookingRepository, user store.User, field store.Field) store.Booking {
+	t.Helper()
+
+	expiresAt := time.Now().Add(time.Hour)
+	b := store.Booking{
+		UserID: user.UserID, FieldID: field.FieldID, BookingDate: "2030-01-07", StartTime: "10:00", EndTime: "11:00",
+		TotalPrice: 100000, Status: store.BookingPending, ExpiresAt: &expiresAt,
+	}
+	if err := bookings.Create(context.Background(), &b); err != nil {
+		t.Fatalf("create booking: %v", err)
+	}
+	return b
+}
+
+func testPayment(bookingID int) store.Payment {
+	return store.Payment{
+		BookingID: bookingID, Provider: "test", ProviderRef: fmt.Sprintf("ref-%d", time.Now().UnixNano()),
+		Amount: 100000, Currency: "IDR", Status: store.PaymentPending,
+	}
+}
+
+func TestPaymentCreateRejectsSecondOpenPayment(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	booking := createTestBooking(t, NewBookingRepository(db), createTestUser(t, db), createTestField(t, db))
+	payments := NewPaymentRepository(db)
+
+	first := testPayment(booking.BookingID)
+	if err := payments.Create(ctx, &first); err != nil {
+		t.Fatalf("create first payment: %v", err)
+	}
+	second := testPayment(booking.BookingID)
+	if err := payments.Create(ctx, &second); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Fatalf("create second pending payment = %v, want ErrAlreadyExists", err)
+	}
+
+	if err := payments.MarkSucceeded(ctx, first.PaymentID); err != nil {
+		t.Fatalf("mark succeeded: %v", err)
+	}
+	if err := payments.Create(ctx, &second); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Fatalf("create payment for paid booking = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestPaymentMarkSucceededUnpayableBooking(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	bookings := NewBookingRepository(db)
+	booking := createTestBooking(t, bookings, createTestUser(t, db), createTestField(t, db))
+	payments := NewPaymentRepository(db)
+
+	payment := testPayment(booking.BookingID)
+	if err := payments.Create(ctx, &payment); err != nil {
+		t.Fatalf("create payment: %v", err)
+	}
+	if err := bookings.Cancel(ctx, booking.BookingID, store.BookingPending, 0); err != nil {
+		t.Fatalf("cancel booking: %v", err)
+	}
+
+	if err := payments.MarkSucceeded(ctx, payment.PaymentID); !errors.Is(err, store.ErrBookingNotPayable) {
+		t.Fatalf("mark succeeded = %v, want ErrBookingNotPayable", err)
+	}
+	got, err := payments.Get(ctx, payment.PaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != store.PaymentPending {
+		t.Errorf("payment is %s, want pending so it can be refunded", got.Status)
+	}
+}
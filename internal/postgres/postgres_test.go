@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"take-home-test/internal/migrations"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_URL and migrates it,
+// skipping the test when it is not set. Tests create their own rows under
+// fresh names, so the database can be shared but should not hold real data.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// createTestUser inserts a player with a name unique to this run.
+func createTestUser(t *testing.T, db *sql.DB) store.User {
+	t.Helper()
+
+	name := fmt.Sprintf("test%d", time.Now().UnixNano())
+	user := store.User{Username: name, Email: name + "@example.com", Password: "x", Role: "user"}
+	if err := NewUserRepository(db).Create(context.Background(), &user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func createTestField(t *testing.T, db *sql.DB) store.Field {
+	t.Helper()
+
+	field := store.Field{Name: fmt.Sprintf("Test field %d", time.Now().UnixNano()), PricePerHour: 100000, Location: "Test"}
+	if err := NewFieldRepository(db).Create(context.Background(), &field); err != nil {
+		t.Fatalf("create field: %v", err)
+	}
+	return field
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const selectPricingRule = `
+	SELECT rule_id, field_id, name, weekdays,
+		to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'),
+		COALESCE(to_char(date_from, 'YYYY-MM-DD'), ''), COALESCE(to_char(date_to, 'YYYY-MM-DD'), ''),
+		price_per_hour, priority, created_at
+	FROM field_pricing_rules
+`
+
+type PricingRuleRepository struct {
+	db *sql.DB
+}
+
+var _ store.PricingRuleRepository = (*PricingRuleRepository)(nil)
+
+func NewPricingRuleRepository(db *sql.DB) *PricingRuleRepository {
+	return &PricingRuleRepository{db: db}
+}
+
+func (r *PricingRuleRepository) List(ctx context.Context, fieldID int) ([]store.PricingRule, error) {
+	rows, err := r.db.QueryContext(ctx, selectPricingRule+" WHERE field_id = $1 ORDER BY rule_id", fieldID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []store.PricingRule
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (r *PricingRuleRepository) Get(ctx context.Context, fieldID, ruleID int) (store.PricingRule, error) {
+	row := r.db.QueryRowContext(ctx, selectPricingRule+" WHERE field_id = $1 AND rule_id = $2", fieldID, ruleID)
+	return scanPricingRule(row)
+}
+
+func (r *PricingRuleRepository) Create(ctx context.Context, rule *store.PricingRule) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO field_pricing_rules
+			(field_id, name, weekdays, start_time, end_time, date_from, date_to, price_per_hour, priority)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::date, NULLIF($7, '')::date, $8, $9)
+		RETURNING rule_id, created_at
+	`, rule.FieldID, rule.Name, pq.Array(weekdayNumbers(rule.Weekdays)), rule.StartTime, rule.EndTime,
+		rule.DateFrom, rule.DateTo, rule.PricePerHour, rule.Priority,
+	).Scan(&rule.RuleID, &rule.CreatedAt)
+	if isConstraintViolation(err, "23503", "") {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (r *PricingRuleRepository) Update(ctx context.Context, rule store.PricingRule) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE field_pricing_rules
+		SET name = $3, weekdays = $4, start_time = $5, end_time = $6,
+			date_from = NULLIF($7, '')::date, date_to = NULLIF($8, '')::date,
+			price_per_hour = $9, priority = $10
+		WHERE field_id = $1 AND rule_id = $2
+	`, rule.FieldID, rule.RuleID, rule.Name, pq.Array(weekdayNumbers(rule.Weekdays)), rule.StartTime, rule.EndTime,
+		rule.DateFrom, rule.DateTo, rule.PricePerHour, rule.Priority)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *PricingRuleRepository) Delete(ctx context.Context, fieldID, ruleID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM field_pricing_rules WHERE field_id = $1 AND rule_id = $2", fieldID, ruleID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func scanPricingRule(row interface{ Scan(...any) error }) (store.PricingRule, error) {
+	var rule store.PricingRule
+	var weekdays []int64
+	err := row.Scan(
+		&rule.RuleID,
+		&rule.FieldID,
+		&rule.Name,
+		pq.Array(&weekdays),
+		&rule.StartTime,
+		&rule.EndTime,
+		&rule.DateFrom,
+		&rule.DateTo,
+		&rule.PricePerHour,
+		&rule.Priority,
+		&rule.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, store.ErrNotFound
+	}
+	for _, day := range weekdays {
+		rule.Weekdays = append(rule.Weekdays, time.Weekday(day))
+	}
+	return rule, err
+}
+
+func weekdayNumbers(days []time.Weekday) []int64 {
+	numbers := make([]int64, 0, len(days))
+	for _, day := range days {
+		numbers = append(numbers, int64(day))
+	}
+	return numbers
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"take-home-test/internal/store"
+
+	"github.com/lib/pq"
+)
+
+const selectPromo = `
+	SELECT p.promo_id, p.code, p.description, p.discount_type, p.discount_value,
+		p.valid_from, p.valid_until, p.max_redemptions, p.per_user_limit, p.min_spend,
+		p.field_ids, p.active,
+		(SELECT COUNT(*) FROM bookings WHERE promo_id = p.promo_id AND ` + promoRedeemedCondition + `),
+		p.created_at
+	FROM promo_codes p
+`
+
+type PromoRepository struct {
+	db *sql.DB
+}
+
+var _ store.PromoRepository = (*PromoRepository)(nil)
+
+func NewPromoRepository(db *sql.DB) *PromoRepository {
+	return &PromoRepository{db: db}
+}
+
+func (r *PromoRepository) Create(ctx context.Context, p *store.PromoCode) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO promo_codes
+			(code, description, discount_type, discount_value, valid_from, valid_until,
+			max_redemptions, per_user_limit, min_spend, field_ids, active)
+		VALUES (upper($1), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING promo_id, code, created_at
+	`, p.Code, p.Description, p.DiscountType, p.DiscountValue, p.ValidFrom, p.ValidUntil,
+		p.MaxRedemptions, p.PerUserLimit, p.MinSpend, pq.Array(p.FieldIDs), p.Active,
+	).Scan(&p.PromoID, &p.Code, &p.CreatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *PromoRepository) List(ctx context.Context) ([]store.PromoCode, error) {
+	rows, err := r.db.QueryContext(ctx, selectPromo+" ORDER BY p.promo_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []store.PromoCode
+	for rows.Next() {
+		p, err := scanPromo(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, p)
+	}
+
+	return promos, rows.Err()
+}
+
+func (r *PromoRepository) Get(ctx context.Context, promoID int) (store.PromoCode, error) {
+	return scanPromo(r.db.QueryRowContext(ctx, selectPromo+" WHERE p.promo_id = $1", promoID))
+}
+
+func (r *PromoRepository) GetByCode(ctx context.Context, code string) (store.PromoCode, error) {
+	return scanPromo(r.db.QueryRowContext(ctx, selectPromo+" WHERE p.code = upper($1)", code))
+}
+
+func (r *PromoRepository) Update(ctx context.Context, p store.PromoCode) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE promo_codes
+		SET code = upper($2), description = $3, discount_type = $4, discount_value = $5,
+			valid_from = $6, valid_until = $7, max_redemptions = $8, per_user_limit = $9,
+			min_spend = $10, field_ids = $11, active = $12
+		WHERE promo_id = $1
+	`, p.PromoID, p.Code, p.Description, p.DiscountType, p.DiscountValue, p.ValidFrom, p.ValidUntil,
+		p.MaxRedemptions, p.PerUserLimit, p.MinSpend, pq.Array(p.FieldIDs), p.Active)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *PromoRepository) Delete(ctx context.Context, promoID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM promo_codes WHERE promo_id = $1", promoID)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func scanPromo(row interface{ Scan(...any) error }) (store.PromoCode, error) {
+	var p store.PromoCode
+	var fieldIDs []int64
+	err := row.Scan(
+		&p.PromoID,
+		&p.Code,
+		&p.Description,
+		&p.DiscountType,
+		&p.DiscountValue,
+		&p.ValidFrom,
+		&p.ValidUntil,
+		&p.MaxRedemptions,
+		&p.PerUserLimit,
+		&p.MinSpend,
+		pq.Array(&fieldIDs),
+		&p.Active,
+		&p.Redemptions,
+		&p.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, store.ErrNotFound
+	}
+	for _, id := range fieldIDs {
+		p.FieldIDs = append(p.FieldIDs, int(id))
+	}
+	return p, err
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"take-home-test/internal/store"
+)
+
+type RoleRepository struct {
+	db *sql.DB
+}
+
+var _ store.RoleRepository = (*RoleRepository)(nil)
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+func (r *RoleRepository) Get(ctx context.Context, name string) (store.Role, error) {
+	roles, err := r.list(ctx, "WHERE r.name = $1", name)
+	if err != nil {
+		return store.Role{}, err
+	}
+	if len(roles) == 0 {
+		return store.Role{}, store.ErrNotFound
+	}
+	return roles[0], nil
+}
+
+func (r *RoleRepository) List(ctx context.Context) ([]store.Role, error) {
+	return r.list(ctx, "")
+}
+
+func (r *RoleRepository) list(ctx context.Context, where string, args ...any) ([]store.Role, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.name, r.description, p.permission
+		FROM roles r
+		LEFT JOIN role_permissions p ON p.role = r.name
+		`+where+`
+		ORDER BY r.name, p.permission
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []store.Role
+	for rows.Next() {
+		var name, description string
+		var permission sql.NullString
+		if err := rows.Scan(&name, &description, &permission); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, store.Role{Name: name, Description: description, Permissions: []string{}})
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+
+	return roles, rows.Err()
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"take-home-test/internal/store"
+	"time"
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+var _ store.TokenRepository = (*TokenRepository)(nil)
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, t *store.RefreshToken) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, mfa, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING token_id, created_at
+	`, t.UserID, t.FamilyID, t.TokenHash, t.MFA, t.ExpiresAt).Scan(&t.TokenID, &t.CreatedAt)
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (store.RefreshToken, error) {
+	var t store.RefreshToken
+	err := r.db.QueryRowContext(ctx, `
+		SELECT token_id, user_id, family_id, token_hash, mfa, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`, tokenHash).Scan(&t.TokenID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.MFA, &t.ExpiresAt, &t.RevokedAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, store.ErrNotFound
+	}
+	return t, err
+}
+
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, oldID int, next *store.RefreshToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now() WHERE token_id = $1 AND revoked_at IS NULL
+	`, oldID)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return store.ErrStatusChanged
+		}
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, mfa, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING token_id, created_at
+	`, next.UserID, next.FamilyID, next.TokenHash, next.MFA, next.ExpiresAt).Scan(&next.TokenID, &next.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TokenRepository) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	return err
+}
+
+func (r *TokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+func (r *TokenRepository) DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt)
+	return err
+}
+
+func (r *TokenRepository) IsAccessTokenDenied(ctx context.Context, jti string) (bool, error) {
+	var denied bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)",
+		jti,
+	).Scan(&denied)
+	return denied, err
+}
+
+func (r *TokenRepository) CreateUserToken(ctx context.Context, t *store.UserToken) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING token_id, created_at
+	`, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.TokenID, &t.CreatedAt)
+}
+
+func (r *TokenRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string, now time.Time) (store.UserToken, error) {
+	var t store.UserToken
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE user_tokens
+		SET used_at = $3
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING token_id, user_id, purpose, token_hash, expires_at, used_at, created_at
+	`, purpose, tokenHash, now).Scan(&t.TokenID, &t.UserID, &t.Purpose, &t.TokenHash, &t.ExpiresAt, &t.UsedAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, store.ErrNotFound
+	}
+	return t, err
+}
+
+func (r *TokenRepository) InvalidateUserTokens(ctx context.Context, userID int, purpose string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_tokens SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, userID, purpose)
+	return err
+}
+
+func (r *TokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleted := 0
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE expires_at <= $1",
+		"DELETE FROM revoked_access_tokens WHERE expires_at <= $1",
+		"DELETE FROM user_tokens WHERE expires_at <= $1",
+	} {
+		result, err := tx.ExecContext(ctx, query, now)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+
+	return deleted, tx.Commit()
+}
@@ -0,0 +1,302 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"take-home-test/internal/store"
+)
+
+// firstAdminLockKey is the pg_advisory_xact_lock key held while the first
+// admin is created.
+const firstAdminLockKey = 72658
+
+const selectUser = `
+	SELECT
+		user_id, username, email, password, role, COALESCE(phone, ''),
+		email_verified_at, COALESCE(pending_email, ''), disabled_at, deleted_at, created_at
+	FROM users
+`
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+var _ store.UserRepository = (*UserRepository)(nil)
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *store.User) error {
+	err := r.db.QueryRowContext(ctx,
+		"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4) RETURNING user_id, created_at",
+		u.Username, u.Email, u.Password, u.Role,
+	).Scan(&u.UserID, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *UserRepository) CreateFirstAdmin(ctx context.Context, u *store.User) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialise bootstrap attempts so two of them cannot both see no admin.
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", firstAdminLockKey); err != nil {
+		return err
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE role = 'admin')").Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return store.ErrAdminExists
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, 'admin') RETURNING user_id, created_at",
+		u.Username, u.Email, u.Password,
+	).Scan(&u.UserID, &u.CreatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	u.Role = "admin"
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, userID int, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $2 WHERE user_id = $1", userID, role)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID int) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE user_id = $1",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) SetPassword(ctx context.Context, userID int, passwordHash string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password = $2 WHERE user_id = $1", userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) List(ctx context.Context, filter store.UserFilter) ([]store.User, int, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Query != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query) + "%"
+		addCondition("(username ILIKE $%[1]d OR email ILIKE $%[1]d)", pattern)
+	}
+	if filter.Role != "" {
+		addCondition("role = $%d", filter.Role)
+	}
+	switch filter.Status {
+	case store.UserActive:
+		conditions = append(conditions, "disabled_at IS NULL")
+	case store.UserDisabled:
+		conditions = append(conditions, "disabled_at IS NOT NULL")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := selectUser + where + " ORDER BY user_id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []store.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (r *UserRepository) UpdateProfile(ctx context.Context, userID int, username, phone string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET username = $2, phone = NULLIF($3, '') WHERE user_id = $1",
+		userID, username, phone,
+	)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) SetPendingEmail(ctx context.Context, userID int, email string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET pending_email = $2 WHERE user_id = $1", userID, email)
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) ChangeEmail(ctx context.Context, userID int, email string) error {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET email = $2, email_verified_at = now(), pending_email = NULL WHERE user_id = $1",
+		userID, email,
+	)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	return expectRows(result)
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, userID int, disabled bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE users SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, now()) END WHERE user_id = $1",
+		userID, disabled,
+	)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		return err
+	}
+
+	if disabled {
+		if _, err := tx.ExecContext(ctx, cancelWaitlistEntries, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// cancelWaitlistEntries takes a user who can no longer book off the
+// waitlists, so that slots are not offered to them.
+const cancelWaitlistEntries = "UPDATE waitlist_entries SET status = 'cancelled' WHERE user_id = $1 AND status = 'waiting'"
+
+func (r *UserRepository) Anonymise(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The placeholder email keeps the unique constraint satisfied and can
+	// never receive mail. An empty password hash matches no password.
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET username = 'Deleted user',
+			email = 'deleted-' || user_id || '@deleted.invalid',
+			password = '',
+			phone = NULL,
+			pending_email = NULL,
+			email_verified_at = NULL,
+			disabled_at = COALESCE(disabled_at, now()),
+			deleted_at = now()
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM user_mfa WHERE user_id = $1",
+		"DELETE FROM mfa_recovery_codes WHERE user_id = $1",
+		"DELETE FROM user_tokens WHERE user_id = $1",
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL",
+		cancelWaitlistEntries,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, userID int) (store.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, selectUser+" WHERE user_id = $1", userID))
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (store.User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, selectUser+" WHERE email = $1", email))
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = $1", email).Scan(&count)
+	return count > 0, err
+}
+
+func scanUser(row interface{ Scan(...any) error }) (store.User, error) {
+	var u store.User
+	err := row.Scan(
+		&u.UserID,
+		&u.Username,
+		&u.Email,
+		&u.Password,
+		&u.Role,
+		&u.Phone,
+		&u.EmailVerifiedAt,
+		&u.PendingEmail,
+		&u.DisabledAt,
+		&u.DeletedAt,
+		&u.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, store.ErrNotFound
+	}
+	return u, err
+}
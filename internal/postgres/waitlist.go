@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"take-home-test/internal/store"
+	"time"
+)
+
+const selectWaitlistEntry = `
+	SELECT entry_id, user_id, field_id, to_char(booking_date, 'YYYY-MM-DD'),
+		to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'),
+		status, booking_id, offered_at, offer_expires_at, created_at
+	FROM waitlist_entries
+`
+
+type WaitlistRepository struct {
+	db *sql.DB
+}
+
+var _ store.WaitlistRepository = (*WaitlistRepository)(nil)
+
+func NewWaitlistRepository(db *sql.DB) *WaitlistRepository {
+	return &WaitlistRepository{db: db}
+}
+
+func (r *WaitlistRepository) Create(ctx context.Context, e *store.WaitlistEntry) error {
+	e.Status = store.WaitlistWaiting
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO waitlist_entries (user_id, field_id, booking_date, start_time, end_time, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING entry_id, created_at
+	`, e.UserID, e.FieldID, e.BookingDate, e.StartTime, e.EndTime, e.Status).Scan(&e.EntryID, &e.CreatedAt)
+	if isUniqueViolation(err) {
+		return store.ErrAlreadyExists
+	}
+	if isConstraintViolation(err, "23503", "") {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+func (r *WaitlistRepository) Get(ctx context.Context, entryID int) (store.WaitlistEntry, error) {
+	return scanWaitlistEntry(r.db.QueryRowContext(ctx, selectWaitlistEntry+" WHERE entry_id = $1", entryID))
+}
+
+func (r *WaitlistRepository) ListByUser(ctx context.Context, userID int) ([]store.WaitlistEntry, error) {
+	return r.list(ctx, " WHERE user_id = $1 ORDER BY created_at DESC, entry_id DESC", userID)
+}
+
+func (r *WaitlistRepository) Waiting(ctx context.Context, fieldID int, date string) ([]store.WaitlistEntry, error) {
+	where := `
+		WHERE status = 'waiting'
+		AND EXISTS (
+			SELECT 1 FROM users u
+			WHERE u.user_id = waitlist_entries.user_id AND u.disabled_at IS NULL AND u.deleted_at IS NULL
+		)`
+	var args []any
+	if fieldID != 0 {
+		args = append(args, fieldID)
+		where += fmt.Sprintf(" AND field_id = $%d", len(args))
+	}
+	if date != "" {
+		args = append(args, date)
+		where += fmt.Sprintf(" AND booking_date = $%d", len(args))
+	}
+	return r.list(ctx, where+" ORDER BY created_at, entry_id", args...)
+}
+
+func (r *WaitlistRepository) MarkOffered(ctx context.Context, entryID, bookingID int, expiresAt time.Time) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE waitlist_entries
+		SET status = 'offered', booking_id = $2, offered_at = now(), offer_expires_at = $3
+		WHERE entry_id = $1 AND status = 'waiting'
+	`, entryID, bookingID, expiresAt)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		return store.ErrStatusChanged
+	}
+	return nil
+}
+
+func (r *WaitlistRepository) Cancel(ctx context.Context, entryID int) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE waitlist_entries SET status = 'cancelled' WHERE entry_id = $1 AND status = 'waiting'
+	`, entryID)
+	if err != nil {
+		return err
+	}
+	if err := expectRows(result); err != nil {
+		return store.ErrStatusChanged
+	}
+	return nil
+}
+
+func (r *WaitlistRepository) Settle(ctx context.Context, now time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	settled, err := tx.ExecContext(ctx, `
+		UPDATE waitlist_entries w
+		SET status = CASE WHEN b.status IN ('paid', 'completed') THEN 'booked' ELSE 'lapsed' END
+		FROM bookings b
+		WHERE w.booking_id = b.booking_id
+		AND w.status = 'offered'
+		AND (b.status <> 'pending' OR b.expires_at <= $1)
+	`, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// The offered booking was deleted along with its field.
+	orphaned, err := tx.ExecContext(ctx, `
+		UPDATE waitlist_entries SET status = 'lapsed' WHERE status = 'offered' AND booking_id IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	// Booking times are local wall-clock times, as in CompleteFinished.
+	expired, err := tx.ExecContext(ctx, `
+		UPDATE waitlist_entries
+		SET status = 'expired'
+		WHERE status = 'waiting' AND booking_date + start_time <= $1::timestamp
+	`, now.In(time.Local).Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, result := range []sql.Result{settled, orphaned, expired} {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		changed += int(n)
+	}
+
+	return changed, tx.Commit()
+}
+
+func (r *WaitlistRepository) list(ctx context.Context, where string, args ...any) ([]store.WaitlistEntry, error) {
+	rows, err := r.db.QueryContext(ctx, selectWaitlistEntry+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []store.WaitlistEntry
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func scanWaitlistEntry(row interface{ Scan(...any) error }) (store.WaitlistEntry, error) {
+	var e store.WaitlistEntry
+	err := row.Scan(
+		&e.EntryID,
+		&e.UserID,
+		&e.FieldID,
+		&e.BookingDate,
+		&e.StartTime,
+		&e.EndTime,
+		&e.Status,
+		&e.BookingID,
+		&e.OfferedAt,
+		&e.OfferExpiresAt,
+		&e.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, store.ErrNotFound
+	}
+	return e, err
+}
@@ -0,0 +1,150 @@
+package pricing
+
+import (
+	"context"
+	"sort"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/store"
+	"time"
+)
+
+// Quote is the price of one booking, split into segments charged at a
+// single rate each.
+type Quote struct {
+	Segments []store.PriceSegment
+	Total    int
+}
+
+// Service prices bookings from a field's base price and its pricing rules.
+type Service struct {
+	rules store.PricingRuleRepository
+}
+
+func NewService(rules store.PricingRuleRepository) *Service {
+	return &Service{rules: rules}
+}
+
+// Quote prices a booking of field on date from start to end, both HH:MM.
+func (s *Service) Quote(ctx context.Context, field store.Field, date time.Time, start, end string) (Quote, error) {
+	rules, err := s.rules.List(ctx, field.FieldID)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	startMinutes, _ := fields.ParseClock(start)
+	endMinutes, _ := fields.ParseClock(end)
+	return Price(rules, field.PricePerHour, date, startMinutes, endMinutes), nil
+}
+
+// Price splits start to end, in minutes since midnight on date, at every
+// boundary of the rules that apply that day, prices each piece at the
+// winning rule or else basePrice, and merges neighbours charged the same.
+// The exact total is rounded to the nearest unit once, and each segment is
+// charged its share of that rounded total, so the segments always add up.
+func Price(rules []store.PricingRule, basePrice int, date time.Time, start, end int) Quote {
+	type window struct {
+		rule       store.PricingRule
+		start, end int
+	}
+
+	var windows []window
+	cuts := []int{start, end}
+	for _, rule := range rules {
+		if !appliesOn(rule, date) {
+			continue
+		}
+		ruleStart, _ := fields.ParseClock(rule.StartTime)
+		ruleEnd, _ := fields.ParseClock(rule.EndTime)
+		if ruleEnd <= start || ruleStart >= end {
+			continue
+		}
+		windows = append(windows, window{rule: rule, start: ruleStart, end: ruleEnd})
+		for _, cut := range []int{ruleStart, ruleEnd} {
+			if cut > start && cut < end {
+				cuts = append(cuts, cut)
+			}
+		}
+	}
+	sort.Ints(cuts)
+
+	// rule is nil where no rule applies.
+	type piece struct {
+		start, end int
+		rule       *store.PricingRule
+	}
+
+	var quote Quote
+	var pieces []piece
+	for i := 1; i < len(cuts); i++ {
+		from, to := cuts[i-1], cuts[i]
+		if from == to {
+			continue
+		}
+
+		var best *store.PricingRule
+		for j := range windows {
+			w := &windows[j]
+			if w.start > from || w.end < to {
+				continue
+			}
+			if best == nil || w.rule.Priority > best.Priority ||
+				(w.rule.Priority == best.Priority && w.rule.RuleID > best.RuleID) {
+				best = &w.rule
+			}
+		}
+
+		if n := len(pieces); n > 0 && pieces[n-1].rule == best {
+			pieces[n-1].end = to
+			continue
+		}
+		pieces = append(pieces, piece{start: from, end: to, rule: best})
+	}
+
+	// cost is the exact price so far, in price per hour times minutes.
+	cost := 0
+	for _, piece := range pieces {
+		segment := store.PriceSegment{
+			StartTime:    fields.FormatClock(piece.start),
+			EndTime:      fields.FormatClock(piece.end),
+			PricePerHour: basePrice,
+		}
+		if piece.rule != nil {
+			ruleID := piece.rule.RuleID
+			segment.RuleID = &ruleID
+			segment.RuleName = piece.rule.Name
+			segment.PricePerHour = piece.rule.PricePerHour
+		}
+		cost += segment.PricePerHour * (piece.end - piece.start)
+		segment.Amount = roundMinutes(cost) - quote.Total
+		quote.Segments = append(quote.Segments, segment)
+		quote.Total += segment.Amount
+	}
+
+	return quote
+}
+
+// roundMinutes converts a price per hour times minutes into a price,
+// rounding half up.
+func roundMinutes(cost int) int {
+	return (cost + 30) / 60
+}
+
+// appliesOn reports whether rule covers date at all.
+func appliesOn(rule store.PricingRule, date time.Time) bool {
+	d := date.Format("2006-01-02")
+	if rule.DateFrom != "" && d < rule.DateFrom {
+		return false
+	}
+	if rule.DateTo != "" && d > rule.DateTo {
+		return false
+	}
+	if len(rule.Weekdays) == 0 {
+		return true
+	}
+	for _, day := range rule.Weekdays {
+		if day == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
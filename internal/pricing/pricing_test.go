@@ -0,0 +1,151 @@
+package pricing
+
+import (
+	"fmt"
+	"reflect"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+func TestPrice(t *testing.T) {
+	// A Saturday.
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		rules      []store.PricingRule
+		basePrice  int
+		start, end string
+		want       []string
+		wantTotal  int
+	}{
+		{
+			name:      "no rules",
+			basePrice: 60000,
+			start:     "10:00", end: "11:30",
+			want:      []string{"10:00-11:30 base 60000 = 90000"},
+			wantTotal: 90000,
+		},
+		{
+			name: "higher priority wins the overlap",
+			rules: []store.PricingRule{
+				{RuleID: 1, Name: "day", StartTime: "08:00", EndTime: "12:00", PricePerHour: 80000, Priority: 1},
+				{RuleID: 2, Name: "peak", StartTime: "10:00", EndTime: "11:00", PricePerHour: 100000, Priority: 5},
+			},
+			basePrice: 60000,
+			start:     "07:00", end: "12:00",
+			want: []string{
+				"07:00-08:00 base 60000 = 60000",
+				"08:00-10:00 day 80000 = 160000",
+				"10:00-11:00 peak 100000 = 100000",
+				"11:00-12:00 day 80000 = 80000",
+			},
+			wantTotal: 400000,
+		},
+		{
+			name: "newer rule loses to a higher priority",
+			rules: []store.PricingRule{
+				{RuleID: 2, Name: "old", StartTime: "10:00", EndTime: "12:00", PricePerHour: 70000, Priority: 1},
+				{RuleID: 9, Name: "new", StartTime: "10:00", EndTime: "12:00", PricePerHour: 90000, Priority: 0},
+			},
+			basePrice: 60000,
+			start:     "10:00", end: "11:00",
+			want:      []string{"10:00-11:00 old 70000 = 70000"},
+			wantTotal: 70000,
+		},
+		{
+			name: "priority tie goes to the higher rule id",
+			rules: []store.PricingRule{
+				{RuleID: 7, Name: "newer", StartTime: "10:00", EndTime: "12:00", PricePerHour: 90000, Priority: 1},
+				{RuleID: 3, Name: "older", StartTime: "10:00", EndTime: "12:00", PricePerHour: 70000, Priority: 1},
+			},
+			basePrice: 60000,
+			start:     "10:00", end: "11:00",
+			want:      []string{"10:00-11:00 newer 90000 = 90000"},
+			wantTotal: 90000,
+		},
+		{
+			name: "neighbours at the same rule are merged",
+			rules: []store.PricingRule{
+				{RuleID: 1, Name: "peak", StartTime: "10:00", EndTime: "12:00", PricePerHour: 100000, Priority: 5},
+				{RuleID: 2, Name: "evening", StartTime: "11:00", EndTime: "13:00", PricePerHour: 80000, Priority: 1},
+			},
+			basePrice: 60000,
+			start:     "10:00", end: "13:00",
+			want: []string{
+				"10:00-12:00 peak 100000 = 200000",
+				"12:00-13:00 evening 80000 = 80000",
+			},
+			wantTotal: 280000,
+		},
+		{
+			name: "weekday filter",
+			rules: []store.PricingRule{
+				{RuleID: 1, Name: "sunday", Weekdays: []time.Weekday{time.Sunday}, StartTime: "00:00", EndTime: "24:00", PricePerHour: 90000},
+				{RuleID: 2, Name: "weekend", Weekdays: []time.Weekday{time.Saturday, time.Sunday}, StartTime: "18:00", EndTime: "24:00", PricePerHour: 80000},
+			},
+			basePrice: 60000,
+			start:     "17:00", end: "24:00",
+			want: []string{
+				"17:00-18:00 base 60000 = 60000",
+				"18:00-24:00 weekend 80000 = 480000",
+			},
+			wantTotal: 540000,
+		},
+		{
+			name: "date range filter",
+			rules: []store.PricingRule{
+				{RuleID: 1, Name: "before", DateTo: "2024-05-31", StartTime: "00:00", EndTime: "24:00", PricePerHour: 10000, Priority: 9},
+				{RuleID: 2, Name: "after", DateFrom: "2024-06-02", StartTime: "00:00", EndTime: "24:00", PricePerHour: 20000, Priority: 9},
+				{RuleID: 3, Name: "that day", DateFrom: "2024-06-01", DateTo: "2024-06-01", StartTime: "00:00", EndTime: "24:00", PricePerHour: 30000},
+			},
+			basePrice: 60000,
+			start:     "10:00", end: "11:00",
+			want:      []string{"10:00-11:00 that day 30000 = 30000"},
+			wantTotal: 30000,
+		},
+		{
+			name:      "odd minutes round to the nearest unit",
+			basePrice: 100001,
+			start:     "10:00", end: "11:30",
+			want:      []string{"10:00-11:30 base 100001 = 150002"},
+			wantTotal: 150002,
+		},
+		{
+			name: "segments share the rounded total",
+			rules: []store.PricingRule{
+				{RuleID: 1, Name: "same", StartTime: "10:01", EndTime: "10:02", PricePerHour: 100},
+			},
+			basePrice: 100,
+			start:     "10:00", end: "10:03",
+			want: []string{
+				"10:00-10:01 base 100 = 2",
+				"10:01-10:02 same 100 = 1",
+				"10:02-10:03 base 100 = 2",
+			},
+			wantTotal: 5,
+		},
+	}
+	for _, tt := range tests {
+		start, _ := fields.ParseClock(tt.start)
+		end, _ := fields.ParseClock(tt.end)
+		quote := Price(tt.rules, tt.basePrice, date, start, end)
+
+		var got []string
+		for _, s := range quote.Segments {
+			name := "base"
+			if s.RuleID != nil {
+				name = s.RuleName
+			}
+			got = append(got, fmt.Sprintf("%s-%s %s %d = %d", s.StartTime, s.EndTime, name, s.PricePerHour, s.Amount))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: segments = %q, want %q", tt.name, got, tt.want)
+		}
+		if quote.Total != tt.wantTotal {
+			t.Errorf("%s: total = %d, want %d", tt.name, quote.Total, tt.wantTotal)
+		}
+	}
+}
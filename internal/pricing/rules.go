@@ -0,0 +1,327 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ruleRequest struct {
+	Name         string   `json:"name"`
+	Days         []string `json:"days"`
+	StartTime    string   `json:"start_time"`
+	EndTime      string   `json:"end_time"`
+	DateFrom     string   `json:"date_from"`
+	DateTo       string   `json:"date_to"`
+	PricePerHour int      `json:"price_per_hour"`
+	Priority     int      `json:"priority"`
+}
+
+func ListRulesHandler(rules store.PricingRuleRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fieldID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		list, err := rules.List(c.UserContext(), fieldID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch pricing rules",
+			})
+		}
+
+		result := []fiber.Map{}
+		for _, rule := range list {
+			result = append(result, ruleResponse(rule))
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Pricing rules retrieved successfully",
+			"rules":   result,
+		})
+	}
+}
+
+func CreateRuleHandler(rules store.PricingRuleRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fieldID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		var req ruleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		rule, msg := req.rule()
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		rule.FieldID = fieldID
+
+		if err := rules.Create(c.UserContext(), &rule); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create pricing rule",
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "Pricing rule created successfully",
+			"rule":    ruleResponse(rule),
+		})
+	}
+}
+
+func UpdateRuleHandler(rules store.PricingRuleRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fieldID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+		ruleID, err := strconv.Atoi(c.Params("rule_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid rule ID",
+			})
+		}
+
+		var req ruleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+
+		rule, msg := req.rule()
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		rule.FieldID, rule.RuleID = fieldID, ruleID
+
+		if err := rules.Update(c.UserContext(), rule); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Pricing rule not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update pricing rule",
+			})
+		}
+
+		rule, err = rules.Get(c.UserContext(), fieldID, ruleID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch updated pricing rule",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Pricing rule updated successfully",
+			"rule":    ruleResponse(rule),
+		})
+	}
+}
+
+func DeleteRuleHandler(rules store.PricingRuleRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fieldID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+		ruleID, err := strconv.Atoi(c.Params("rule_id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid rule ID",
+			})
+		}
+
+		if err := rules.Delete(c.UserContext(), fieldID, ruleID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Pricing rule not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete pricing rule",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Pricing rule deleted successfully",
+		})
+	}
+}
+
+// QuoteHandler prices a booking without making it, from ?date,
+// ?start_time and ?end_time.
+func QuoteHandler(fieldRepo store.FieldRepository, pricer *Service) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fieldID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		date, err := time.Parse("2006-01-02", c.Query("date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid date format. Use YYYY-MM-DD",
+			})
+		}
+		start, startOK := fields.ParseClock(c.Query("start_time"))
+		end, endOK := fields.ParseClock(c.Query("end_time"))
+		if !startOK || !endOK {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid time format. Use HH:MM",
+			})
+		}
+		if end <= start {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End time must be after start time",
+			})
+		}
+
+		field, err := fieldRepo.Get(c.UserContext(), fieldID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch field",
+			})
+		}
+
+		quote, err := pricer.Quote(c.UserContext(), field, date, fields.FormatClock(start), fields.FormatClock(end))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to price booking",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":         "Price calculated successfully",
+			"field_id":        field.FieldID,
+			"booking_date":    date.Format("2006-01-02"),
+			"start_time":      fields.FormatClock(start),
+			"end_time":        fields.FormatClock(end),
+			"total_price":     quote.Total,
+			"price_breakdown": quote.Segments,
+		})
+	}
+}
+
+// rule validates the request, returning why it is invalid or "" if not.
+func (req ruleRequest) rule() (store.PricingRule, string) {
+	rule := store.PricingRule{
+		Name:         req.Name,
+		PricePerHour: req.PricePerHour,
+		Priority:     req.Priority,
+		StartTime:    req.StartTime,
+		EndTime:      req.EndTime,
+		DateFrom:     req.DateFrom,
+		DateTo:       req.DateTo,
+	}
+
+	if rule.Name == "" {
+		return rule, "Rule name is required"
+	}
+	if rule.PricePerHour <= 0 {
+		return rule, "Price per hour must be greater than 0"
+	}
+
+	seen := make(map[time.Weekday]bool)
+	for _, name := range req.Days {
+		day, ok := fields.ParseWeekday(name)
+		if !ok {
+			return rule, fmt.Sprintf("Invalid day %q. Use monday to sunday", name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			rule.Weekdays = append(rule.Weekdays, day)
+		}
+	}
+
+	if rule.StartTime == "" {
+		rule.StartTime = "00:00"
+	}
+	if rule.EndTime == "" {
+		rule.EndTime = "24:00"
+	}
+	start, ok := fields.ParseClock(rule.StartTime)
+	if !ok || start == 24*60 {
+		return rule, "Invalid start time format. Use HH:MM"
+	}
+	end, ok := fields.ParseClock(rule.EndTime)
+	if !ok {
+		return rule, "Invalid end time format. Use HH:MM"
+	}
+	if end <= start {
+		return rule, "End time must be after start time. Split windows that cross midnight into two rules"
+	}
+	rule.StartTime, rule.EndTime = fields.FormatClock(start), fields.FormatClock(end)
+
+	for param, value := range map[string]string{"date_from": rule.DateFrom, "date_to": rule.DateTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return rule, fmt.Sprintf("Invalid %s format. Use YYYY-MM-DD", param)
+		}
+	}
+	if rule.DateFrom != "" && rule.DateTo != "" && rule.DateTo < rule.DateFrom {
+		return rule, "date_to must not be before date_from"
+	}
+
+	return rule, ""
+}
+
+func ruleResponse(rule store.PricingRule) fiber.Map {
+	days := []string{}
+	for _, day := range rule.Weekdays {
+		days = append(days, fields.WeekdayName(day))
+	}
+
+	return fiber.Map{
+		"rule_id":        rule.RuleID,
+		"field_id":       rule.FieldID,
+		"name":           rule.Name,
+		"days":           days,
+		"start_time":     rule.StartTime,
+		"end_time":       rule.EndTime,
+		"date_from":      rule.DateFrom,
+		"date_to":        rule.DateTo,
+		"price_per_hour": rule.PricePerHour,
+		"priority":       rule.Priority,
+		"created_at":     rule.CreatedAt,
+	}
+}
@@ -0,0 +1,269 @@
+package promos
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var codePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+type promoRequest struct {
+	Code           string     `json:"code"`
+	Description    string     `json:"description"`
+	DiscountType   string     `json:"discount_type"`
+	DiscountValue  int        `json:"discount_value"`
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	PerUserLimit   int        `json:"per_user_limit"`
+	MinSpend       int        `json:"min_spend"`
+	FieldIDs       []int      `json:"field_ids"`
+	// Active defaults to true.
+	Active *bool `json:"active"`
+}
+
+func ListPromosHandler(promos store.PromoRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list, err := promos.List(c.UserContext())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch promo codes",
+			})
+		}
+
+		result := []fiber.Map{}
+		for _, p := range list {
+			result = append(result, promoResponse(p))
+		}
+
+		return c.JSON(fiber.Map{
+			"message":     "Promo codes retrieved successfully",
+			"promo_codes": result,
+		})
+	}
+}
+
+func GetPromoHandler(promos store.PromoRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid promo code ID",
+			})
+		}
+
+		p, err := promos.Get(c.UserContext(), id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Promo code not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch promo code",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":    "Promo code retrieved successfully",
+			"promo_code": promoResponse(p),
+		})
+	}
+}
+
+func CreatePromoHandler(promos store.PromoRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req promoRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body. Times use RFC 3339, such as 2030-01-01T08:00:00+07:00",
+			})
+		}
+
+		p, msg := req.promo()
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+
+		if err := promos.Create(c.UserContext(), &p); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Promo code already exists",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create promo code",
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message":    "Promo code created successfully",
+			"promo_code": promoResponse(p),
+		})
+	}
+}
+
+func UpdatePromoHandler(promos store.PromoRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid promo code ID",
+			})
+		}
+
+		var req promoRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body. Times use RFC 3339, such as 2030-01-01T08:00:00+07:00",
+			})
+		}
+
+		p, msg := req.promo()
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		p.PromoID = id
+
+		if err := promos.Update(c.UserContext(), p); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Promo code not found",
+				})
+			}
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Promo code already exists",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update promo code",
+			})
+		}
+
+		p, err = promos.Get(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch updated promo code",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message":    "Promo code updated successfully",
+			"promo_code": promoResponse(p),
+		})
+	}
+}
+
+// DeletePromoHandler removes a promo code. Bookings that used it keep
+// their discount.
+func DeletePromoHandler(promos store.PromoRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid promo code ID",
+			})
+		}
+
+		if err := promos.Delete(c.UserContext(), id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Promo code not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to delete promo code",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Promo code deleted successfully",
+		})
+	}
+}
+
+// promo validates the request, returning why it is invalid or "" if not.
+func (req promoRequest) promo() (store.PromoCode, string) {
+	p := store.PromoCode{
+		Code:           strings.ToUpper(strings.TrimSpace(req.Code)),
+		Description:    req.Description,
+		DiscountType:   req.DiscountType,
+		DiscountValue:  req.DiscountValue,
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
+		MaxRedemptions: req.MaxRedemptions,
+		PerUserLimit:   req.PerUserLimit,
+		MinSpend:       req.MinSpend,
+		Active:         req.Active == nil || *req.Active,
+	}
+
+	if !codePattern.MatchString(p.Code) {
+		return p, "Code must be 3 to 50 letters, digits, dashes or underscores"
+	}
+	switch p.DiscountType {
+	case store.DiscountPercent:
+		if p.DiscountValue < 1 || p.DiscountValue > 100 {
+			return p, "Percent discounts must be between 1 and 100"
+		}
+	case store.DiscountFixed:
+		if p.DiscountValue <= 0 {
+			return p, "Fixed discounts must be greater than 0"
+		}
+	default:
+		return p, "Discount type must be percent or fixed"
+	}
+	if p.ValidFrom != nil && p.ValidUntil != nil && !p.ValidUntil.After(*p.ValidFrom) {
+		return p, "valid_until must be after valid_from"
+	}
+	if p.MaxRedemptions < 0 || p.PerUserLimit < 0 || p.MinSpend < 0 {
+		return p, "Limits and minimum spend must not be negative"
+	}
+
+	seen := make(map[int]bool)
+	for _, id := range req.FieldIDs {
+		if id <= 0 {
+			return p, "Invalid field ID in field_ids"
+		}
+		if !seen[id] {
+			seen[id] = true
+			p.FieldIDs = append(p.FieldIDs, id)
+		}
+	}
+
+	return p, ""
+}
+
+func promoResponse(p store.PromoCode) fiber.Map {
+	fieldIDs := p.FieldIDs
+	if fieldIDs == nil {
+		fieldIDs = []int{}
+	}
+
+	return fiber.Map{
+		"promo_id":        p.PromoID,
+		"code":            p.Code,
+		"description":     p.Description,
+		"discount_type":   p.DiscountType,
+		"discount_value":  p.DiscountValue,
+		"valid_from":      p.ValidFrom,
+		"valid_until":     p.ValidUntil,
+		"max_redemptions": p.MaxRedemptions,
+		"per_user_limit":  p.PerUserLimit,
+		"min_spend":       p.MinSpend,
+		"field_ids":       fieldIDs,
+		"active":          p.Active,
+		"redemptions":     p.Redemptions,
+		"created_at":      p.CreatedAt,
+	}
+}
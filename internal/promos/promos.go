@@ -0,0 +1,45 @@
+package promos
+
+import (
+	"fmt"
+	"slices"
+	"take-home-test/internal/store"
+	"time"
+)
+
+// PromoError explains why a promo code cannot be used on a booking. Its
+// message is meant for the person booking.
+type PromoError struct {
+	Reason string
+}
+
+func (e *PromoError) Error() string {
+	return e.Reason
+}
+
+// Discount returns how much promo takes off a booking of fieldID priced
+// at subtotal, made at now. It returns a *PromoError when the code does
+// not apply. Redemption limits are left to BookingRepository.Create, which
+// can check them atomically.
+func Discount(promo store.PromoCode, fieldID, subtotal int, now time.Time) (int, error) {
+	if !promo.Active {
+		return 0, &PromoError{"Promo code is no longer active"}
+	}
+	if promo.ValidFrom != nil && now.Before(*promo.ValidFrom) {
+		return 0, &PromoError{"Promo code is not valid yet"}
+	}
+	if promo.ValidUntil != nil && !now.Before(*promo.ValidUntil) {
+		return 0, &PromoError{"Promo code has expired"}
+	}
+	if len(promo.FieldIDs) > 0 && !slices.Contains(promo.FieldIDs, fieldID) {
+		return 0, &PromoError{"Promo code does not apply to this field"}
+	}
+	if subtotal < promo.MinSpend {
+		return 0, &PromoError{fmt.Sprintf("Promo code requires a minimum spend of %d", promo.MinSpend)}
+	}
+
+	if promo.DiscountType == store.DiscountPercent {
+		return subtotal * promo.DiscountValue / 100, nil
+	}
+	return min(promo.DiscountValue, subtotal), nil
+}
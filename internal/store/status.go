@@ -0,0 +1,69 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// Booking statuses. A booking starts pending, becomes paid once its
+// payment is confirmed and completed once it has been played. Pending
+// bookings can be cancelled or expire; paid bookings can be cancelled or
+// refunded.
+const (
+	BookingPending   = "pending"
+	BookingPaid      = "paid"
+	BookingCompleted = "completed"
+	BookingCancelled = "cancelled"
+	BookingExpired   = "expired"
+	BookingRefunded  = "refunded"
+)
+
+var bookingTransitions = map[string][]string{
+	BookingPending:   {BookingPaid, BookingCancelled, BookingExpired},
+	BookingPaid:      {BookingCompleted, BookingCancelled, BookingRefunded},
+	BookingCompleted: nil,
+	BookingCancelled: nil,
+	BookingExpired:   nil,
+	BookingRefunded:  nil,
+}
+
+func IsBookingStatus(status string) bool {
+	_, ok := bookingTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a booking may move from one status to
+// another. Every status change goes through this check.
+func CanTransition(from, to string) bool {
+	for _, next := range bookingTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedFrom returns the statuses a booking may move to status from, for
+// repositories that make the check in a query.
+func AllowedFrom(to string) []string {
+	var from []string
+	for status := range bookingTransitions {
+		if CanTransition(status, to) {
+			from = append(from, status)
+		}
+	}
+	sort.Strings(from)
+	return from
+}
+
+// HoldsSlot reports whether b keeps its slot from being booked by anyone
+// else at now: paid bookings do, and pending ones until their hold ends.
+func HoldsSlot(b Booking, now time.Time) bool {
+	switch b.Status {
+	case BookingPaid:
+		return true
+	case BookingPending:
+		return b.ExpiresAt == nil || b.ExpiresAt.After(now)
+	}
+	return false
+}
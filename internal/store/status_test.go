@@ -0,0 +1,46 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllowedFrom(t *testing.T) {
+	tests := map[string][]string{
+		BookingPaid:      {BookingPending},
+		BookingCancelled: {BookingPaid, BookingPending},
+		BookingExpired:   {BookingPending},
+		BookingCompleted: {BookingPaid},
+		BookingRefunded:  {BookingPaid},
+		BookingPending:   nil,
+	}
+	for to, want := range tests {
+		if got := AllowedFrom(to); !reflect.DeepEqual(got, want) {
+			t.Errorf("AllowedFrom(%s) = %v, want %v", to, got, want)
+		}
+	}
+}
+
+func TestHoldsSlot(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		b    Booking
+		want bool
+	}{
+		{"paid", Booking{Status: BookingPaid}, true},
+		{"pending without hold", Booking{Status: BookingPending}, true},
+		{"pending hold running", Booking{Status: BookingPending, ExpiresAt: &future}, true},
+		{"pending hold lapsed", Booking{Status: BookingPending, ExpiresAt: &past}, false},
+		{"cancelled", Booking{Status: BookingCancelled}, false},
+		{"completed", Booking{Status: BookingCompleted}, false},
+	}
+	for _, tt := range tests {
+		if got := HoldsSlot(tt.b, now); got != tt.want {
+			t.Errorf("%s: HoldsSlot = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,675 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrAlreadyExists   = errors.New("already exists")
+	ErrSlotUnavailable = errors.New("slot unavailable")
+	// ErrStatusChanged is returned when a booking is no longer in the
+	// status an update expected it to be in.
+	ErrStatusChanged = errors.New("booking status changed")
+	// ErrBookingNotPayable is returned when a payment succeeds for a
+	// booking that is no longer pending.
+	ErrBookingNotPayable = errors.New("booking can no longer be paid")
+	ErrDuplicateEvent    = errors.New("event already processed")
+	// ErrAdminExists is returned when the first admin is created after an
+	// admin already exists.
+	ErrAdminExists = errors.New("an admin already exists")
+	// ErrPromoExhausted and ErrPromoUserLimit are returned when a booking's
+	// promo code has reached its overall or per-user redemption limit.
+	ErrPromoExhausted = errors.New("promo code fully redeemed")
+	ErrPromoUserLimit = errors.New("promo code already used the maximum number of times")
+	// ErrPromoUnavailable is returned when a booking's promo code was
+	// deactivated or left its validity window after it was checked.
+	ErrPromoUnavailable = errors.New("promo code is no longer valid")
+)
+
+type User struct {
+	UserID          int
+	Username        string
+	Email           string
+	Password        string
+	Role            string
+	Phone           string
+	EmailVerifiedAt *time.Time
+	// PendingEmail is the address the user is changing to, until they
+	// confirm it.
+	PendingEmail string
+	// DisabledAt is set while an admin has disabled the account.
+	DisabledAt *time.Time
+	// DeletedAt is set once the user deleted their account and its
+	// personal data was anonymised.
+	DeletedAt *time.Time
+	CreatedAt time.Time
+}
+
+// User list statuses.
+const (
+	UserActive   = "active"
+	UserDisabled = "disabled"
+)
+
+// UserFilter narrows UserRepository.List. Zero values mean "no filter";
+// Query matches part of the username or email and Status is UserActive or
+// UserDisabled.
+type UserFilter struct {
+	Query  string
+	Role   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type Field struct {
+	FieldID      int
+	Name         string
+	PricePerHour int
+	Location     string
+	Rules        SlotRules
+}
+
+// SlotRules constrain the bookings a field accepts. Bookings start and end
+// on a multiple of SlotMinutes past midnight and last between MinMinutes and
+// MaxMinutes.
+type SlotRules struct {
+	SlotMinutes int
+	MinMinutes  int
+	MaxMinutes  int
+}
+
+// OpeningHours are a field's regular hours on one day of the week. Times
+// are HH:MM, and Closes may be 24:00.
+type OpeningHours struct {
+	Weekday time.Weekday
+	Opens   string
+	Closes  string
+}
+
+// FieldException replaces a field's regular hours on one date, such as a
+// holiday. A closed exception has no Opens or Closes.
+type FieldException struct {
+	FieldID int
+	Date    string
+	Closed  bool
+	Opens   string
+	Closes  string
+	Reason  string
+}
+
+// FieldBlackout takes a field out of use, for example for maintenance.
+type FieldBlackout struct {
+	BlackoutID int
+	FieldID    int
+	StartsAt   time.Time
+	EndsAt     time.Time
+	Reason     string
+	CreatedAt  time.Time
+}
+
+// PricingRule sets a field's hourly price for part of its time. A rule
+// applies on Weekdays, or every day when empty, between StartTime and
+// EndTime, and from DateFrom to DateTo when they are set. Where rules
+// overlap, the highest Priority wins, then the newest rule.
+type PricingRule struct {
+	RuleID       int
+	FieldID      int
+	Name         string
+	Weekdays     []time.Weekday
+	StartTime    string
+	EndTime      string
+	DateFrom     string
+	DateTo       string
+	PricePerHour int
+	Priority     int
+	CreatedAt    time.Time
+}
+
+// PriceSegment is one stretch of a booking charged at a single rate.
+// RuleID is nil where the field's base price applies. It is stored as JSON
+// on the booking.
+type PriceSegment struct {
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"`
+	PricePerHour int    `json:"price_per_hour"`
+	Amount       int    `json:"amount"`
+	RuleID       *int   `json:"rule_id"`
+	RuleName     string `json:"rule_name,omitempty"`
+}
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// PromoCode discounts bookings. DiscountValue is a percentage for
+// DiscountPercent and an amount for DiscountFixed. Zero limits and empty
+// FieldIDs mean no restriction. Redemptions counts the pending, paid and
+// completed bookings that used the code.
+type PromoCode struct {
+	PromoID        int
+	Code           string
+	Description    string
+	DiscountType   string
+	DiscountValue  int
+	ValidFrom      *time.Time
+	ValidUntil     *time.Time
+	MaxRedemptions int
+	PerUserLimit   int
+	MinSpend       int
+	FieldIDs       []int
+	Active         bool
+	Redemptions    int
+	CreatedAt      time.Time
+}
+
+// Booking dates are formatted as YYYY-MM-DD and times as HH:MM.
+type Booking struct {
+	BookingID    int
+	UserID       int
+	FieldID      int
+	FieldName    string
+	Location     string
+	BookingDate  string
+	StartTime    string
+	EndTime      string
+	TotalPrice   int
+	Status       string
+	RefundAmount int
+	CancelledAt  *time.Time
+	// ExpiresAt is when an unpaid pending booking stops holding its slot.
+	ExpiresAt   *time.Time
+	CheckedInAt *time.Time
+	// PriceBreakdown splits the price before any discount by rate.
+	// Bookings made before pricing rules have none.
+	PriceBreakdown []PriceSegment
+	// DiscountAmount was taken off by PromoCode. TotalPrice is what is left
+	// to pay.
+	DiscountAmount int
+	PromoCode      string
+	// SeriesID is set on the occurrences of a recurring booking.
+	SeriesID *int
+	// PriceAdjustment is what rescheduling a paid booking changed its price
+	// by: positive is still to be charged, negative is owed back as credit.
+	PriceAdjustment int
+	RescheduleCount int
+	CreatedAt       time.Time
+}
+
+// BookingReschedule records one move of a booking and its price change.
+type BookingReschedule struct {
+	RescheduleID int
+	BookingID    int
+	ActorID      int
+	FromFieldID  int
+	FromDate     string
+	FromStart    string
+	FromEnd      string
+	ToFieldID    int
+	ToDate       string
+	ToStart      string
+	ToEnd        string
+	OldPrice     int
+	NewPrice     int
+	CreatedAt    time.Time
+}
+
+// BookingSeries is a booking that repeats weekly. Each occurrence is an
+// ordinary booking carrying the series ID. Rule is the RRULE it was made
+// from, such as FREQ=WEEKLY;BYDAY=TU;COUNT=10.
+type BookingSeries struct {
+	SeriesID  int
+	UserID    int
+	FieldID   int
+	Rule      string
+	StartDate string
+	StartTime string
+	EndTime   string
+	CreatedAt time.Time
+}
+
+// WaitlistEntry is a user waiting for a slot on a field to free up. Once
+// it does, the entry is offered a pending booking, BookingID, held until
+// OfferExpiresAt.
+type WaitlistEntry struct {
+	EntryID        int
+	UserID         int
+	FieldID        int
+	BookingDate    string
+	StartTime      string
+	EndTime        string
+	Status         string
+	BookingID      *int
+	OfferedAt      *time.Time
+	OfferExpiresAt *time.Time
+	CreatedAt      time.Time
+}
+
+// Waitlist entry statuses.
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+	// WaitlistBooked means the offered booking was paid.
+	WaitlistBooked = "booked"
+	// WaitlistLapsed means the offered booking was not paid in time or was
+	// cancelled.
+	WaitlistLapsed = "lapsed"
+	// WaitlistExpired means the slot started before anything was offered.
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// BusySlot is a time a booking holds a field, without saying whose.
+type BusySlot struct {
+	Date      string
+	StartTime string
+	EndTime   string
+}
+
+// BookingFilter narrows BookingRepository.List. Zero values mean "no
+// filter"; SortBy is one of the BookingSort* constants.
+type BookingFilter struct {
+	UserID   int
+	SeriesID int
+	Status   string
+	DateFrom string
+	DateTo   string
+	SortBy   string
+	SortDesc bool
+	Limit    int
+	Offset   int
+}
+
+const (
+	BookingSortDate       = "booking_date"
+	BookingSortCreatedAt  = "created_at"
+	BookingSortTotalPrice = "total_price"
+)
+
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentRefunded  = "refunded"
+)
+
+// PaymentFilter narrows PaymentRepository.List. Zero values mean "no
+// filter".
+type PaymentFilter struct {
+	UserID int
+	Status string
+	Limit  int
+	Offset int
+}
+
+// Payment is one attempt to pay for a booking through a payment provider.
+type Payment struct {
+	PaymentID      int
+	BookingID      int
+	Provider       string
+	ProviderRef    string
+	Amount         int
+	RefundedAmount int
+	Currency       string
+	Status         string
+	FailureReason  string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// PaymentEvent is an asynchronous notification from a payment provider.
+// Type is the payment status the provider reports, one of the Payment*
+// constants. For refunds Amount is the total refunded so far.
+type PaymentEvent struct {
+	Provider    string
+	EventID     string
+	Type        string
+	ProviderRef string
+	Amount      int
+}
+
+// RefreshToken is one link in a rotation chain. Every token issued by
+// rotating another shares its FamilyID; only the hash of the token is
+// stored.
+type RefreshToken struct {
+	TokenID   int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	// MFA is set when the family began with a second factor, so tokens
+	// rotated from it keep that assurance.
+	MFA       bool
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// User token purposes.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenChangeEmail   = "change_email"
+)
+
+// UserToken is a single-use token mailed to a user, such as an email
+// verification or password reset link. Only its hash is stored.
+type UserToken struct {
+	TokenID   int
+	UserID    int
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFA is a user's TOTP enrollment. It is pending until EnabledAt is set by
+// confirming a first code. LastUsedStep is the last TOTP step accepted, so
+// a code cannot be replayed.
+type MFA struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// LoginCounter tracks recent failed logins for one key, such as an account
+// or an IP address.
+type LoginCounter struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Audit actions.
+const (
+	AuditAdminBootstrap = "admin.bootstrap"
+	AuditAdminCreate    = "admin.create"
+	AuditAdminPromote   = "admin.promote"
+	AuditAccountUnlock  = "account.unlock"
+	AuditRoleChange     = "user.role_change"
+	AuditUserDisable    = "user.disable"
+	AuditUserEnable     = "user.enable"
+	AuditAccountDelete  = "account.delete"
+)
+
+// Role is a named set of permissions that users are assigned.
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// AuditEntry records a privileged action. ActorID is nil when the action
+// was not taken by a signed-in user, such as the bootstrap or CLI.
+type AuditEntry struct {
+	AuditID      int
+	ActorID      *int
+	Action       string
+	TargetUserID int
+	Details      string
+	CreatedAt    time.Time
+}
+
+type UserRepository interface {
+	// Create stores u and sets its UserID. It returns ErrAlreadyExists
+	// when the email is already registered.
+	Create(ctx context.Context, u *User) error
+	GetByID(ctx context.Context, userID int) (User, error)
+	GetByEmail(ctx context.Context, email string) (User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	// CreateFirstAdmin stores u as an admin only if no admin exists yet and
+	// returns ErrAdminExists otherwise.
+	CreateFirstAdmin(ctx context.Context, u *User) error
+	SetRole(ctx context.Context, userID int, role string) error
+	MarkEmailVerified(ctx context.Context, userID int) error
+	SetPassword(ctx context.Context, userID int, passwordHash string) error
+	// List returns one page of matching users, oldest first, and the total
+	// number of matches.
+	List(ctx context.Context, filter UserFilter) ([]User, int, error)
+	UpdateProfile(ctx context.Context, userID int, username, phone string) error
+	SetPendingEmail(ctx context.Context, userID int, email string) error
+	// ChangeEmail replaces the email with a verified one and clears the
+	// pending email. It returns ErrAlreadyExists when another account has
+	// the email.
+	ChangeEmail(ctx context.Context, userID int, email string) error
+	// SetDisabled disables or re-enables the account. Disabling it also
+	// takes the user off every waitlist they wait on.
+	SetDisabled(ctx context.Context, userID int, disabled bool) error
+	// Anonymise deletes the user's account in one transaction: their
+	// personal data is replaced with placeholders, their second factor and
+	// mailed tokens are deleted, their refresh tokens revoked and their
+	// waiting waitlist entries cancelled. Bookings and payments are kept.
+	Anonymise(ctx context.Context, userID int) error
+}
+
+type FieldRepository interface {
+	Create(ctx context.Context, f *Field) error
+	List(ctx context.Context) ([]Field, error)
+	Get(ctx context.Context, fieldID int) (Field, error)
+	// Update changes the field's name, price and location. Its rules are
+	// changed with SetRules.
+	Update(ctx context.Context, f Field) error
+	Delete(ctx context.Context, fieldID int) error
+	SetRules(ctx context.Context, fieldID int, rules SlotRules) error
+	// Hours returns the field's weekly hours, ordered from Sunday. A field
+	// without any is open all day, every day.
+	Hours(ctx context.Context, fieldID int) ([]OpeningHours, error)
+	// SetHours replaces the field's weekly hours.
+	SetHours(ctx context.Context, fieldID int, hours []OpeningHours) error
+	// Exceptions returns the field's exceptions between from and to
+	// inclusive, ordered by date.
+	Exceptions(ctx context.Context, fieldID int, from, to string) ([]FieldException, error)
+	// SetException creates or replaces the exception on e.Date.
+	SetException(ctx context.Context, e FieldException) error
+	// DeleteException returns ErrNotFound if there is no exception on date.
+	DeleteException(ctx context.Context, fieldID int, date string) error
+	// CreateBlackout stores b and sets its BlackoutID and CreatedAt. It
+	// returns ErrNotFound if the field does not exist.
+	CreateBlackout(ctx context.Context, b *FieldBlackout) error
+	// Blackouts returns the field's blackouts that overlap from to to,
+	// ordered by start.
+	Blackouts(ctx context.Context, fieldID int, from, to time.Time) ([]FieldBlackout, error)
+	DeleteBlackout(ctx context.Context, fieldID, blackoutID int) error
+}
+
+type PricingRuleRepository interface {
+	// List returns the field's rules, ordered by RuleID.
+	List(ctx context.Context, fieldID int) ([]PricingRule, error)
+	Get(ctx context.Context, fieldID, ruleID int) (PricingRule, error)
+	// Create stores r and sets its RuleID and CreatedAt. It returns
+	// ErrNotFound if the field does not exist.
+	Create(ctx context.Context, r *PricingRule) error
+	Update(ctx context.Context, r PricingRule) error
+	Delete(ctx context.Context, fieldID, ruleID int) error
+}
+
+type PromoRepository interface {
+	// Create stores p and sets its PromoID and CreatedAt. It returns
+	// ErrAlreadyExists if the code is taken.
+	Create(ctx context.Context, p *PromoCode) error
+	List(ctx context.Context) ([]PromoCode, error)
+	Get(ctx context.Context, promoID int) (PromoCode, error)
+	// GetByCode matches code case-insensitively.
+	GetByCode(ctx context.Context, code string) (PromoCode, error)
+	// Update changes everything but the redemptions. It returns
+	// ErrAlreadyExists if the new code is taken.
+	Update(ctx context.Context, p PromoCode) error
+	// Delete removes the code. Bookings keep their discount.
+	Delete(ctx context.Context, promoID int) error
+}
+
+type BookingRepository interface {
+	IsAvailable(ctx context.Context, fieldID int, bookingDate, startTime, endTime string) (bool, error)
+	// Busy returns the slots held on the field from date from to date to
+	// inclusive by paid bookings and pending bookings whose hold has not
+	// expired, ordered by date and start time.
+	Busy(ctx context.Context, fieldID int, from, to string) ([]BusySlot, error)
+	// Create stores b and sets its BookingID and CreatedAt. It returns
+	// ErrSlotUnavailable when the slot overlaps a paid booking or a pending
+	// booking whose hold has not expired. When b has a PromoCode, the
+	// code's validity and limits are checked in the same transaction,
+	// returning ErrPromoUnavailable, ErrPromoExhausted, ErrPromoUserLimit, or
+	// ErrNotFound if the code is gone.
+	Create(ctx context.Context, b *Booking) error
+	// CreateSeries stores s and its occurrences in one transaction, setting
+	// their IDs and each occurrence's SeriesID. Nothing is stored and
+	// ErrSlotUnavailable is returned if any occurrence overlaps, as with
+	// Create.
+	CreateSeries(ctx context.Context, s *BookingSeries, occurrences []Booking) error
+	GetSeries(ctx context.Context, seriesID int) (BookingSeries, error)
+	// Reschedule moves a booking to b's field, date and times and stores
+	// its new price, adjustment and discount, recording r in the same
+	// transaction. It returns ErrStatusChanged if the booking is no longer
+	// in fromStatus or was rescheduled meanwhile, and ErrSlotUnavailable if
+	// the new slot overlaps another booking. r.ToFieldID and the other To
+	// fields are taken from b.
+	Reschedule(ctx context.Context, b *Booking, fromStatus string, r *BookingReschedule) error
+	// Get returns the booking joined with its field name and location.
+	Get(ctx context.Context, bookingID int) (Booking, error)
+	// List returns one page of matching bookings and the total number of
+	// matches.
+	List(ctx context.Context, filter BookingFilter) ([]Booking, int, error)
+	// Cancel moves a booking from fromStatus to cancelled and records the
+	// refund. It returns ErrStatusChanged if the booking is no longer in
+	// fromStatus.
+	Cancel(ctx context.Context, bookingID int, fromStatus string, refundAmount int) error
+	// ExpirePending moves pending bookings whose hold ended before now to
+	// expired and returns how many it moved.
+	ExpirePending(ctx context.Context, now time.Time) (int, error)
+	// CheckIn records that the players of a paid booking arrived. It
+	// returns ErrStatusChanged if the booking is not paid or was already
+	// checked in.
+	CheckIn(ctx context.Context, bookingID int, at time.Time) error
+	// CompleteFinished moves paid bookings that ended before now, in local
+	// time, to completed and returns how many it moved.
+	CompleteFinished(ctx context.Context, now time.Time) (int, error)
+}
+
+type WaitlistRepository interface {
+	// Create stores e as waiting and sets its EntryID and CreatedAt. It
+	// returns ErrAlreadyExists if the user already waits for the same slot.
+	Create(ctx context.Context, e *WaitlistEntry) error
+	Get(ctx context.Context, entryID int) (WaitlistEntry, error)
+	// ListByUser returns the user's entries, newest first.
+	ListByUser(ctx context.Context, userID int) ([]WaitlistEntry, error)
+	// Waiting returns the waiting entries of enabled users in the order
+	// they joined. A non-zero fieldID and a non-empty date narrow it to one
+	// field and day.
+	Waiting(ctx context.Context, fieldID int, date string) ([]WaitlistEntry, error)
+	// MarkOffered records that a waiting entry was offered bookingID until
+	// expiresAt. It returns ErrStatusChanged if the entry is not waiting.
+	MarkOffered(ctx context.Context, entryID, bookingID int, expiresAt time.Time) error
+	// Cancel takes a waiting entry off the list. It returns
+	// ErrStatusChanged if the entry is not waiting.
+	Cancel(ctx context.Context, entryID int) error
+	// Settle moves offered entries to booked once their booking is paid
+	// and to lapsed once it no longer holds the slot, and expires waiting
+	// entries whose slot started before now. It returns how many changed.
+	Settle(ctx context.Context, now time.Time) (int, error)
+}
+
+type PaymentRepository interface {
+	// Create stores a pending payment attempt and sets its PaymentID. It
+	// returns ErrAlreadyExists if the booking already has a pending or
+	// succeeded payment, so a booking is never charged twice.
+	Create(ctx context.Context, p *Payment) error
+	Get(ctx context.Context, paymentID int) (Payment, error)
+	// List returns one page of matching payments, newest first, and the
+	// total number of matches.
+	List(ctx context.Context, filter PaymentFilter) ([]Payment, int, error)
+	// ListByBooking returns the booking's payment attempts, newest first.
+	ListByBooking(ctx context.Context, bookingID int) ([]Payment, error)
+	// MarkSucceeded moves a pending payment to succeeded and its booking
+	// from pending to paid in one transaction. It changes nothing and
+	// returns ErrStatusChanged if the payment is no longer pending, or
+	// ErrBookingNotPayable if the booking is no longer pending.
+	MarkSucceeded(ctx context.Context, paymentID int) error
+	MarkFailed(ctx context.Context, paymentID int, reason string) error
+	// MarkRefunded records amount as refunded on a succeeded payment.
+	MarkRefunded(ctx context.Context, paymentID int, amount int) error
+	// ApplyEvent records a provider event and applies it to the payment and
+	// its booking in one transaction. It returns ErrDuplicateEvent if the
+	// event was already recorded and ErrBookingNotPayable, recording
+	// nothing, if a success arrives for a booking that is no longer pending.
+	ApplyEvent(ctx context.Context, event PaymentEvent) (Payment, error)
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, t *RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error)
+	// RotateRefreshToken revokes the token oldID and stores next in one
+	// transaction. It returns ErrStatusChanged if oldID was already revoked.
+	RotateRefreshToken(ctx context.Context, oldID int, next *RefreshToken) error
+	RevokeRefreshFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	// DenyAccessToken rejects the access token with this jti until it
+	// expires on its own.
+	DenyAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenDenied(ctx context.Context, jti string) (bool, error)
+	CreateUserToken(ctx context.Context, t *UserToken) error
+	// ConsumeUserToken marks the unused, unexpired token with this purpose
+	// and hash as used and returns it. It returns ErrNotFound otherwise.
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string, now time.Time) (UserToken, error)
+	// InvalidateUserTokens marks the user's unused tokens with this purpose
+	// as used.
+	InvalidateUserTokens(ctx context.Context, userID int, purpose string) error
+	// DeleteExpired removes refresh tokens, user tokens and deny-list
+	// entries that expired before now and returns how many it removed.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+type MFARepository interface {
+	Get(ctx context.Context, userID int) (MFA, error)
+	// SavePending starts or restarts enrollment with secret. It returns
+	// ErrAlreadyExists if MFA is already enabled.
+	SavePending(ctx context.Context, userID int, secret string) error
+	// Enable turns MFA on after step was verified and replaces the user's
+	// recovery codes with codeHashes.
+	Enable(ctx context.Context, userID int, step int64, codeHashes []string) error
+	// UseStep records step as used. It returns ErrStatusChanged if step is
+	// not later than the last one used.
+	UseStep(ctx context.Context, userID int, step int64) error
+	// UseRecoveryCode marks the unused code with this hash as used. It
+	// returns ErrNotFound if there is none.
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	RemainingRecoveryCodes(ctx context.Context, userID int) (int, error)
+	// Disable removes the enrollment and recovery codes.
+	Disable(ctx context.Context, userID int) error
+}
+
+type LoginAttemptRepository interface {
+	// Attempt counts an attempt at now as a failure against every key,
+	// checking and counting in one atomic step so parallel attempts cannot
+	// all get under a limit. If any key is locked at now nothing is counted
+	// and ok is false. Otherwise failures restart from one when the previous
+	// failure is older than window, and a key is locked until now plus
+	// lockFor(key, failures) when that is positive. The counters are
+	// returned in the order of keys.
+	Attempt(ctx context.Context, keys []string, now time.Time, window time.Duration, lockFor func(key string, failures int) time.Duration) (counters []LoginCounter, ok bool, err error)
+	// Forgive takes back one failure counted against key.
+	Forgive(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	// DeleteStale removes counters with no failure or lock after before and
+	// returns how many it removed.
+	DeleteStale(ctx context.Context, before time.Time) (int, error)
+}
+
+type RoleRepository interface {
+	// Get returns the role with its permissions, or ErrNotFound.
+	Get(ctx context.Context, name string) (Role, error)
+	List(ctx context.Context) ([]Role, error)
+}
+
+type AuditRepository interface {
+	// Record stores e and sets its AuditID and CreatedAt.
+	Record(ctx context.Context, e *AuditEntry) error
+	// List returns the newest entries first.
+	List(ctx context.Context, limit, offset int) ([]AuditEntry, error)
+}
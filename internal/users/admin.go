@@ -0,0 +1,551 @@
+package users
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+)
+
+const BootstrapTokenHeader = "X-Bootstrap-Token"
+
+var (
+	ErrUsernameTooShort = errors.New("username must be at least 3 characters")
+	ErrInvalidEmail     = errors.New("invalid email address")
+	ErrPasswordTooShort = errors.New("password must be at least 6 characters")
+)
+
+// NewAccount validates the credentials and returns a user with a hashed
+// password, ready to be stored.
+func NewAccount(username, email, password, role string) (store.User, error) {
+	if len(username) < 3 {
+		return store.User{}, ErrUsernameTooShort
+	}
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return store.User{}, ErrInvalidEmail
+	}
+	if len(password) < 6 {
+		return store.User{}, ErrPasswordTooShort
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return store.User{}, err
+	}
+
+	return store.User{
+		Username: username,
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     role,
+	}, nil
+}
+
+// Audit logs a privileged action and stores it in the audit log. A failure
+// to store the entry is logged but not returned, since the action has
+// already happened.
+func Audit(ctx context.Context, audit store.AuditRepository, entry store.AuditEntry) {
+	actor := "system"
+	if entry.ActorID != nil {
+		actor = strconv.Itoa(*entry.ActorID)
+	}
+	slog.Info("Admin audit", "action", entry.Action, "actor", actor, "target_user_id", entry.TargetUserID, "details", entry.Details)
+
+	if err := audit.Record(ctx, &entry); err != nil {
+		slog.Error("Failed to record audit entry", "action", entry.Action, "target_user_id", entry.TargetUserID, "error", err)
+	}
+}
+
+// BootstrapAdmin creates the first admin. It requires the bootstrap token
+// and only works while no admin exists.
+func BootstrapAdmin(users store.UserRepository, audit store.AuditRepository, sessions *auth.Sessions, bootstrapToken string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if bootstrapToken == "" {
+			return errorResponse(c, "Admin bootstrap is disabled", 403)
+		}
+		provided := c.Get(BootstrapTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(bootstrapToken)) != 1 {
+			return errorResponse(c, "Invalid bootstrap token", 401)
+		}
+
+		var req struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return errorResponse(c, "Invalid request body", 400)
+		}
+
+		user, message, status := newAccount(req.Username, req.Email, req.Password, "admin")
+		if message != "" {
+			return errorResponse(c, message, status)
+		}
+
+		if err := users.CreateFirstAdmin(c.UserContext(), &user); err != nil {
+			switch {
+			case errors.Is(err, store.ErrAdminExists):
+				return errorResponse(c, "An admin already exists. Ask an admin to grant access", 409)
+			case errors.Is(err, store.ErrAlreadyExists):
+				return errorResponse(c, "Email already registered", 400)
+			}
+			slog.Error("Failed to create first admin", "error", err)
+			return errorResponse(c, "Failed to create user", 500)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			Action:       store.AuditAdminBootstrap,
+			TargetUserID: user.UserID,
+			Details:      "first admin created with the bootstrap token from " + c.IP(),
+		})
+
+		tokens, err := sessions.Start(c.UserContext(), user, false)
+		if err != nil {
+			return errorResponse(c, "Failed to generate token", 500)
+		}
+
+		return c.Status(201).JSON(fiber.Map{
+			"message": "Admin registered successfully",
+			"user":    tokenResponse(user.Email, tokens),
+		})
+	}
+}
+
+// RegisterAdmin lets a signed-in admin create another admin account.
+func RegisterAdmin(users store.UserRepository, audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actorID, _ := c.Locals("user_id").(int)
+
+		var req struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return errorResponse(c, "Invalid request body", 400)
+		}
+
+		user, message, status := newAccount(req.Username, req.Email, req.Password, "admin")
+		if message != "" {
+			return errorResponse(c, message, status)
+		}
+
+		if err := users.Create(c.UserContext(), &user); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return errorResponse(c, "Email already registered", 400)
+			}
+			slog.Error("Failed to create user", "error", err)
+			return errorResponse(c, "Failed to create user", 500)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &actorID,
+			Action:       store.AuditAdminCreate,
+			TargetUserID: user.UserID,
+			Details:      "admin account created",
+		})
+
+		return c.Status(201).JSON(fiber.Map{
+			"message": "Admin registered successfully",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+// PromoteAdmin lets a signed-in admin grant the admin role to an existing
+// user. Like SetUserRole, it ends the user's sessions.
+func PromoteAdmin(users store.UserRepository, tokens store.TokenRepository, audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actorID, _ := c.Locals("user_id").(int)
+
+		userID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return errorResponse(c, "Invalid user ID", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "User not found", 404)
+			}
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if user.Role == "admin" {
+			return errorResponse(c, "User is already an admin", 409)
+		}
+
+		if err := users.SetRole(c.UserContext(), user.UserID, "admin"); err != nil {
+			slog.Error("Failed to promote user", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to promote user", 500)
+		}
+		if err := tokens.RevokeUserRefreshTokens(c.UserContext(), userID); err != nil {
+			slog.Error("Failed to revoke sessions after role change", "user_id", userID, "error", err)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &actorID,
+			Action:       store.AuditAdminPromote,
+			TargetUserID: user.UserID,
+			Details:      fmt.Sprintf("role changed from %s to admin", user.Role),
+		})
+
+		user.Role = "admin"
+		return c.JSON(fiber.Map{
+			"message": "User promoted to admin",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+// SetUserRole lets a signed-in admin assign any role to another user. The
+// user's access tokens stop working at once and their refresh tokens are
+// revoked, so they have to sign in again to pick up the new permissions.
+func SetUserRole(users store.UserRepository, roles store.RoleRepository, tokens store.TokenRepository, audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actorID, _ := c.Locals("user_id").(int)
+
+		userID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return errorResponse(c, "Invalid user ID", 400)
+		}
+		// Keeps an admin from locking themselves, and possibly everyone,
+		// out of user management.
+		if userID == actorID {
+			return errorResponse(c, "You cannot change your own role", 400)
+		}
+
+		var req struct {
+			Role string `json:"role"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Role == "" {
+			return errorResponse(c, "Role is required", 400)
+		}
+
+		if _, err := roles.Get(c.UserContext(), req.Role); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "Unknown role", 400)
+			}
+			slog.Error("Failed to fetch role", "role", req.Role, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "User not found", 404)
+			}
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if user.Role == req.Role {
+			return errorResponse(c, "User already has this role", 409)
+		}
+
+		if err := users.SetRole(c.UserContext(), user.UserID, req.Role); err != nil {
+			slog.Error("Failed to set role", "user_id", userID, "role", req.Role, "error", err)
+			return errorResponse(c, "Failed to change role", 500)
+		}
+		if err := tokens.RevokeUserRefreshTokens(c.UserContext(), userID); err != nil {
+			slog.Error("Failed to revoke sessions after role change", "user_id", userID, "error", err)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &actorID,
+			Action:       store.AuditRoleChange,
+			TargetUserID: user.UserID,
+			Details:      fmt.Sprintf("role changed from %s to %s", user.Role, req.Role),
+		})
+
+		user.Role = req.Role
+		return c.JSON(fiber.Map{
+			"message": "Role changed successfully",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+func ListRoles(roles store.RoleRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list, err := roles.List(c.UserContext())
+		if err != nil {
+			slog.Error("Failed to list roles", "error", err)
+			return errorResponse(c, "Failed to fetch roles", 500)
+		}
+
+		result := make([]fiber.Map, 0, len(list))
+		for _, role := range list {
+			result = append(result, fiber.Map{
+				"name":        role.Name,
+				"description": role.Description,
+				"permissions": role.Permissions,
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Roles retrieved successfully",
+			"roles":   result,
+		})
+	}
+}
+
+// UnlockAccount lets a signed-in admin lift a login lockout.
+func UnlockAccount(users store.UserRepository, throttle *auth.LoginThrottle, audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actorID, _ := c.Locals("user_id").(int)
+
+		userID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return errorResponse(c, "Invalid user ID", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "User not found", 404)
+			}
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		if err := throttle.Unlock(c.UserContext(), user.Email); err != nil {
+			slog.Error("Failed to unlock account", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to unlock account", 500)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &actorID,
+			Action:       store.AuditAccountUnlock,
+			TargetUserID: user.UserID,
+			Details:      "login lockout cleared",
+		})
+
+		return c.JSON(fiber.Map{
+			"message": "Account unlocked",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+// ListUsers lets a signed-in admin browse users. q matches part of the
+// username or email.
+func ListUsers(users store.UserRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := store.UserFilter{
+			Query:  strings.TrimSpace(c.Query("q")),
+			Role:   c.Query("role"),
+			Status: c.Query("status"),
+		}
+		switch filter.Status {
+		case "", store.UserActive, store.UserDisabled:
+		default:
+			return errorResponse(c, "Invalid status filter. Use active or disabled", 400)
+		}
+
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", 50)
+		if page < 1 || limit < 1 || limit > 200 {
+			return errorResponse(c, "Invalid pagination. page must be >= 1 and limit between 1 and 200", 400)
+		}
+		filter.Limit = limit
+		filter.Offset = (page - 1) * limit
+
+		list, total, err := users.List(c.UserContext(), filter)
+		if err != nil {
+			slog.Error("Failed to list users", "error", err)
+			return errorResponse(c, "Failed to fetch users", 500)
+		}
+
+		result := make([]fiber.Map, 0, len(list))
+		for _, user := range list {
+			result = append(result, userResponse(user))
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Users retrieved successfully",
+			"users":   result,
+			"pagination": fiber.Map{
+				"page":        page,
+				"limit":       limit,
+				"total":       total,
+				"total_pages": (total + limit - 1) / limit,
+			},
+		})
+	}
+}
+
+func GetUser(users store.UserRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return errorResponse(c, "Invalid user ID", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "User not found", 404)
+			}
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "User retrieved successfully",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+// SetUserDisabled lets a signed-in admin disable or re-enable an account. A
+// disabled user's tokens stop working at once and they cannot log in.
+func SetUserDisabled(users store.UserRepository, tokens store.TokenRepository, audit store.AuditRepository, disabled bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		actorID, _ := c.Locals("user_id").(int)
+
+		userID, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return errorResponse(c, "Invalid user ID", 400)
+		}
+		if userID == actorID {
+			return errorResponse(c, "You cannot disable or enable your own account", 400)
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		// The body is optional.
+		_ = c.BodyParser(&req)
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "User not found", 404)
+			}
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if user.DeletedAt != nil {
+			return errorResponse(c, "User has been deleted", 409)
+		}
+		if (user.DisabledAt != nil) == disabled {
+			if disabled {
+				return errorResponse(c, "User is already disabled", 409)
+			}
+			return errorResponse(c, "User is not disabled", 409)
+		}
+
+		if err := users.SetDisabled(c.UserContext(), userID, disabled); err != nil {
+			slog.Error("Failed to update user", "user_id", userID, "disabled", disabled, "error", err)
+			return errorResponse(c, "Failed to update user", 500)
+		}
+
+		action, message := store.AuditUserEnable, "User enabled"
+		if disabled {
+			action, message = store.AuditUserDisable, "User disabled"
+			if err := tokens.RevokeUserRefreshTokens(c.UserContext(), userID); err != nil {
+				slog.Error("Failed to revoke sessions of disabled user", "user_id", userID, "error", err)
+			}
+		}
+
+		details := strings.ToLower(message)
+		if req.Reason != "" {
+			details += ": " + req.Reason
+		}
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &actorID,
+			Action:       action,
+			TargetUserID: user.UserID,
+			Details:      details,
+		})
+
+		user, err = users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": message,
+			"user":    userResponse(user),
+		})
+	}
+}
+
+func ListAuditLog(audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		page := c.QueryInt("page", 1)
+		limit := c.QueryInt("limit", 50)
+		if page < 1 || limit < 1 || limit > 200 {
+			return errorResponse(c, "Invalid pagination. page must be >= 1 and limit between 1 and 200", 400)
+		}
+
+		entries, err := audit.List(c.UserContext(), limit, (page-1)*limit)
+		if err != nil {
+			slog.Error("Failed to list audit log", "error", err)
+			return errorResponse(c, "Failed to fetch audit log", 500)
+		}
+
+		result := make([]fiber.Map, 0, len(entries))
+		for _, e := range entries {
+			result = append(result, fiber.Map{
+				"audit_id":       e.AuditID,
+				"actor_id":       e.ActorID,
+				"action":         e.Action,
+				"target_user_id": e.TargetUserID,
+				"details":        e.Details,
+				"created_at":     e.CreatedAt,
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Audit log retrieved successfully",
+			"entries": result,
+			"page":    page,
+			"limit":   limit,
+		})
+	}
+}
+
+// newAccount returns the error message and status to respond with when
+// the credentials are rejected.
+func newAccount(username, email, password, role string) (store.User, string, int) {
+	user, err := NewAccount(username, email, password, role)
+	switch {
+	case errors.Is(err, ErrUsernameTooShort):
+		return user, "Username must be at least 3 characters", 400
+	case errors.Is(err, ErrInvalidEmail):
+		return user, "Invalid email address", 400
+	case errors.Is(err, ErrPasswordTooShort):
+		return user, "Password must be at least 6 characters", 400
+	case err != nil:
+		return user, "Failed to process password", 500
+	}
+	return user, "", 0
+}
+
+func userResponse(user store.User) fiber.Map {
+	return fiber.Map{
+		"user_id":           user.UserID,
+		"username":          user.Username,
+		"email":             user.Email,
+		"role":              user.Role,
+		"phone":             user.Phone,
+		"email_verified_at": user.EmailVerifiedAt,
+		"disabled_at":       user.DisabledAt,
+		"deleted_at":        user.DeletedAt,
+		"created_at":        user.CreatedAt,
+	}
+}
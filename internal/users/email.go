@@ -0,0 +1,251 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/mailer"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+)
+
+// Emails issues the single-use tokens behind email verification and
+// password reset and mails them as links to baseURL.
+type Emails struct {
+	users     store.UserRepository
+	tokens    store.TokenRepository
+	mailer    mailer.Mailer
+	baseURL   string
+	verifyTTL time.Duration
+	resetTTL  time.Duration
+}
+
+func NewEmails(users store.UserRepository, tokens store.TokenRepository, m mailer.Mailer, baseURL string, verifyTTL, resetTTL time.Duration) *Emails {
+	return &Emails{
+		users:     users,
+		tokens:    tokens,
+		mailer:    m,
+		baseURL:   baseURL,
+		verifyTTL: verifyTTL,
+		resetTTL:  resetTTL,
+	}
+}
+
+// SendVerification mails a new verification link, invalidating any link
+// sent before.
+func (e *Emails) SendVerification(ctx context.Context, user store.User) error {
+	raw, err := e.issue(ctx, user.UserID, store.TokenVerifyEmail, e.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	e.send(user.Email, "Verify your email address", fmt.Sprintf(
+		"Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThe link expires in %s.\n",
+		user.Username, e.baseURL, raw, e.verifyTTL,
+	))
+	return nil
+}
+
+// SendPasswordReset mails a new password reset link, invalidating any
+// link sent before.
+func (e *Emails) SendPasswordReset(ctx context.Context, user store.User) error {
+	raw, err := e.issue(ctx, user.UserID, store.TokenResetPassword, e.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	e.send(user.Email, "Reset your password", fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to reset your password. If it was you, open the link below:\n\n%s/reset-password?token=%s\n\nThe link expires in %s. If you did not ask for this, ignore this email.\n",
+		user.Username, e.baseURL, raw, e.resetTTL,
+	))
+	return nil
+}
+
+// SendEmailChange mails a confirmation link to the new address and lets
+// the current address know about the change.
+func (e *Emails) SendEmailChange(ctx context.Context, user store.User, newEmail string) error {
+	raw, err := e.issue(ctx, user.UserID, store.TokenChangeEmail, e.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	e.send(newEmail, "Confirm your new email address", fmt.Sprintf(
+		"Hi %s,\n\nConfirm that you want to use this address for your account by opening the link below:\n\n%s/confirm-email?token=%s\n\nThe link expires in %s.\n",
+		user.Username, e.baseURL, raw, e.verifyTTL,
+	))
+	e.send(user.Email, "Your email address is changing", fmt.Sprintf(
+		"Hi %s,\n\nSomeone asked to change the email address of your account to %s. It changes once the new address is confirmed. If this was not you, change your password now.\n",
+		user.Username, newEmail,
+	))
+	return nil
+}
+
+func (e *Emails) issue(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	if err := e.tokens.InvalidateUserTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	raw, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := store.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := e.tokens.CreateUserToken(ctx, &token); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// send mails in the background so a slow mail server neither holds up the
+// request nor reveals whether the address belongs to an account.
+func (e *Emails) send(to, subject, body string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := e.mailer.Send(ctx, mailer.Message{To: to, Subject: subject, Body: body}); err != nil {
+			slog.Error("Failed to send email", "to", to, "subject", subject, "error", err)
+		}
+	}()
+}
+
+func RequestVerification(users store.UserRepository, emails *Emails) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if user.EmailVerifiedAt != nil {
+			return errorResponse(c, "Email is already verified", 409)
+		}
+
+		if err := emails.SendVerification(c.UserContext(), user); err != nil {
+			slog.Error("Failed to issue verification token", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to send verification email", 500)
+		}
+
+		return c.Status(202).JSON(fiber.Map{
+			"message": "Verification email sent",
+		})
+	}
+}
+
+func VerifyEmail(users store.UserRepository, tokens store.TokenRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Token string `json:"token"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return errorResponse(c, "Token is required", 400)
+		}
+
+		token, err := tokens.ConsumeUserToken(c.UserContext(), store.TokenVerifyEmail, auth.HashToken(req.Token), time.Now())
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "Invalid or expired token", 400)
+			}
+			slog.Error("Failed to consume verification token", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		if err := users.MarkEmailVerified(c.UserContext(), token.UserID); err != nil {
+			slog.Error("Failed to mark email verified", "user_id", token.UserID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Email verified successfully",
+		})
+	}
+}
+
+func ForgotPassword(users store.UserRepository, emails *Emails) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Email string `json:"email"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Email == "" {
+			return errorResponse(c, "Email is required", 400)
+		}
+
+		// Respond the same way whether or not the account exists.
+		user, err := users.GetByEmail(c.UserContext(), req.Email)
+		if err == nil {
+			err = emails.SendPasswordReset(c.UserContext(), user)
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Failed to issue password reset token", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		return c.Status(202).JSON(fiber.Map{
+			"message": "If the email is registered, a reset link has been sent",
+		})
+	}
+}
+
+// ResetPassword sets a new password from a reset token and signs the user
+// out of every session.
+func ResetPassword(users store.UserRepository, tokens store.TokenRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return errorResponse(c, "Token is required", 400)
+		}
+		if len(req.Password) < 6 {
+			return errorResponse(c, "Password must be at least 6 characters", 400)
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return errorResponse(c, "Failed to process password", 500)
+		}
+
+		token, err := tokens.ConsumeUserToken(c.UserContext(), store.TokenResetPassword, auth.HashToken(req.Token), time.Now())
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "Invalid or expired token", 400)
+			}
+			slog.Error("Failed to consume reset token", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		if err := users.SetPassword(c.UserContext(), token.UserID, string(hashedPassword)); err != nil {
+			slog.Error("Failed to set password", "user_id", token.UserID, "error", err)
+			return errorResponse(c, "Failed to reset password", 500)
+		}
+		// Receiving the link proves control of the mailbox.
+		if err := users.MarkEmailVerified(c.UserContext(), token.UserID); err != nil {
+			slog.Error("Failed to mark email verified", "user_id", token.UserID, "error", err)
+		}
+		if err := tokens.RevokeUserRefreshTokens(c.UserContext(), token.UserID); err != nil {
+			slog.Error("Failed to revoke sessions after password reset", "user_id", token.UserID, "error", err)
+			return errorResponse(c, "Failed to reset password", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Password reset successfully. Please log in again",
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package users
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
+)
+
+// accountExport is everything the service stores about a user that they
+// can be given back. Password hashes, MFA secrets and tokens are left out.
+type accountExport struct {
+	ExportedAt time.Time         `json:"exported_at"`
+	Profile    exportedProfile   `json:"profile"`
+	Bookings   []exportedBooking `json:"bookings"`
+	Payments   []exportedPayment `json:"payments"`
+}
+
+type exportedProfile struct {
+	UserID          int        `json:"user_id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	Phone           string     `json:"phone,omitempty"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type exportedBooking struct {
+	BookingID      int                  `json:"booking_id"`
+	FieldID        int                  `json:"field_id"`
+	FieldName      string               `json:"field_name"`
+	Location       string               `json:"location"`
+	BookingDate    string               `json:"booking_date"`
+	StartTime      string               `json:"start_time"`
+	EndTime        string               `json:"end_time"`
+	TotalPrice     int                  `json:"total_price"`
+	PriceBreakdown []store.PriceSegment `json:"price_breakdown"`
+	DiscountAmount int                  `json:"discount_amount"`
+	PromoCode      string               `json:"promo_code,omitempty"`
+	Status         string               `json:"status"`
+	RefundAmount   int                  `json:"refund_amount"`
+	CancelledAt    *time.Time           `json:"cancelled_at"`
+	CheckedInAt    *time.Time           `json:"checked_in_at"`
+	CreatedAt      time.Time            `json:"created_at"`
+}
+
+type exportedPayment struct {
+	PaymentID      int       `json:"payment_id"`
+	BookingID      int       `json:"booking_id"`
+	Provider       string    `json:"provider"`
+	ProviderRef    string    `json:"provider_ref"`
+	Amount         int       `json:"amount"`
+	RefundedAmount int       `json:"refunded_amount"`
+	Currency       string    `json:"currency"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ExportAccount returns the caller's profile, bookings and payments as a
+// JSON document, or as a ZIP of one JSON file each with ?format=zip.
+func ExportAccount(users store.UserRepository, mfas store.MFARepository, bookings store.BookingRepository, payments store.PaymentRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		format := c.Query("format", "json")
+		if format != "json" && format != "zip" {
+			return errorResponse(c, "Invalid format. Use json or zip", 400)
+		}
+
+		export, err := exportAccount(c, users, mfas, bookings, payments, userID)
+		if err != nil {
+			slog.Error("Failed to export account", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to export account", 500)
+		}
+		slog.Info("Account exported", "user_id", userID, "format", format)
+
+		filename := fmt.Sprintf("account-%d-%s", userID, export.ExportedAt.Format("20060102"))
+		if format == "json" {
+			c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.json"`)
+			return c.JSON(export)
+		}
+
+		archive, err := zipExport(export)
+		if err != nil {
+			slog.Error("Failed to build export archive", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to export account", 500)
+		}
+		c.Set(fiber.HeaderContentType, "application/zip")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.zip"`)
+		return c.Send(archive)
+	}
+}
+
+// DeleteAccount anonymises the caller's account once their password is
+// confirmed. Bookings and payments are kept for accounting, and every
+// token the user holds stops working.
+func DeleteAccount(users store.UserRepository, throttle *auth.LoginThrottle, audit store.AuditRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		var req struct {
+			Password string `json:"password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Password == "" {
+			return errorResponse(c, "Password is required", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if rejected, err := confirmPassword(c, throttle, user, req.Password); rejected {
+			return err
+		}
+
+		if err := users.Anonymise(c.UserContext(), userID); err != nil {
+			slog.Error("Failed to delete account", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to delete account", 500)
+		}
+		// The counter is keyed by the old email address.
+		if err := throttle.Unlock(c.UserContext(), user.Email); err != nil {
+			slog.Error("Failed to clear login failures", "user_id", userID, "error", err)
+		}
+
+		Audit(c.UserContext(), audit, store.AuditEntry{
+			ActorID:      &userID,
+			Action:       store.AuditAccountDelete,
+			TargetUserID: userID,
+			Details:      "account deleted and personal data anonymised at the user's request",
+		})
+
+		return c.JSON(fiber.Map{
+			"message": "Account deleted",
+		})
+	}
+}
+
+func exportAccount(c *fiber.Ctx, users store.UserRepository, mfas store.MFARepository, bookings store.BookingRepository, payments store.PaymentRepository, userID int) (accountExport, error) {
+	ctx := c.UserContext()
+
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	mfa, err := mfas.Get(ctx, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return accountExport{}, err
+	}
+
+	export := accountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: exportedProfile{
+			UserID:          user.UserID,
+			Username:        user.Username,
+			Email:           user.Email,
+			PendingEmail:    user.PendingEmail,
+			Phone:           user.Phone,
+			Role:            user.Role,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			MFAEnabled:      err == nil && mfa.EnabledAt != nil,
+			CreatedAt:       user.CreatedAt,
+		},
+		Bookings: []exportedBooking{},
+		Payments: []exportedPayment{},
+	}
+
+	list, _, err := bookings.List(ctx, store.BookingFilter{UserID: userID, SortBy: store.BookingSortCreatedAt})
+	if err != nil {
+		return accountExport{}, err
+	}
+	for _, b := range list {
+		export.Bookings = append(export.Bookings, exportedBooking{
+			BookingID:      b.BookingID,
+			FieldID:        b.FieldID,
+			FieldName:      b.FieldName,
+			Location:       b.Location,
+			BookingDate:    b.BookingDate,
+			StartTime:      b.StartTime,
+			EndTime:        b.EndTime,
+			TotalPrice:     b.TotalPrice,
+			PriceBreakdown: b.PriceBreakdown,
+			DiscountAmount: b.DiscountAmount,
+			PromoCode:      b.PromoCode,
+			Status:         b.Status,
+			RefundAmount:   b.RefundAmount,
+			CancelledAt:    b.CancelledAt,
+			CheckedInAt:    b.CheckedInAt,
+			CreatedAt:      b.CreatedAt,
+		})
+	}
+
+	paid, _, err := payments.List(ctx, store.PaymentFilter{UserID: userID})
+	if err != nil {
+		return accountExport{}, err
+	}
+	for _, p := range paid {
+		export.Payments = append(export.Payments, exportedPayment{
+			PaymentID:      p.PaymentID,
+			BookingID:      p.BookingID,
+			Provider:       p.Provider,
+			ProviderRef:    p.ProviderRef,
+			Amount:         p.Amount,
+			RefundedAmount: p.RefundedAmount,
+			Currency:       p.Currency,
+			Status:         p.Status,
+			CreatedAt:      p.CreatedAt,
+			UpdatedAt:      p.UpdatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+func zipExport(export accountExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"bookings.json", export.Bookings},
+		{"payments.json", export.Payments},
+	} {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
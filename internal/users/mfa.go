@@ -0,0 +1,277 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+	"take-home-test/internal/totp"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/exp/slog"
+)
+
+const recoveryCodeCount = 10
+
+func EnrollMFA(users store.UserRepository, mfas store.MFARepository, issuer string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return errorResponse(c, "Failed to generate secret", 500)
+		}
+
+		if err := mfas.SavePending(c.UserContext(), userID, secret); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return errorResponse(c, "Two-factor authentication is already enabled", 409)
+			}
+			slog.Error("Failed to save mfa secret", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to start enrollment", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message":          "Scan the provisioning URI with an authenticator app, then confirm with a code",
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(secret, issuer, user.Email),
+		})
+	}
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves their
+// authenticator works, and returns the recovery codes, which are shown
+// only this once.
+func ConfirmMFA(users store.UserRepository, mfas store.MFARepository, sessions *auth.Sessions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		var req struct {
+			Code string `json:"code"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Code == "" {
+			return errorResponse(c, "Code is required", 400)
+		}
+
+		mfa, err := mfas.Get(c.UserContext(), userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "Start enrollment first", 409)
+			}
+			slog.Error("Failed to fetch mfa", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if mfa.EnabledAt != nil {
+			return errorResponse(c, "Two-factor authentication is already enabled", 409)
+		}
+
+		step, ok := totp.Validate(mfa.Secret, req.Code, time.Now(), 1)
+		if !ok {
+			return errorResponse(c, "Invalid code", 400)
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			return errorResponse(c, "Failed to generate recovery codes", 500)
+		}
+
+		if err := mfas.Enable(c.UserContext(), userID, step, hashes); err != nil {
+			if errors.Is(err, store.ErrStatusChanged) {
+				return errorResponse(c, "Enrollment changed, please start again", 409)
+			}
+			slog.Error("Failed to enable mfa", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to enable two-factor authentication", 500)
+		}
+		slog.Info("Two-factor authentication enabled", "user_id", userID)
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		tokens, err := sessions.Start(c.UserContext(), user, true)
+		if err != nil {
+			return errorResponse(c, "Failed to generate token", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message":        "Two-factor authentication enabled. Store the recovery codes somewhere safe",
+			"recovery_codes": codes,
+			"user":           tokenResponse(user.Email, tokens),
+		})
+	}
+}
+
+// VerifyMFA completes a login that was paused for the second factor.
+// Either a TOTP code or a recovery code is accepted.
+func VerifyMFA(mfas store.MFARepository, sessions *auth.Sessions, throttle *auth.LoginThrottle) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			MFAToken     string `json:"mfa_token"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.MFAToken == "" {
+			return errorResponse(c, "mfa_token is required", 400)
+		}
+		if req.Code == "" && req.RecoveryCode == "" {
+			return errorResponse(c, "code or recovery_code is required", 400)
+		}
+
+		user, err := sessions.MFAChallengeUser(c.UserContext(), req.MFAToken)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidMFAChallenge) {
+				return errorResponse(c, "Invalid or expired mfa token, please log in again", 401)
+			}
+			slog.Error("Failed to check mfa token", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		if limited, err := checkThrottle(c, throttle, user.Email); limited {
+			return err
+		}
+
+		ok, err := checkSecondFactor(c.UserContext(), mfas, user.UserID, req.Code, req.RecoveryCode)
+		if err != nil {
+			slog.Error("Failed to check second factor", "user_id", user.UserID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if !ok {
+			return errorResponse(c, "Invalid code", 401)
+		}
+		passThrottle(c, throttle, user.Email)
+
+		tokens, err := sessions.FinishMFAChallenge(c.UserContext(), req.MFAToken, user)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidMFAChallenge) {
+				return errorResponse(c, "Invalid or expired mfa token, please log in again", 401)
+			}
+			if errors.Is(err, auth.ErrAccountDisabled) {
+				return errorResponse(c, "Account is disabled", 403)
+			}
+			return errorResponse(c, "Failed to generate token", 500)
+		}
+
+		response := fiber.Map{
+			"message": "Login successful",
+			"user":    tokenResponse(user.Email, tokens),
+		}
+		if req.RecoveryCode != "" {
+			remaining, err := mfas.RemainingRecoveryCodes(c.UserContext(), user.UserID)
+			if err == nil {
+				response["recovery_codes_remaining"] = remaining
+			}
+			slog.Warn("Recovery code used to log in", "user_id", user.UserID, "remaining", remaining)
+		}
+
+		return c.JSON(response)
+	}
+}
+
+// DisableMFA turns two-factor authentication off. It requires a current
+// code so a stolen access token alone cannot remove the second factor.
+func DisableMFA(mfas store.MFARepository, throttle *auth.LoginThrottle) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		email, _ := c.Locals("email").(string)
+
+		var req struct {
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+			return errorResponse(c, "code or recovery_code is required", 400)
+		}
+
+		if limited, err := checkThrottle(c, throttle, email); limited {
+			return err
+		}
+
+		ok, err := checkSecondFactor(c.UserContext(), mfas, userID, req.Code, req.RecoveryCode)
+		if err != nil {
+			slog.Error("Failed to check second factor", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if !ok {
+			return errorResponse(c, "Invalid code", 401)
+		}
+		passThrottle(c, throttle, email)
+
+		if err := mfas.Disable(c.UserContext(), userID); err != nil {
+			slog.Error("Failed to disable mfa", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to disable two-factor authentication", 500)
+		}
+		slog.Warn("Two-factor authentication disabled", "user_id", userID)
+
+		return c.JSON(fiber.Map{
+			"message": "Two-factor authentication disabled",
+		})
+	}
+}
+
+// checkSecondFactor reports whether code, or else recoveryCode, is valid
+// for the user's enabled enrollment, and spends it.
+func checkSecondFactor(ctx context.Context, mfas store.MFARepository, userID int, code, recoveryCode string) (bool, error) {
+	mfa, err := mfas.Get(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if mfa.EnabledAt == nil {
+		return false, nil
+	}
+
+	if code == "" {
+		err := mfas.UseRecoveryCode(ctx, userID, auth.HashToken(normalizeRecoveryCode(recoveryCode)))
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now(), 1)
+	if !ok {
+		return false, nil
+	}
+	// A code is only good once, even within its 30 seconds.
+	err = mfas.UseStep(ctx, userID, step)
+	if errors.Is(err, store.ErrStatusChanged) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, auth.HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
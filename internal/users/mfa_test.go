@@ -0,0 +1,48 @@
+package users
+
+import (
+	"context"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/totp"
+	"testing"
+	"time"
+)
+
+func TestCheckSecondFactorRejectsReplayedCode(t *testing.T) {
+	ctx := context.Background()
+	mfas := memory.NewMFARepository(memory.NewDB())
+	const userID = 1
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mfas.SavePending(ctx, userID, secret); err != nil {
+		t.Fatalf("save pending: %v", err)
+	}
+	step := totp.Step(time.Now())
+	// Enrolled a while ago, so no step around now has been used yet.
+	if err := mfas.Enable(ctx, userID, step-10, nil); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := checkSecondFactor(ctx, mfas, userID, code, ""); err != nil || !ok {
+		t.Fatalf("first use = %v, %v; want accepted", ok, err)
+	}
+	if ok, err := checkSecondFactor(ctx, mfas, userID, code, ""); err != nil || ok {
+		t.Fatalf("replay = %v, %v; want rejected", ok, err)
+	}
+
+	// An earlier code, still within the allowed skew, is spent as well.
+	earlier, err := totp.Code(secret, step-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := checkSecondFactor(ctx, mfas, userID, earlier, ""); err != nil || ok {
+		t.Fatalf("earlier step after a later one = %v, %v; want rejected", ok, err)
+	}
+}
@@ -0,0 +1,266 @@
+package users
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+func GetProfile(users store.UserRepository, mfas store.MFARepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		permissions, _ := c.Locals("permissions").(auth.Permissions)
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		mfa, err := mfas.Get(c.UserContext(), userID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Failed to fetch mfa", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		profile := userResponse(user)
+		profile["pending_email"] = user.PendingEmail
+		profile["mfa_enabled"] = err == nil && mfa.EnabledAt != nil
+		profile["permissions"] = permissions
+
+		return c.JSON(fiber.Map{
+			"message": "Profile retrieved successfully",
+			"user":    profile,
+		})
+	}
+}
+
+// UpdateProfile changes the caller's username and phone number. Fields left
+// out of the body are kept; an empty phone removes it.
+func UpdateProfile(users store.UserRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		var req struct {
+			Username *string `json:"username"`
+			Phone    *string `json:"phone"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return errorResponse(c, "Invalid request body", 400)
+		}
+		if req.Username == nil && req.Phone == nil {
+			return errorResponse(c, "Nothing to update", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		if req.Username != nil {
+			user.Username = strings.TrimSpace(*req.Username)
+			if len(user.Username) < 3 {
+				return errorResponse(c, "Username must be at least 3 characters", 400)
+			}
+		}
+		if req.Phone != nil {
+			user.Phone = strings.NewReplacer(" ", "", "-", "").Replace(*req.Phone)
+			if user.Phone != "" && !phonePattern.MatchString(user.Phone) {
+				return errorResponse(c, "Invalid phone number", 400)
+			}
+		}
+
+		if err := users.UpdateProfile(c.UserContext(), userID, user.Username, user.Phone); err != nil {
+			slog.Error("Failed to update profile", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to update profile", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Profile updated successfully",
+			"user":    userResponse(user),
+		})
+	}
+}
+
+// ChangePassword sets a new password once the current one is confirmed. It
+// signs the user out of every other session and returns a new one.
+func ChangePassword(users store.UserRepository, tokens store.TokenRepository, sessions *auth.Sessions, throttle *auth.LoginThrottle) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		jti, _ := c.Locals("jti").(string)
+		expiresAt, _ := c.Locals("token_expires_at").(time.Time)
+		mfa, _ := c.Locals("mfa").(bool)
+
+		var req struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.CurrentPassword == "" {
+			return errorResponse(c, "Current password is required", 400)
+		}
+		if len(req.NewPassword) < 6 {
+			return errorResponse(c, "Password must be at least 6 characters", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if rejected, err := confirmPassword(c, throttle, user, req.CurrentPassword); rejected {
+			return err
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return errorResponse(c, "Failed to process password", 500)
+		}
+		if err := users.SetPassword(c.UserContext(), userID, string(hashedPassword)); err != nil {
+			slog.Error("Failed to set password", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to change password", 500)
+		}
+
+		if err := tokens.RevokeUserRefreshTokens(c.UserContext(), userID); err != nil {
+			slog.Error("Failed to revoke sessions after password change", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to change password", 500)
+		}
+		if err := tokens.DenyAccessToken(c.UserContext(), jti, expiresAt); err != nil {
+			slog.Error("Failed to revoke access token after password change", "user_id", userID, "error", err)
+		}
+
+		session, err := sessions.Start(c.UserContext(), user, mfa)
+		if err != nil {
+			return errorResponse(c, "Failed to generate token", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Password changed successfully. Other sessions have been signed out",
+			"user":    tokenResponse(user.Email, session),
+		})
+	}
+}
+
+// RequestEmailChange starts changing the caller's email. The new address
+// only replaces the current one once it is confirmed from its mailbox.
+func RequestEmailChange(users store.UserRepository, emails *Emails, throttle *auth.LoginThrottle) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		var req struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Password == "" {
+			return errorResponse(c, "Email and password are required", 400)
+		}
+		if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+			return errorResponse(c, "Invalid email address", 400)
+		}
+
+		user, err := users.GetByID(c.UserContext(), userID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", userID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if req.Email == user.Email {
+			return errorResponse(c, "This is already your email address", 400)
+		}
+		if rejected, err := confirmPassword(c, throttle, user, req.Password); rejected {
+			return err
+		}
+
+		exists, err := users.EmailExists(c.UserContext(), req.Email)
+		if err != nil {
+			slog.Error("Database error", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if exists {
+			return errorResponse(c, "Email already registered", 400)
+		}
+
+		if err := users.SetPendingEmail(c.UserContext(), userID, req.Email); err != nil {
+			slog.Error("Failed to set pending email", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to change email", 500)
+		}
+		if err := emails.SendEmailChange(c.UserContext(), user, req.Email); err != nil {
+			slog.Error("Failed to issue email change token", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to send confirmation email", 500)
+		}
+
+		return c.Status(202).JSON(fiber.Map{
+			"message": "Confirmation email sent to the new address",
+		})
+	}
+}
+
+func ConfirmEmailChange(users store.UserRepository, tokens store.TokenRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Token string `json:"token"`
+		}
+
+		if err := c.BodyParser(&req); err != nil || req.Token == "" {
+			return errorResponse(c, "Token is required", 400)
+		}
+
+		token, err := tokens.ConsumeUserToken(c.UserContext(), store.TokenChangeEmail, auth.HashToken(req.Token), time.Now())
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return errorResponse(c, "Invalid or expired token", 400)
+			}
+			slog.Error("Failed to consume email change token", "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+
+		user, err := users.GetByID(c.UserContext(), token.UserID)
+		if err != nil {
+			slog.Error("Failed to fetch user", "user_id", token.UserID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if user.PendingEmail == "" {
+			return errorResponse(c, "Invalid or expired token", 400)
+		}
+
+		if err := users.ChangeEmail(c.UserContext(), user.UserID, user.PendingEmail); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return errorResponse(c, "Email already registered", 409)
+			}
+			slog.Error("Failed to change email", "user_id", user.UserID, "error", err)
+			return errorResponse(c, "Failed to change email", 500)
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Email changed successfully",
+			"email":   user.PendingEmail,
+		})
+	}
+}
+
+// confirmPassword checks the caller's current password, counting a wrong
+// one as a failed login. It reports whether it has already responded.
+func confirmPassword(c *fiber.Ctx, throttle *auth.LoginThrottle, user store.User, password string) (bool, error) {
+	if limited, err := checkThrottle(c, throttle, user.Email); limited {
+		return true, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return true, errorResponse(c, "Current password is incorrect", 403)
+	}
+	passThrottle(c, throttle, user.Email)
+
+	return false, nil
+}
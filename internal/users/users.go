@@ -1,15 +1,21 @@
 package users
 
 import (
-	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"strconv"
 	"take-home-test/internal/auth"
+	"take-home-test/internal/store"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/exp/slog"
 )
 
-func RegisterAdmin(db *sql.DB, jwtSecret string) fiber.Handler {
+func RegisterUser(users store.UserRepository, sessions *auth.Sessions, emails *Emails) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req struct {
 			Username string `json:"username"`
@@ -24,17 +30,19 @@ func RegisterAdmin(db *sql.DB, jwtSecret string) fiber.Handler {
 		if len(req.Username) < 3 {
 			return errorResponse(c, "Username must be at least 3 characters", 400)
 		}
+		if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+			return errorResponse(c, "Invalid email address", 400)
+		}
 		if len(req.Password) < 6 {
 			return errorResponse(c, "Password must be at least 6 characters", 400)
 		}
 
-		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", req.Email).Scan(&count)
+		exists, err := users.EmailExists(c.UserContext(), req.Email)
 		if err != nil {
 			slog.Error("Database error", "error", err)
 			return errorResponse(c, "Internal server error", 500)
 		}
-		if count > 0 {
+		if exists {
 			return errorResponse(c, "Email already registered", 400)
 		}
 
@@ -43,36 +51,43 @@ func RegisterAdmin(db *sql.DB, jwtSecret string) fiber.Handler {
 			return errorResponse(c, "Failed to process password", 500)
 		}
 
-		var userID int
-		err = db.QueryRow(
-			"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4) RETURNING user_id",
-			req.Username, req.Email, string(hashedPassword), "admin",
-		).Scan(&userID)
-
-		if err != nil {
+		user := store.User{
+			Username: req.Username,
+			Email:    req.Email,
+			Password: string(hashedPassword),
+			Role:     "user",
+		}
+		if err := users.Create(c.UserContext(), &user); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return errorResponse(c, "Email already registered", 400)
+			}
 			slog.Error("Failed to create user", "error", err)
 			return errorResponse(c, "Failed to create user", 500)
 		}
 
-		token, err := auth.GenerateJWT(jwtSecret, userID, req.Email, "admin")
+		if err := emails.SendVerification(c.UserContext(), user); err != nil {
+			slog.Error("Failed to issue verification token", "user_id", user.UserID, "error", err)
+		}
+
+		tokens, err := sessions.Start(c.UserContext(), user, false)
 		if err != nil {
 			return errorResponse(c, "Failed to generate token", 500)
 		}
 
 		return c.Status(201).JSON(fiber.Map{
 			"message": "User registered successfully",
-			"user": fiber.Map{
-				"email": req.Email,
-				"token": token,
-			},
+			"user":    tokenResponse(user.Email, tokens),
 		})
 	}
 }
 
-func RegisterUser(db *sql.DB, jwtSecret string) fiber.Handler {
+// dummyPasswordHash is compared against when the email is unknown, so the
+// response takes as long as for a wrong password.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func Login(users store.UserRepository, mfas store.MFARepository, sessions *auth.Sessions, throttle *auth.LoginThrottle) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req struct {
-			Username string `json:"username"`
 			Email    string `json:"email"`
 			Password string `json:"password"`
 		}
@@ -81,102 +96,148 @@ func RegisterUser(db *sql.DB, jwtSecret string) fiber.Handler {
 			return errorResponse(c, "Invalid request body", 400)
 		}
 
-		if len(req.Username) < 3 {
-			return errorResponse(c, "Username must be at least 3 characters", 400)
-		}
-		if len(req.Password) < 6 {
-			return errorResponse(c, "Password must be at least 6 characters", 400)
+		if limited, err := checkThrottle(c, throttle, req.Email); limited {
+			return err
 		}
 
-		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", req.Email).Scan(&count)
+		user, err := users.GetByEmail(c.UserContext(), req.Email)
 		if err != nil {
-			slog.Error("Database error", "error", err)
-			return errorResponse(c, "Internal server error", 500)
-		}
-		if count > 0 {
-			return errorResponse(c, "Email already registered", 400)
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+			return errorResponse(c, "Invalid email or password", 401)
 		}
 
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 		if err != nil {
-			return errorResponse(c, "Failed to process password", 500)
+			return errorResponse(c, "Invalid email or password", 401)
 		}
 
-		var userID int
-		err = db.QueryRow(
-			"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4) RETURNING user_id",
-			req.Username, req.Email, string(hashedPassword), "user",
-		).Scan(&userID)
+		passThrottle(c, throttle, req.Email)
+		// Only revealed to someone who knows the password.
+		if user.DisabledAt != nil {
+			return errorResponse(c, "Account is disabled", 403)
+		}
 
-		if err != nil {
-			slog.Error("Failed to create user", "error", err)
-			return errorResponse(c, "Failed to create user", 500)
+		mfa, err := mfas.Get(c.UserContext(), user.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Failed to fetch mfa", "user_id", user.UserID, "error", err)
+			return errorResponse(c, "Internal server error", 500)
+		}
+		if err == nil && mfa.EnabledAt != nil {
+			challenge, err := sessions.StartMFAChallenge(user)
+			if err != nil {
+				return errorResponse(c, "Failed to generate token", 500)
+			}
+			return c.JSON(fiber.Map{
+				"message":      "Enter the code from your authenticator app",
+				"mfa_required": true,
+				"mfa_token":    challenge,
+			})
 		}
 
-		token, err := auth.GenerateJWT(jwtSecret, userID, req.Email, "user")
+		tokens, err := sessions.Start(c.UserContext(), user, false)
 		if err != nil {
 			return errorResponse(c, "Failed to generate token", 500)
 		}
 
-		return c.Status(201).JSON(fiber.Map{
-			"message": "User registered successfully",
-			"user": fiber.Map{
-				"email": req.Email,
-				"token": token,
-			},
+		return c.JSON(fiber.Map{
+			"message": "Login successful",
+			"user":    tokenResponse(user.Email, tokens),
 		})
 	}
 }
 
-func Login(db *sql.DB, jwtSecret string) fiber.Handler {
+func Refresh(sessions *auth.Sessions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
+			RefreshToken string `json:"refresh_token"`
 		}
 
-		if err := c.BodyParser(&req); err != nil {
-			return errorResponse(c, "Invalid request body", 400)
+		if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+			return errorResponse(c, "Refresh token is required", 400)
 		}
 
-		var user struct {
-			UserID   int
-			Username string
-			Email    string
-			Password string
-			Role     string
+		user, tokens, err := sessions.Refresh(c.UserContext(), req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+				return errorResponse(c, "Invalid or expired refresh token", 401)
+			}
+			if errors.Is(err, auth.ErrAccountDisabled) {
+				return errorResponse(c, "Account is disabled", 403)
+			}
+			slog.Error("Failed to refresh token", "error", err)
+			return errorResponse(c, "Failed to refresh token", 500)
 		}
 
-		err := db.QueryRow(
-			"SELECT user_id, username, email, password, role FROM users WHERE email = $1",
-			req.Email,
-		).Scan(&user.UserID, &user.Username, &user.Email, &user.Password, &user.Role)
+		return c.JSON(fiber.Map{
+			"message": "Token refreshed successfully",
+			"user":    tokenResponse(user.Email, tokens),
+		})
+	}
+}
 
-		if err != nil {
-			return errorResponse(c, "Invalid email or password", 401)
-		}
+func Logout(sessions *auth.Sessions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+		jti, _ := c.Locals("jti").(string)
+		expiresAt, _ := c.Locals("token_expires_at").(time.Time)
 
-		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-		if err != nil {
-			return errorResponse(c, "Invalid email or password", 401)
+		var req struct {
+			RefreshToken string `json:"refresh_token"`
 		}
+		// The body is optional; without a refresh token only the access
+		// token is revoked.
+		_ = c.BodyParser(&req)
 
-		token, err := auth.GenerateJWT(jwtSecret, user.UserID, user.Email, user.Role)
+		err := sessions.Logout(c.UserContext(), userID, jti, expiresAt, req.RefreshToken)
 		if err != nil {
-			return errorResponse(c, "Failed to generate token", 500)
+			if errors.Is(err, auth.ErrInvalidRefreshToken) {
+				return errorResponse(c, "Invalid refresh token", 400)
+			}
+			slog.Error("Failed to log out", "user_id", userID, "error", err)
+			return errorResponse(c, "Failed to log out", 500)
 		}
 
 		return c.JSON(fiber.Map{
-			"message": "Login successful",
-			"user": fiber.Map{
-				"email": user.Email,
-				"token": token,
-			},
+			"message": "Logged out successfully",
 		})
 	}
 }
 
+// checkThrottle reserves an attempt at email's password or code, which
+// counts as a failure until passThrottle takes it back. It reports whether
+// the caller must wait instead, in which case it has already responded and
+// the handler should return the error.
+func checkThrottle(c *fiber.Ctx, throttle *auth.LoginThrottle, email string) (bool, error) {
+	wait, err := throttle.Attempt(c.UserContext(), email, c.IP())
+	if err != nil {
+		slog.Error("Failed to check login throttle", "error", err)
+		return true, errorResponse(c, "Internal server error", 500)
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return true, errorResponse(c, fmt.Sprintf("Too many failed login attempts. Try again in %d seconds", seconds), 429)
+	}
+	return false, nil
+}
+
+// passThrottle takes back the attempt checkThrottle reserved once it turned
+// out to be right.
+func passThrottle(c *fiber.Ctx, throttle *auth.LoginThrottle, email string) {
+	if err := throttle.Success(c.UserContext(), email, c.IP()); err != nil {
+		slog.Error("Failed to reset login failures", "error", err)
+	}
+}
+
+func tokenResponse(email string, tokens auth.TokenPair) fiber.Map {
+	return fiber.Map{
+		"email":         email,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    int(tokens.ExpiresIn.Seconds()),
+	}
+}
+
 func errorResponse(c *fiber.Ctx, message string, status int) error {
 	return c.Status(status).JSON(fiber.Map{"error": message})
 }
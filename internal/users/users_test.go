@@ -0,0 +1,170 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testEmail    = "player@example.com"
+	testPassword = "correct horse"
+)
+
+var testPolicy = auth.ThrottlePolicy{
+	AccountMaxFailures: 5,
+	IPMaxFailures:      100,
+	Window:             15 * time.Minute,
+	Lockout:            15 * time.Minute,
+	// Keep the delay between attempts out of the way of the lockout.
+	MaxDelay: 0,
+}
+
+// newLoginApp serves Login from in-memory repositories holding one user.
+func newLoginApp(t *testing.T, policy auth.ThrottlePolicy) *fiber.App {
+	t.Helper()
+
+	db := memory.NewDB()
+	userRepo := memory.NewUserRepository(db)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := store.User{Username: "player", Email: testEmail, Password: string(hash), Role: "user"}
+	if err := userRepo.Create(context.Background(), &user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	keys, err := auth.NewHMACKeySet("test secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := auth.NewSessions(keys, 15*time.Minute, time.Hour, userRepo, memory.NewTokenRepository(db), memory.NewRoleRepository(db))
+	throttle := auth.NewLoginThrottle(memory.NewLoginAttemptRepository(db), policy)
+
+	app := fiber.New()
+	app.Get("/auth/login", Login(userRepo, memory.NewMFARepository(db), sessions, throttle))
+	return app
+}
+
+// login tries password and returns the status and the access token, if any.
+func login(t *testing.T, app *fiber.App, password string) (int, string) {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"email":%q,"password":%q}`, testEmail, password)
+	req := httptest.NewRequest("GET", "/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		User struct {
+			Token string `json:"token"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("login: decode %q: %v", raw, err)
+	}
+	return resp.StatusCode, result.User.Token
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newLoginApp(t, testPolicy)
+
+	if status, token := login(t, app, testPassword); status != fiber.StatusOK || token == "" {
+		t.Fatalf("first login: status %d, token %q; want 200 and a token", status, token)
+	}
+
+	for i := 1; i <= testPolicy.AccountMaxFailures; i++ {
+		if status, _ := login(t, app, "wrong"); status != fiber.StatusUnauthorized {
+			t.Fatalf("wrong password %d: status %d, want 401", i, status)
+		}
+	}
+
+	// Locked out: even the right password gets no token.
+	status, token := login(t, app, testPassword)
+	if status != fiber.StatusTooManyRequests {
+		t.Errorf("locked out login: status %d, want 429", status)
+	}
+	if token != "" {
+		t.Errorf("locked out login returned a token")
+	}
+}
+
+func TestLoginLockoutParallel(t *testing.T) {
+	app := newLoginApp(t, testPolicy)
+
+	const guesses = 30
+	statuses := make([]int, guesses)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			statuses[i], _ = login(t, app, "wrong")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	counts := make(map[int]int)
+	for _, status := range statuses {
+		counts[status]++
+	}
+	if counts[fiber.StatusUnauthorized] != testPolicy.AccountMaxFailures || counts[fiber.StatusTooManyRequests] != guesses-testPolicy.AccountMaxFailures {
+		t.Errorf("statuses %v, want %d checked and the rest 429", counts, testPolicy.AccountMaxFailures)
+	}
+
+	if status, token := login(t, app, testPassword); status != fiber.StatusTooManyRequests || token != "" {
+		t.Errorf("login after parallel guesses: status %d, token %q; want 429 and no token", status, token)
+	}
+}
+
+func TestLoginSuccessDoesNotCountAgainstIP(t *testing.T) {
+	policy := testPolicy
+	policy.IPMaxFailures = 3
+	app := newLoginApp(t, policy)
+
+	// Everyone behind one address logs in successfully many times over.
+	for i := 0; i < 2*policy.IPMaxFailures; i++ {
+		if status, _ := login(t, app, testPassword); status != fiber.StatusOK {
+			t.Fatalf("login %d: status %d, want 200", i+1, status)
+		}
+	}
+}
+
+func TestLoginDelayAfterRepeatedFailures(t *testing.T) {
+	policy := testPolicy
+	policy.MaxDelay = time.Minute
+	app := newLoginApp(t, policy)
+
+	// From the third failure on, the next attempt must wait.
+	for i := 1; i <= 3; i++ {
+		if status, _ := login(t, app, "wrong"); status != fiber.StatusUnauthorized {
+			t.Fatalf("wrong password %d: status %d, want 401", i, status)
+		}
+	}
+	if status, token := login(t, app, testPassword); status != fiber.StatusTooManyRequests || token != "" {
+		t.Errorf("login during delay: status %d, token %q; want 429 and no token", status, token)
+	}
+}
@@ -0,0 +1,310 @@
+package waitlist
+
+import (
+	"errors"
+	"strconv"
+	"take-home-test/internal/auth"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/store"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JoinHandler puts the caller on the waitlist for a slot that is taken.
+// Free slots are turned away, since they can be booked directly.
+func JoinHandler(entries store.WaitlistRepository, bookings store.BookingRepository, fieldRepo store.FieldRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(int)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User not authenticated",
+			})
+		}
+
+		var req struct {
+			FieldID     int    `json:"field_id"`
+			BookingDate string `json:"booking_date"`
+			StartTime   string `json:"start_time"`
+			EndTime     string `json:"end_time"`
+		}
+
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+
+		if req.FieldID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid field ID",
+			})
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", req.BookingDate, time.Local)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid booking date format. Use YYYY-MM-DD",
+			})
+		}
+		start, startOK := fields.ParseClock(req.StartTime)
+		end, endOK := fields.ParseClock(req.EndTime)
+		if !startOK || !endOK {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid time format. Use HH:MM",
+			})
+		}
+		if end <= start {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "End time must be after start time",
+			})
+		}
+		if date.Add(time.Duration(start) * time.Minute).Before(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot join the waitlist for a slot in the past",
+			})
+		}
+
+		// Normalise the inputs so stored values compare consistently.
+		req.BookingDate = date.Format("2006-01-02")
+		req.StartTime, req.EndTime = fields.FormatClock(start), fields.FormatClock(end)
+
+		field, err := fieldRepo.Get(c.UserContext(), req.FieldID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check field: " + err.Error(),
+			})
+		}
+
+		if err := fields.CheckBooking(c.UserContext(), fieldRepo, field, date, req.StartTime, req.EndTime); err != nil {
+			var slotErr *fields.SlotError
+			if errors.As(err, &slotErr) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": slotErr.Error(),
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check opening hours: " + err.Error(),
+			})
+		}
+
+		free, err := bookings.IsAvailable(c.UserContext(), req.FieldID, req.BookingDate, req.StartTime, req.EndTime)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check availability: " + err.Error(),
+			})
+		}
+		if free {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Field is free at the selected time. Book it instead",
+			})
+		}
+
+		entry := store.WaitlistEntry{
+			UserID:      userID,
+			FieldID:     req.FieldID,
+			BookingDate: req.BookingDate,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+		}
+		if err := entries.Create(c.UserContext(), &entry); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "You are already on the waitlist for this slot",
+				})
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"error": "Field not found",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to join waitlist: " + err.Error(),
+			})
+		}
+
+		position, err := positionOf(c, entries, entry)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch waitlist position",
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"message": "Joined waitlist successfully",
+			"entry":   entryResponse(entry, position),
+		})
+	}
+}
+
+// ListHandler returns the caller's waitlist entries, newest first.
+func ListHandler(entries store.WaitlistRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(int)
+
+		list, err := entries.ListByUser(c.UserContext(), userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch waitlist",
+			})
+		}
+
+		// Entries for the same day share one queue lookup.
+		queues := make(map[string][]store.WaitlistEntry)
+		result := []fiber.Map{}
+		for _, e := range list {
+			position := 0
+			if e.Status == store.WaitlistWaiting {
+				key := strconv.Itoa(e.FieldID) + "/" + e.BookingDate
+				queue, ok := queues[key]
+				if !ok {
+					if queue, err = entries.Waiting(c.UserContext(), e.FieldID, e.BookingDate); err != nil {
+						return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+							"error": "Failed to fetch waitlist position",
+						})
+					}
+					queues[key] = queue
+				}
+				position = queuePosition(e, queue)
+			}
+			result = append(result, entryResponse(e, position))
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Waitlist retrieved successfully",
+			"entries": result,
+		})
+	}
+}
+
+func GetHandler(entries store.WaitlistRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entry, ok, err := ownEntry(c, entries, auth.PermBookingsReadAll)
+		if !ok {
+			return err
+		}
+
+		position, err := positionOf(c, entries, entry)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to fetch waitlist position",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Waitlist entry retrieved successfully",
+			"entry":   entryResponse(entry, position),
+		})
+	}
+}
+
+// LeaveHandler takes the caller off the waitlist. An offer already made
+// is declined by cancelling its booking instead.
+func LeaveHandler(entries store.WaitlistRepository) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		entry, ok, err := ownEntry(c, entries, auth.PermBookingsCancelAll)
+		if !ok {
+			return err
+		}
+
+		if err := entries.Cancel(c.UserContext(), entry.EntryID); err != nil {
+			if errors.Is(err, store.ErrStatusChanged) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "Only waiting entries can be cancelled",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to leave waitlist",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "Left waitlist successfully",
+		})
+	}
+}
+
+// ownEntry loads the entry named in the path if the caller owns it or holds
+// permission. When ok is false the response has already been written.
+func ownEntry(c *fiber.Ctx, entries store.WaitlistRepository, permission string) (store.WaitlistEntry, bool, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return store.WaitlistEntry{}, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid waitlist entry ID",
+		})
+	}
+
+	entry, err := entries.Get(c.UserContext(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return entry, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Waitlist entry not found",
+			})
+		}
+		return entry, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch waitlist entry",
+		})
+	}
+
+	userID, _ := c.Locals("user_id").(int)
+	permissions, _ := c.Locals("permissions").(auth.Permissions)
+	if entry.UserID != userID && !permissions.Has(permission) {
+		return entry, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Waitlist entry not found",
+		})
+	}
+
+	return entry, true, nil
+}
+
+// positionOf returns e's place in the queue for its slot, or 0 if it is no
+// longer waiting.
+func positionOf(c *fiber.Ctx, entries store.WaitlistRepository, e store.WaitlistEntry) (int, error) {
+	if e.Status != store.WaitlistWaiting {
+		return 0, nil
+	}
+	queue, err := entries.Waiting(c.UserContext(), e.FieldID, e.BookingDate)
+	if err != nil {
+		return 0, err
+	}
+	return queuePosition(e, queue), nil
+}
+
+// position0 counts the waiting entries ahead of e, oldest first, whose
+// windows overlap e's: any of them would be offered the slot first.
+func queuePosition(e store.WaitlistEntry, queue []store.WaitlistEntry) int {
+	position := 1
+	for _, other := range queue {
+		if other.EntryID == e.EntryID {
+			break
+		}
+		if other.StartTime < e.EndTime && e.StartTime < other.EndTime {
+			position++
+		}
+	}
+	return position
+}
+
+func entryResponse(e store.WaitlistEntry, position int) fiber.Map {
+	result := fiber.Map{
+		"entry_id":         e.EntryID,
+		"field_id":         e.FieldID,
+		"booking_date":     e.BookingDate,
+		"start_time":       e.StartTime,
+		"end_time":         e.EndTime,
+		"status":           e.Status,
+		"booking_id":       e.BookingID,
+		"offered_at":       e.OfferedAt,
+		"offer_expires_at": e.OfferExpiresAt,
+		"created_at":       e.CreatedAt,
+	}
+	if position > 0 {
+		result["position"] = position
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"take-home-test/internal/fields"
+	"take-home-test/internal/mailer"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/store"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Service offers freed slots to the users waiting for them. An offer is a
+// pending booking made on the user's behalf, held for the offer TTL
+// instead of the usual hold, which they confirm by paying as usual.
+type Service struct {
+	entries   store.WaitlistRepository
+	bookings  store.BookingRepository
+	fieldRepo store.FieldRepository
+	users     store.UserRepository
+	pricer    *pricing.Service
+	mailer    mailer.Mailer
+	offerTTL  time.Duration
+
+	// mu runs one pass at a time, so entries are always offered in the
+	// order they joined.
+	mu sync.Mutex
+}
+
+func NewService(entries store.WaitlistRepository, bookings store.BookingRepository, fieldRepo store.FieldRepository, users store.UserRepository, pricer *pricing.Service, m mailer.Mailer, offerTTL time.Duration) *Service {
+	return &Service{
+		entries:   entries,
+		bookings:  bookings,
+		fieldRepo: fieldRepo,
+		users:     users,
+		pricer:    pricer,
+		mailer:    m,
+		offerTTL:  offerTTL,
+	}
+}
+
+// Released offers what a cancelled or moved booking freed on fieldID's
+// date. It works in the background so the request that freed the slot
+// does not wait for it.
+func (s *Service) Released(fieldID int, date string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if _, err := s.Offer(ctx, fieldID, date, time.Now()); err != nil {
+			slog.Error("Failed to offer released slot", "field_id", fieldID, "date", date, "error", err)
+		}
+	}()
+}
+
+// RunOffers settles and offers the whole waitlist every interval until ctx
+// is cancelled. This picks up holds that expired and anything Released
+// missed.
+func RunOffers(ctx context.Context, s *Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.Offer(ctx, 0, "", now); err != nil {
+				slog.Error("Failed to offer waitlisted slots", "error", err)
+			}
+		}
+	}
+}
+
+// Offer brings the waitlist up to date and then offers every slot that is
+// free to the entries waiting for it, oldest first. A non-zero fieldID and
+// a non-empty date narrow it to one field and day. It returns how many
+// offers were made.
+func (s *Service) Offer(ctx context.Context, fieldID int, date string, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.entries.Settle(ctx, now); err != nil {
+		return 0, err
+	}
+
+	waiting, err := s.entries.Waiting(ctx, fieldID, date)
+	if err != nil {
+		return 0, err
+	}
+
+	offered := 0
+	byID := make(map[int]store.Field)
+	for _, e := range waiting {
+		field, ok := byID[e.FieldID]
+		if !ok {
+			if field, err = s.fieldRepo.Get(ctx, e.FieldID); err != nil {
+				return offered, err
+			}
+			byID[e.FieldID] = field
+		}
+
+		ok, err := s.offer(ctx, e, field, now)
+		if err != nil {
+			return offered, err
+		}
+		if ok {
+			offered++
+		}
+	}
+
+	if offered > 0 {
+		slog.Info("Offered waitlisted slots", "count", offered)
+	}
+	return offered, nil
+}
+
+// offer holds e's slot for its user if it is free, reporting whether it
+// did.
+func (s *Service) offer(ctx context.Context, e store.WaitlistEntry, field store.Field, now time.Time) (bool, error) {
+	date, err := time.ParseInLocation("2006-01-02", e.BookingDate, time.Local)
+	if err != nil {
+		return false, err
+	}
+
+	// The hours or a blackout may have changed since the user joined; the
+	// entry then waits until it expires or they leave.
+	if err := fields.CheckBooking(ctx, s.fieldRepo, field, date, e.StartTime, e.EndTime); err != nil {
+		var slotErr *fields.SlotError
+		if errors.As(err, &slotErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	free, err := s.bookings.IsAvailable(ctx, e.FieldID, e.BookingDate, e.StartTime, e.EndTime)
+	if err != nil || !free {
+		return false, err
+	}
+
+	quote, err := s.pricer.Quote(ctx, field, date, e.StartTime, e.EndTime)
+	if err != nil {
+		return false, err
+	}
+
+	expiresAt := now.Add(s.offerTTL)
+	booking := store.Booking{
+		UserID:         e.UserID,
+		FieldID:        e.FieldID,
+		BookingDate:    e.BookingDate,
+		StartTime:      e.StartTime,
+		EndTime:        e.EndTime,
+		TotalPrice:     quote.Total,
+		PriceBreakdown: quote.Segments,
+		Status:         store.BookingPending,
+		ExpiresAt:      &expiresAt,
+	}
+	if err := s.bookings.Create(ctx, &booking); err != nil {
+		if errors.Is(err, store.ErrSlotUnavailable) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := s.entries.MarkOffered(ctx, e.EntryID, booking.BookingID, expiresAt); err != nil {
+		// The user left the waitlist meanwhile, so give the slot back.
+		if cancelErr := s.bookings.Cancel(ctx, booking.BookingID, store.BookingPending, 0); cancelErr != nil {
+			slog.Error("Failed to release unwanted waitlist hold", "booking_id", booking.BookingID, "error", cancelErr)
+		}
+		if errors.Is(err, store.ErrStatusChanged) {
+			return false, nil
+		}
+		return false, err
+	}
+	slog.Info("Offered waitlisted slot", "entry_id", e.EntryID, "user_id", e.UserID, "booking_id", booking.BookingID)
+
+	s.notify(ctx, e, field, booking)
+	return true, nil
+}
+
+func (s *Service) notify(ctx context.Context, e store.WaitlistEntry, field store.Field, booking store.Booking) {
+	user, err := s.users.GetByID(ctx, e.UserID)
+	if err != nil {
+		slog.Error("Failed to fetch waitlisted user", "user_id", e.UserID, "error", err)
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "A slot you were waiting for is free",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\n%s is free on %s from %s to %s. We are holding it for you as booking #%d until %s.\n\nPay for the booking before then to keep it; otherwise it goes to the next person waiting.\n",
+			user.Username, field.Name, booking.BookingDate, booking.StartTime, booking.EndTime, booking.BookingID,
+			booking.ExpiresAt.In(time.Local).Format("2006-01-02 15:04"),
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		slog.Error("Failed to send waitlist offer", "user_id", e.UserID, "booking_id", booking.BookingID, "error", err)
+	}
+}
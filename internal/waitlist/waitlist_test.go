@@ -0,0 +1,63 @@
+package waitlist
+
+import (
+	"context"
+	"io"
+	"take-home-test/internal/mailer"
+	"take-home-test/internal/memory"
+	"take-home-test/internal/pricing"
+	"take-home-test/internal/store"
+	"testing"
+	"time"
+)
+
+func TestOfferSkipsDisabledUsers(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	users := memory.NewUserRepository(db)
+	fieldRepo := memory.NewFieldRepository(db)
+	entries := memory.NewWaitlistRepository(db)
+
+	field := store.Field{Name: "Court 1", PricePerHour: 100000, Location: "Jakarta"}
+	if err := fieldRepo.Create(ctx, &field); err != nil {
+		t.Fatalf("create field: %v", err)
+	}
+
+	date := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	var waiting []store.WaitlistEntry
+	for _, name := range []string{"first", "second"} {
+		user := store.User{Username: name, Email: name + "@example.com", Password: "x", Role: "user"}
+		if err := users.Create(ctx, &user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		entry := store.WaitlistEntry{UserID: user.UserID, FieldID: field.FieldID, BookingDate: date, StartTime: "10:00", EndTime: "11:00"}
+		if err := entries.Create(ctx, &entry); err != nil {
+			t.Fatalf("join waitlist: %v", err)
+		}
+		waiting = append(waiting, entry)
+	}
+
+	if err := users.SetDisabled(ctx, waiting[0].UserID, true); err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+
+	s := NewService(entries, memory.NewBookingRepository(db), fieldRepo, users,
+		pricing.NewService(memory.NewPricingRuleRepository(db)), mailer.NewLogMailer(io.Discard), 30*time.Minute)
+	offered, err := s.Offer(ctx, field.FieldID, date, time.Now())
+	if err != nil {
+		t.Fatalf("offer: %v", err)
+	}
+	if offered != 1 {
+		t.Fatalf("offered %d slots, want 1", offered)
+	}
+
+	for i, want := range []string{store.WaitlistCancelled, store.WaitlistOffered} {
+		entry, err := entries.Get(ctx, waiting[i].EntryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Status != want {
+			t.Errorf("entry of user %d is %s, want %s", entry.UserID, entry.Status, want)
+		}
+	}
+}